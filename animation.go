@@ -0,0 +1,362 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"slices"
+)
+
+// Animation 合成后的动画，每一帧都是完整的画面
+type Animation struct {
+	Frames []image.Image // 所有帧尺寸相同
+	Delays []int         // 每帧的延迟，单位为 1/100 秒
+	// 循环次数，与 gif.GIF.LoopCount 相同：0 表示无限循环，-1 表示只播放一次
+	LoopCount int
+}
+
+// Bounds 返回动画的尺寸，没有帧时为空
+func (a *Animation) Bounds() image.Rectangle {
+	if len(a.Frames) == 0 {
+		return image.Rectangle{}
+	}
+	return a.Frames[0].Bounds()
+}
+
+// NewAnimationFromGIF 根据处置方式将 GIF 的各帧合成为完整的画面
+// GIF 中的帧可能只包含与上一帧不同的区域，合成后每一帧都可以单独处理。
+func NewAnimationFromGIF(g *gif.GIF) (*Animation, error) {
+	if len(g.Image) == 0 {
+		return nil, errors.New("GIF 中没有图像帧")
+	}
+
+	screen := animationCanvas(g)
+	anim := &Animation{
+		Frames:    make([]image.Image, 0, len(g.Image)),
+		Delays:    make([]int, len(g.Image)),
+		LoopCount: g.LoopCount,
+	}
+	copy(anim.Delays, g.Delay)
+
+	canvas := image.NewRGBA(screen)
+	var previous *image.RGBA
+	for i, frame := range g.Image {
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = cloneRGBA(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		anim.Frames = append(anim.Frames, cloneRGBA(canvas))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			// 浏览器将背景处理为透明
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	return anim, nil
+}
+
+// animationCanvas 返回合成画布，逻辑屏幕尺寸为空时使用所有帧的并集
+func animationCanvas(g *gif.GIF) image.Rectangle {
+	screen := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if screen.Empty() {
+		screen = image.Rectangle{}
+		for _, frame := range g.Image {
+			screen = screen.Union(frame.Bounds())
+		}
+	}
+	return screen
+}
+
+// scanGIF 只按块结构遍历 GIF 数据，不解码像素，返回图像帧数和合成画布
+// 画布为逻辑屏幕与所有帧区域的并集，不小于 animationCanvas 的结果；数据不完整时返回已读取部分的结果。
+func scanGIF(data []byte) (int, image.Rectangle) {
+	if len(data) < 13 {
+		return 0, image.Rectangle{}
+	}
+	le16 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 }
+	canvas := image.Rect(0, 0, le16(data[6:]), le16(data[8:]))
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipBlocks 跳过以长度为 0 的子块结束的数据子块
+	skipBlocks := func() bool {
+		for pos < len(data) {
+			n := int(data[pos])
+			pos += 1 + n
+			if n == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // 扩展块: 标签和数据子块
+			pos += 2
+			if !skipBlocks() {
+				return frames, canvas
+			}
+		case 0x2c: // 图像描述符: 位置、尺寸、局部颜色表、LZW 最小码长和数据子块
+			if pos+10 > len(data) {
+				return frames, canvas
+			}
+			d := data[pos+1 : pos+10]
+			x, y := le16(d[0:]), le16(d[2:])
+			canvas = canvas.Union(image.Rect(x, y, x+le16(d[4:]), y+le16(d[6:])))
+			frames++
+			pos += 10
+			if d[8]&0x80 != 0 {
+				pos += 3 << (d[8]&0x07 + 1)
+			}
+			pos++
+			if !skipBlocks() {
+				return frames, canvas
+			}
+		default: // 结束符或无法识别的块
+			return frames, canvas
+		}
+	}
+	return frames, canvas
+}
+
+// ProcessAnimation 使用处理器链处理动画的每一帧
+func ProcessAnimation(anim *Animation, processors []Processor) (*Animation, error) {
+	return ProcessAnimationWithContext(context.Background(), anim, processors)
+}
+
+// ProcessAnimationWithContext 使用处理器链处理动画的每一帧，ctx 取消或超时后返回 ctx.Err()
+// 每一帧都是尺寸相同的完整画面，缩放、切割、旋转等处理器在每一帧上得到相同的几何变换。
+// 按内容选择区域的处理器 (smart 位置的切割和 cover 缩放) 在第一帧上确定区域，之后的帧使用相同的区域；
+// 接缝裁剪在每一帧上会移除不同的接缝，处理动画时返回错误。处理后各帧尺寸不一致时返回错误。
+func ProcessAnimationWithContext(ctx context.Context, anim *Animation, processors []Processor) (*Animation, error) {
+	result := &Animation{
+		Frames:    make([]image.Image, len(anim.Frames)),
+		Delays:    anim.Delays,
+		LoopCount: anim.LoopCount,
+	}
+
+	for i, frame := range anim.Frames {
+		var processed image.Image
+		var err error
+		if i == 0 {
+			processed, processors, err = resolveSteps(ctx, frame, processors)
+		} else {
+			processed, err = ProcessWithContext(ctx, frame, processors)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 帧: %w", i, err)
+		}
+		if i > 0 && processed.Bounds().Size() != result.Frames[0].Bounds().Size() {
+			return nil, fmt.Errorf("第 %d 帧处理后尺寸 %v 与第一帧 %v 不一致",
+				i, processed.Bounds().Size(), result.Frames[0].Bounds().Size())
+		}
+		result.Frames[i] = processed
+	}
+
+	return result, nil
+}
+
+// geometryResolver 由可能根据图片内容选择几何变换的处理器实现
+// 处理动画时在第一帧上确定几何变换，使每一帧得到相同的变换。
+type geometryResolver interface {
+	// contentAware 返回几何变换是否取决于图片内容
+	contentAware() bool
+	// resolveGeometry 在 img 上确定几何变换，返回在尺寸相同的图片上使用固定变换的处理器
+	resolveGeometry(img image.Image) (Processor, error)
+}
+
+// resolveSteps 使用处理器链处理 img，返回处理结果和在 img 上确定了几何变换的处理器链
+func resolveSteps(ctx context.Context, img image.Image, processors []Processor) (image.Image, []Processor, error) {
+	resolved := slices.Clone(processors)
+	current := img
+	for i, processor := range resolved {
+		if r, ok := processor.(geometryResolver); ok && r.contentAware() {
+			fixed, err := r.resolveGeometry(current)
+			if err != nil {
+				return nil, nil, fmt.Errorf("步骤 %d: %w", i, err)
+			}
+			resolved[i] = fixed
+		}
+
+		var err error
+		if current, err = processSteps(ctx, current, resolved[i:i+1], i); err != nil {
+			return nil, nil, err
+		}
+	}
+	return current, resolved, nil
+}
+
+// EncodeAnimation 将动画编码为 GIF，保留每帧延迟和循环次数
+// 每一帧使用 MedianCutQuantizer 生成局部调色板，颜色数由 options.GIFColors 指定，
+// 不透明度低于一半的像素编码为透明。
+func EncodeAnimation(w io.Writer, anim *Animation, options *ProcessorOptions) error {
+	if options == nil {
+		options = &DefaultProcessorOptions
+	}
+	if len(anim.Frames) == 0 {
+		return errors.New("动画中没有图像帧")
+	}
+
+	numColors := options.GIFColors
+	if numColors <= 0 || numColors > 256 {
+		numColors = 256
+	}
+
+	size := anim.Bounds().Size()
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, len(anim.Frames)),
+		Delay:     make([]int, len(anim.Frames)),
+		Disposal:  make([]byte, len(anim.Frames)),
+		LoopCount: anim.LoopCount,
+		Config:    image.Config{Width: size.X, Height: size.Y},
+	}
+	copy(g.Delay, anim.Delays)
+
+	for i, frame := range anim.Frames {
+		g.Image[i] = quantizeFrame(frame, numColors)
+		// 每一帧都是完整画面，显示下一帧前清除，透明区域不显示上一帧
+		g.Disposal[i] = gif.DisposalBackground
+	}
+
+	return gif.EncodeAll(w, g)
+}
+
+// keepAnimation 输入为 GIF 且输出为 GIF 时保留动画
+func (o *ProcessorOptions) keepAnimation(input ImageFormat) bool {
+	return input == FormatGIF && !o.DisableAnimation && (o.Format == FormatAuto || o.Format == FormatGIF)
+}
+
+// processAnimation 合成、处理并编码 GIF 动画的所有帧
+func processAnimation(ctx context.Context, g *gif.GIF, w io.Writer, processors []Processor,
+	options *ProcessorOptions, result *ProcessResult,
+) (*ProcessResult, error) {
+	anim, err := NewAnimationFromGIF(g)
+	if err != nil {
+		return nil, err
+	}
+
+	anim, err = ProcessAnimationWithContext(ctx, anim, processors)
+	if err != nil {
+		return nil, err
+	}
+
+	size := anim.Bounds().Size()
+	result.OutputFormat = FormatGIF
+	result.Width = size.X
+	result.Height = size.Y
+	result.Frames = len(anim.Frames)
+	if err := options.checkOutputSize(result.Width, result.Height); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := EncodeAnimation(w, anim, options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// quantizeFrame 将帧转换为调色板图像，原点移动到 (0,0)
+func quantizeFrame(frame image.Image, numColors int) *image.Paletted {
+	bounds := frame.Bounds()
+
+	// 有透明像素时保留一个透明色
+	transparent := hasTransparency(frame)
+	opaqueColors := numColors
+	if transparent {
+		opaqueColors = max(numColors-1, 1)
+	}
+	palette := MedianCutQuantizer{}.Quantize(make(color.Palette, 0, opaqueColors), frame)
+	if len(palette) == 0 {
+		palette = append(palette, color.Black)
+	}
+	transparentIndex := -1
+	if transparent {
+		transparentIndex = len(palette)
+		palette = append(palette, color.Transparent)
+	}
+	opaque := palette
+	if transparent {
+		opaque = palette[:transparentIndex]
+	}
+
+	dst := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette)
+	indexes := make(map[color.NRGBA]uint8)
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := color.NRGBAModel.Convert(frame.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
+			if c.A < 0x80 {
+				dst.Pix[dst.PixOffset(x, y)] = uint8(transparentIndex)
+				continue
+			}
+			c.A = 0xff
+			index, ok := indexes[c]
+			if !ok {
+				index = uint8(opaque.Index(c))
+				indexes[c] = index
+			}
+			dst.Pix[dst.PixOffset(x, y)] = index
+		}
+	}
+	return dst
+}
+
+// hasTransparency 是否包含不透明度低于一半的像素
+func hasTransparency(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return false
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0x8000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cloneRGBA 复制图像
+func cloneRGBA(img *image.RGBA) *image.RGBA {
+	clone := image.NewRGBA(img.Bounds())
+	copy(clone.Pix, img.Pix)
+	return clone
+}
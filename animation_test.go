@@ -0,0 +1,265 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"slices"
+	"testing"
+)
+
+var (
+	testRed   = color.RGBA{R: 255, A: 255}
+	testBlue  = color.RGBA{B: 255, A: 255}
+	testGreen = color.RGBA{G: 255, A: 255}
+	testWhite = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+)
+
+// encodeTestGIF 生成 20x10 的4帧动画:
+// 第0帧全红；第1帧左上角蓝色，之后恢复为背景；第2帧右下绿色，之后恢复为上一帧；第3帧右上角白点
+func encodeTestGIF(t *testing.T) []byte {
+	t.Helper()
+	palette := color.Palette{color.Transparent, testRed, testBlue, testGreen, testWhite}
+	frame := func(r image.Rectangle, c color.Color) *image.Paletted {
+		img := image.NewPaletted(r, palette)
+		draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+		return img
+	}
+
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			frame(image.Rect(0, 0, 20, 10), testRed),
+			frame(image.Rect(0, 0, 5, 5), testBlue),
+			frame(image.Rect(10, 5, 15, 10), testGreen),
+			frame(image.Rect(19, 0, 20, 1), testWhite),
+		},
+		Delay:     []int{10, 20, 30, 40},
+		Disposal:  []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalPrevious, gif.DisposalNone},
+		LoopCount: 3,
+		Config:    image.Config{Width: 20, Height: 10, ColorModel: palette},
+	}
+
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewAnimationFromGIF(t *testing.T) {
+	g, err := gif.DecodeAll(bytes.NewReader(encodeTestGIF(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	anim, err := NewAnimationFromGIF(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		frame int
+		x, y  int
+		want  color.RGBA
+	}{
+		{0, 0, 0, testRed},
+		{1, 0, 0, testBlue},
+		{1, 10, 0, testRed},
+		{2, 0, 0, color.RGBA{}}, // 第1帧区域恢复为背景
+		{2, 12, 7, testGreen},
+		{3, 12, 7, testRed}, // 第2帧区域恢复为上一帧
+		{3, 0, 0, color.RGBA{}},
+		{3, 19, 0, testWhite},
+	}
+	for _, tc := range tests {
+		if c := color.RGBAModel.Convert(anim.Frames[tc.frame].At(tc.x, tc.y)); c != tc.want {
+			t.Fatalf("第 %d 帧 (%d,%d) 期望 %v，实际 %v", tc.frame, tc.x, tc.y, tc.want, c)
+		}
+	}
+}
+
+func TestProcessAnimatedGIF(t *testing.T) {
+	data := encodeTestGIF(t)
+
+	output, err := ProcessImage(data, []Processor{NewZoomRatioProcessor(0.5)}, nil)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 4 || g.Config.Width != 10 || g.Config.Height != 5 {
+		t.Fatalf("期望 4 帧 10x5，实际 %d 帧 %dx%d", len(g.Image), g.Config.Width, g.Config.Height)
+	}
+	if !slices.Equal(g.Delay, []int{10, 20, 30, 40}) || g.LoopCount != 3 {
+		t.Fatalf("延迟或循环次数错误: %v %d", g.Delay, g.LoopCount)
+	}
+
+	anim, err := NewAnimationFromGIF(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		frame int
+		x, y  int
+		want  color.RGBA
+	}{
+		{1, 1, 1, testBlue},
+		{2, 6, 3, testGreen},
+		{3, 6, 3, testRed},
+		{3, 1, 1, color.RGBA{}},
+	} {
+		if c := color.RGBAModel.Convert(anim.Frames[tc.frame].At(tc.x, tc.y)); c != tc.want {
+			t.Fatalf("第 %d 帧 (%d,%d) 期望 %v，实际 %v", tc.frame, tc.x, tc.y, tc.want, c)
+		}
+	}
+
+	// 只处理第一帧
+	output, err = ProcessImage(data, nil, &ProcessorOptions{DisableAnimation: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, err := gif.DecodeAll(bytes.NewReader(output)); err != nil || len(g.Image) != 1 {
+		t.Fatalf("期望静态 GIF: %v", err)
+	}
+
+	// 帧数限制
+	var limitErr *LimitError
+	if _, err := ProcessImage(data, nil, &ProcessorOptions{MaxFrames: 3}); !errors.As(err, &limitErr) || limitErr.Kind != LimitFrames {
+		t.Fatalf("期望帧数超出限制，实际 %v", err)
+	}
+}
+
+func TestAnimationLimitBeforeDecode(t *testing.T) {
+	data := encodeTestGIF(t)
+	frames, canvas := scanGIF(data)
+	if frames != 4 || canvas != image.Rect(0, 0, 20, 10) {
+		t.Fatalf("期望 4 帧 20x10，实际 %d 帧 %v", frames, canvas)
+	}
+
+	// 4 帧 20x10 的动画合成后共 800 像素
+	var limitErr *LimitError
+	_, err := ProcessImage(data, nil, &ProcessorOptions{MaxAnimationPixels: 799})
+	if !errors.As(err, &limitErr) || limitErr.Kind != LimitAnimationPixels || limitErr.Value != 800 {
+		t.Fatalf("期望像素总数超出限制，实际 %v", err)
+	}
+	if _, err := ProcessImage(data, nil, &ProcessorOptions{MaxAnimationPixels: 800}); err != nil {
+		t.Fatalf("未超出限制时处理失败: %v", err)
+	}
+
+	// 去掉结束符后完整解码会失败，帧数限制在解码像素之前检查
+	truncated := data[:len(data)-1]
+	if _, err := ProcessImage(truncated, nil, &ProcessorOptions{}); err == nil {
+		t.Fatal("期望解码错误")
+	}
+	if _, err := ProcessImage(truncated, nil, &ProcessorOptions{MaxFrames: 3}); !errors.As(err, &limitErr) || limitErr.Kind != LimitFrames {
+		t.Fatalf("期望帧数超出限制，实际 %v", err)
+	}
+
+	// 帧区域超出逻辑屏幕时按并集计算画布
+	if frames, canvas := scanGIF(patchGIFScreen(data, 10, 5)); frames != 4 || canvas != image.Rect(0, 0, 20, 10) {
+		t.Fatalf("期望 4 帧 20x10，实际 %d 帧 %v", frames, canvas)
+	}
+	if frames, _ := scanGIF(data[:20]); frames != 0 {
+		t.Fatalf("期望 0 帧，实际 %d", frames)
+	}
+}
+
+// patchGIFScreen 修改 GIF 的逻辑屏幕尺寸
+func patchGIFScreen(data []byte, width, height int) []byte {
+	patched := bytes.Clone(data)
+	patched[6], patched[7] = byte(width), byte(width>>8)
+	patched[8], patched[9] = byte(height), byte(height>>8)
+	return patched
+}
+
+func TestProcessAnimationContentAware(t *testing.T) {
+	// 主体在第一帧左侧，第二帧右侧；按第一帧确定区域时第二帧的主体被切掉
+	frames := []image.Image{
+		subjectImage(400, 200, image.Rect(10, 60, 70, 120), testRed),
+		subjectImage(400, 200, image.Rect(320, 60, 380, 120), testRed),
+	}
+	anim := &Animation{Frames: frames, Delays: []int{10, 10}}
+
+	pipeline, err := NewPipeline(NewZoomRatioProcessor(1), NewSmartCutProcessor(200, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		processor Processor
+	}{
+		{"smart 切割", NewSmartCutProcessor(200, 200)},
+		{"smart 宽高比切割", NewCutAspectProcessor(1, CutPositionSmart)},
+		{"smart cover 缩放", NewZoomCoverProcessor(100, 100, CutPositionSmart)},
+		{"流水线中的 smart 切割", pipeline},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			processors := []Processor{tc.processor}
+			if alone, _ := Process(frames[1], processors); countColor(alone, testRed) == 0 {
+				t.Fatal("单独处理第二帧时期望保留主体")
+			}
+
+			result, err := ProcessAnimation(anim, processors)
+			if err != nil {
+				t.Fatalf("处理失败: %v", err)
+			}
+			if countColor(result.Frames[0], testRed) == 0 || countColor(result.Frames[1], testRed) > 0 {
+				t.Fatal("期望每一帧使用第一帧确定的区域")
+			}
+			// 处理器链本身不被修改，可以继续处理其他图片
+			if again, _ := Process(frames[1], processors); countColor(again, testRed) == 0 {
+				t.Fatal("处理动画后处理器链不应改变")
+			}
+		})
+	}
+
+	if _, err := ProcessAnimation(anim, []Processor{NewSeamCarveProcessor(300, 0)}); err == nil {
+		t.Fatal("期望接缝裁剪不支持动画")
+	}
+}
+
+func TestMedianCutQuantizer(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(img, image.Rect(0, 0, 64, 32), image.NewUniform(testRed), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 32, 64, 64), image.NewUniform(testBlue), image.Point{}, draw.Src)
+	img.Set(0, 0, testGreen)
+
+	// 颜色数不超过容量时使用原始颜色
+	palette := MedianCutQuantizer{}.Quantize(make(color.Palette, 0, 16), img)
+	if len(palette) != 3 || !slices.Contains(palette, color.Color(testGreen)) {
+		t.Fatalf("调色板错误: %v", palette)
+	}
+
+	// 渐变图片切分为指定颜色数
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: 128, A: 255})
+		}
+	}
+	palette = MedianCutQuantizer{}.Quantize(make(color.Palette, 0, 16), img)
+	if len(palette) != 16 {
+		t.Fatalf("期望 16 个颜色，实际 %d", len(palette))
+	}
+}
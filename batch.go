@@ -0,0 +1,250 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// BatchItem 批量处理的单个输入
+type BatchItem struct {
+	Name string // 输入名称，用于标识结果
+	Data []byte // 输入数据，为空时通过 Open 读取
+
+	// Open 打开输入数据，重试时会再次调用
+	Open func() (io.ReadCloser, error)
+
+	// Save 保存处理后的数据，在工作协程中调用，返回错误时按处理失败对待
+	// 为空时处理后的数据保存在 BatchResult.Output 中
+	Save func(output []byte, result *ProcessResult) error
+}
+
+// reader 打开输入数据
+func (item *BatchItem) reader() (io.ReadCloser, error) {
+	if item.Data != nil {
+		return io.NopCloser(bytes.NewReader(item.Data)), nil
+	}
+	if item.Open != nil {
+		return item.Open()
+	}
+	return nil, errors.New("没有输入数据")
+}
+
+// BatchResult 单个输入的处理结果
+type BatchResult struct {
+	Index    int            // 输入序号，从 0 开始
+	Item     BatchItem      // 输入
+	Output   []byte         // 处理后的数据，设置了 Item.Save 时为空
+	Result   *ProcessResult // 处理结果，失败时为空
+	Err      error          // 最后一次处理的错误
+	Attempts int            // 处理次数，包含重试
+	Duration time.Duration  // 所有处理次数的总耗时
+}
+
+// BatchProgress 批量处理进度
+type BatchProgress struct {
+	Total     int // 输入总数，输入数量未知时为 0
+	Done      int // 已完成数量，包含失败的输入
+	Succeeded int // 成功数量
+	Failed    int // 失败数量
+	Retries   int // 累计重试次数
+}
+
+// BatchAction 处理失败后的操作
+type BatchAction int
+
+const (
+	// BatchSkip 跳过失败的输入，继续处理其他输入
+	BatchSkip BatchAction = iota
+	// BatchRetry 重试失败的输入，重试次数不超过 Batch.MaxRetries
+	BatchRetry
+)
+
+// Batch 使用共享的处理器链并发处理多个输入
+// 单个输入失败不会中断整个批次，失败的输入按 OnError 重试或跳过。
+// Processors 被所有工作协程并发使用，内置处理器都满足并发要求。
+type Batch struct {
+	Processors []Processor       // 共享的处理器链
+	Options    *ProcessorOptions // 处理选项，为空时使用默认选项
+	Workers    int               // 并发数，0 表示 runtime.NumCPU()
+	MaxRetries int               // 每个输入失败后最多重试次数
+
+	// OnError 决定失败的输入是否重试，attempt 为已处理次数
+	// 为空时在 MaxRetries 范围内重试所有错误，ctx 取消或超时不会重试
+	OnError func(item BatchItem, err error, attempt int) BatchAction
+
+	// OnProgress 每完成一个输入调用一次，调用是串行的，可以直接输出日志
+	OnProgress func(progress BatchProgress, result *BatchResult)
+}
+
+// Run 并发处理 items 中的输入，每完成一个输入向返回的通道发送一个结果
+// items 关闭且所有输入处理完成后关闭结果通道，调用方需要读取结果直到通道关闭。
+// ctx 取消后不再读取新的输入，items 的发送方应同时监听 ctx.Done()。
+func (b *Batch) Run(ctx context.Context, items <-chan BatchItem) <-chan BatchResult {
+	return b.run(ctx, items, 0)
+}
+
+// RunItems 并发处理所有输入，按输入顺序返回结果
+// ctx 取消后未处理的输入结果为 ctx.Err()
+func (b *Batch) RunItems(ctx context.Context, items []BatchItem) []BatchResult {
+	ch := make(chan BatchItem)
+	go func() {
+		defer close(ch)
+		for _, item := range items {
+			select {
+			case ch <- item:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make([]BatchResult, len(items))
+	done := make([]bool, len(items))
+	for r := range b.run(ctx, ch, len(items)) {
+		results[r.Index] = r
+		done[r.Index] = true
+	}
+
+	for i := range results {
+		if !done[i] {
+			results[i] = BatchResult{Index: i, Item: items[i], Err: ctx.Err()}
+		}
+	}
+	return results
+}
+
+func (b *Batch) run(ctx context.Context, items <-chan BatchItem, total int) <-chan BatchResult {
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if total > 0 && workers > total {
+		workers = total
+	}
+
+	type indexedItem struct {
+		index int
+		item  BatchItem
+	}
+
+	// 分配输入序号，ctx 取消后停止读取
+	indexed := make(chan indexedItem)
+	go func() {
+		defer close(indexed)
+		for i := 0; ; i++ {
+			select {
+			case item, ok := <-items:
+				if !ok {
+					return
+				}
+				select {
+				case indexed <- indexedItem{index: i, item: item}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	results := make(chan BatchResult)
+	progress := BatchProgress{Total: total}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for in := range indexed {
+				r := b.process(ctx, in.index, in.item)
+
+				mu.Lock()
+				progress.Done++
+				progress.Retries += r.Attempts - 1
+				if r.Err != nil {
+					progress.Failed++
+				} else {
+					progress.Succeeded++
+				}
+				if b.OnProgress != nil {
+					b.OnProgress(progress, &r)
+				}
+				mu.Unlock()
+
+				results <- r
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// process 处理单个输入，失败时按 OnError 和 MaxRetries 重试
+func (b *Batch) process(ctx context.Context, index int, item BatchItem) (r BatchResult) {
+	r = BatchResult{Index: index, Item: item}
+	start := time.Now()
+	defer func() { r.Duration = time.Since(start) }()
+
+	for {
+		r.Attempts++
+		r.Output, r.Result, r.Err = b.attempt(ctx, &item)
+		if r.Err == nil || ctx.Err() != nil || r.Attempts > b.MaxRetries {
+			return r
+		}
+		if b.OnError != nil && b.OnError(item, r.Err, r.Attempts) != BatchRetry {
+			return r
+		}
+	}
+}
+
+// attempt 处理一次输入
+func (b *Batch) attempt(ctx context.Context, item *BatchItem) ([]byte, *ProcessResult, error) {
+	rc, err := item.reader()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	buf := new(bytes.Buffer)
+	result, err := ProcessReaderWithContext(ctx, rc, buf, b.Processors, b.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if item.Save != nil {
+		if err := item.Save(buf.Bytes(), result); err != nil {
+			return nil, nil, err
+		}
+		return nil, result, nil
+	}
+	return buf.Bytes(), result, nil
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestBatchRunItems(t *testing.T) {
+	good := encodeTestPNG(t, 40, 20)
+
+	// 第一次打开失败，重试后成功
+	var opens atomic.Int32
+	flaky := BatchItem{Name: "flaky", Open: func() (io.ReadCloser, error) {
+		if opens.Add(1) == 1 {
+			return nil, errors.New("暂时不可用")
+		}
+		return io.NopCloser(bytes.NewReader(good)), nil
+	}}
+
+	var saved atomic.Int32
+	items := []BatchItem{
+		{Name: "good", Data: good},
+		{Name: "bad", Data: []byte("not an image")},
+		flaky,
+		{Name: "saved", Data: good, Save: func(output []byte, result *ProcessResult) error {
+			if len(output) == 0 || result.Width != 10 {
+				return errors.New("输出错误")
+			}
+			saved.Add(1)
+			return nil
+		}},
+	}
+
+	var last BatchProgress
+	calls := 0
+	batch := &Batch{
+		Processors: []Processor{NewZoomWidthProcessor(10)},
+		Workers:    2,
+		MaxRetries: 2,
+		OnError: func(item BatchItem, err error, attempt int) BatchAction {
+			// 格式错误不重试
+			var formatErr *UnsupportedFormatError
+			if errors.As(err, &formatErr) {
+				return BatchSkip
+			}
+			return BatchRetry
+		},
+		OnProgress: func(progress BatchProgress, result *BatchResult) {
+			calls++
+			last = progress
+		},
+	}
+
+	results := batch.RunItems(context.Background(), items)
+
+	if calls != len(items) || last != (BatchProgress{Total: 4, Done: 4, Succeeded: 3, Failed: 1, Retries: 1}) {
+		t.Fatalf("进度错误: %d 次, %+v", calls, last)
+	}
+	for i, r := range results {
+		if r.Index != i || r.Item.Name != items[i].Name {
+			t.Fatalf("结果顺序错误: %d %s", r.Index, r.Item.Name)
+		}
+	}
+	if results[0].Err != nil || results[0].Result.Width != 10 || len(results[0].Output) == 0 {
+		t.Fatalf("处理失败: %+v", results[0])
+	}
+	if results[1].Err == nil || results[1].Attempts != 1 {
+		t.Fatalf("格式错误应跳过且不重试: %+v", results[1])
+	}
+	if results[2].Err != nil || results[2].Attempts != 2 {
+		t.Fatalf("重试后应成功: %+v", results[2])
+	}
+	if results[3].Err != nil || results[3].Output != nil || saved.Load() != 1 {
+		t.Fatalf("保存结果错误: %+v", results[3])
+	}
+}
+
+func TestBatchRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	items := make(chan BatchItem)
+	batch := &Batch{Processors: []Processor{NewZoomWidthProcessor(10)}}
+	for r := range batch.Run(ctx, items) {
+		t.Fatalf("取消后不应处理输入: %+v", r)
+	}
+
+	results := batch.RunItems(ctx, []BatchItem{{Name: "a", Data: encodeTestPNG(t, 4, 4)}})
+	if !errors.Is(results[0].Err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled，实际 %v", results[0].Err)
+	}
+}
+
+// TestBatchSharedFontFace 文本和水印处理器共享同一个包装后的 truetype 字体并发处理，结果与串行处理一致
+func TestBatchSharedFontFace(t *testing.T) {
+	ttf, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face := NewSyncFace(truetype.NewFace(ttf, &truetype.Options{Size: 18}))
+	if NewSyncFace(face) != face || NewSyncFace(basicfont.Face7x13) != basicfont.Face7x13 {
+		t.Fatal("已包装的字体和 basicfont 应原样返回")
+	}
+
+	text := NewTextProcessor(TextOptions{Text: "vimage 并发", Position: image.Pt(5, 30), Font: face})
+	watermark := NewWatermarkProcessor("watermark", 18, color.RGBA{R: 255, A: 255}, 0.8, "bottom-right", 15)
+	watermark.FontFace = face
+	processors := []Processor{text, watermark, NewMosaicProcessor([]*MosaicRegion{{ToX: 40, ToY: 40}}, 1, DirectionLeft)}
+
+	data := encodeTestPNG(t, 200, 100)
+	expected, err := ProcessImage(data, processors, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items := make([]BatchItem, 32)
+	for i := range items {
+		items[i] = BatchItem{Data: data}
+	}
+
+	batch := &Batch{Processors: processors, Workers: 8}
+	for _, r := range batch.RunItems(context.Background(), items) {
+		if r.Err != nil {
+			t.Fatalf("处理失败: %v", r.Err)
+		}
+		if !bytes.Equal(r.Output, expected) {
+			t.Fatalf("输入 %d 的并发处理结果与串行处理不一致", r.Index)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+)
+
+// boxBlur 对图片做盒式模糊，先水平后垂直，超出边界的像素使用边缘像素
+// 多次调用可以近似高斯模糊。
+func boxBlur(img *image.RGBA, radius int) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if radius <= 0 || w == 0 || h == 0 {
+		return
+	}
+
+	tmp := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		src := img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y+y):]
+		boxBlurLine(tmp.Pix[tmp.PixOffset(0, y):], src, w, 4, radius)
+	}
+	for x := 0; x < w; x++ {
+		dst := img.Pix[img.PixOffset(bounds.Min.X+x, bounds.Min.Y):]
+		boxBlurLine(dst, tmp.Pix[tmp.PixOffset(x, 0):], h, tmp.Stride, radius)
+	}
+}
+
+// boxBlurLine 对一行或一列的 n 个像素做盒式模糊，相邻像素间隔 step 字节
+func boxBlurLine(dst, src []uint8, n, step, radius int) {
+	size := 2*radius + 1
+	for c := 0; c < 4; c++ {
+		sum := 0
+		for i := -radius; i <= radius; i++ {
+			sum += int(src[min(max(i, 0), n-1)*step+c])
+		}
+		for i := 0; i < n; i++ {
+			dst[i*step+c] = uint8((sum + size/2) / size)
+			out := max(i-radius, 0)
+			in := min(i+radius+1, n-1)
+			sum += int(src[in*step+c]) - int(src[out*step+c])
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheBackend 缓存存储，可以被多个 goroutine 并发使用
+// Get 返回的数据由调用方共享，不能修改
+type CacheBackend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, data []byte) error
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Hits        int64 // 命中次数
+	Misses      int64 // 未命中并处理的次数
+	Shared      int64 // 等待其他相同请求处理结果的次数
+	Uncacheable int64 // 处理器链无法缓存、直接处理的次数
+	SetErrors   int64 // 写入缓存失败的次数
+}
+
+// Cache 处理结果缓存，键由输入数据、处理器链和处理选项计算，见 CacheKey
+// 并发的相同请求只处理一次，其他请求等待并共享结果。
+type Cache struct {
+	backend CacheBackend
+	group   flightGroup
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	shared      atomic.Int64
+	uncacheable atomic.Int64
+	setErrors   atomic.Int64
+}
+
+// NewCache 创建处理结果缓存
+func NewCache(backend CacheBackend) *Cache {
+	return &Cache{backend: backend}
+}
+
+// ProcessImage 与 ProcessImageWithContext 相同，结果已缓存时直接返回缓存的数据
+// 返回的数据可能与其他调用方共享，不能修改。处理器链无法缓存(ErrUncacheable)时直接处理。
+// 写入缓存失败不影响返回结果，只计入 CacheStats.SetErrors。
+func (c *Cache) ProcessImage(ctx context.Context, imgData []byte, processors []Processor,
+	options *ProcessorOptions,
+) ([]byte, error) {
+	key, err := CacheKey(imgData, processors, options)
+	if errors.Is(err, ErrUncacheable) {
+		c.uncacheable.Add(1)
+		return ProcessImageWithContext(ctx, imgData, processors, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := c.backend.Get(key); ok {
+		c.hits.Add(1)
+		return data, nil
+	}
+
+	data, shared, err := c.group.do(ctx, key, func() ([]byte, error) {
+		// 等待锁期间其他请求可能已写入缓存
+		if data, ok := c.backend.Get(key); ok {
+			c.hits.Add(1)
+			return data, nil
+		}
+
+		c.misses.Add(1)
+		data, err := ProcessImageWithContext(ctx, imgData, processors, options)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.backend.Set(key, data); err != nil {
+			c.setErrors.Add(1)
+		}
+		return data, nil
+	})
+	if !shared {
+		return data, err
+	}
+
+	c.shared.Add(1)
+	// 处理请求被取消时，ctx 仍然有效的等待者自己处理
+	if isContextError(err) && ctx.Err() == nil {
+		return c.ProcessImage(ctx, imgData, processors, options)
+	}
+	return data, err
+}
+
+// Stats 返回缓存统计
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Shared:      c.shared.Load(),
+		Uncacheable: c.uncacheable.Load(),
+		SetErrors:   c.setErrors.Load(),
+	}
+}
+
+// isContextError 是否为 ctx 取消或超时的错误
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// flightCall 正在进行的处理
+type flightCall struct {
+	done    chan struct{}
+	data    []byte
+	err     error
+	waiters int // 等待结果的调用数
+}
+
+// flightGroup 合并相同键的并发调用
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// waiting 返回正在等待其他调用结果的调用数
+func (g *flightGroup) waiting() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	n := 0
+	for _, call := range g.calls {
+		n += call.waiters
+	}
+	return n
+}
+
+// do 执行 fn，相同键正在执行时等待其结果，shared 表示结果来自其他调用
+// 等待期间 ctx 取消时返回 ctx.Err()
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) ([]byte, bool, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		call.waiters++
+		g.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.data, true, call.err
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	// fn panic 时等待者收到错误
+	call.err = errors.New("处理异常退出")
+	call.data, call.err = fn()
+	return call.data, false, call.err
+}
@@ -0,0 +1,194 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirCache 本地目录缓存，每个条目保存为一个文件，总字节数超过 maxBytes 时按最近使用顺序删除文件
+// 文件修改时间记录最近使用时间，重新打开目录时据此恢复使用顺序。
+// 同一个目录只能由一个 DirCache 使用。
+type DirCache struct {
+	dir string
+
+	mu  sync.Mutex
+	lru *byteLRU
+}
+
+// dirCacheEntry 目录中已有的缓存文件
+type dirCacheEntry struct {
+	key     string
+	size    int64
+	modTime time.Time
+}
+
+// NewDirCache 创建目录缓存，目录不存在时创建，已有的缓存文件会被加载，超过 maxBytes 时删除最久未使用的文件
+func NewDirCache(dir string, maxBytes int64) (*DirCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var entries []dirCacheEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !validCacheKey(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries = append(entries, dirCacheEntry{key: d.Name(), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("加载缓存目录失败: %w", err)
+	}
+
+	// 从新到旧加入列表，最新的在前面
+	slices.SortFunc(entries, func(a, b dirCacheEntry) int {
+		return b.modTime.Compare(a.modTime)
+	})
+
+	c := &DirCache{dir: dir, lru: newByteLRU(maxBytes)}
+	for _, entry := range entries {
+		c.lru.pushBack(entry.key, entry.size)
+	}
+	c.removeFiles(c.lru.evict())
+
+	return c, nil
+}
+
+// Get 实现 CacheBackend 接口，读取失败时视为未命中
+func (c *DirCache) Get(key string) ([]byte, bool) {
+	if !validCacheKey(key) {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	_, ok := c.lru.get(key)
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// 文件被外部删除或者同时被淘汰
+		c.mu.Lock()
+		c.lru.remove(key)
+		c.mu.Unlock()
+		return nil, false
+	}
+
+	// 记录使用时间，重新打开目录时保持使用顺序
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Set 实现 CacheBackend 接口，先写入临时文件再重命名，读取时不会得到不完整的数据
+// 超过 maxBytes 的数据不缓存，并删除该键已有的旧文件
+func (c *DirCache) Set(key string, data []byte) error {
+	if !validCacheKey(key) {
+		return fmt.Errorf("无效的缓存键: %q", key)
+	}
+
+	path := c.path(key)
+	if int64(len(data)) > c.lru.maxBytes {
+		c.mu.Lock()
+		c.lru.remove(key)
+		c.mu.Unlock()
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	c.lru.add(key, int64(len(data)), nil)
+	evicted := c.lru.evict()
+	c.mu.Unlock()
+
+	c.removeFiles(evicted)
+	return nil
+}
+
+// Size 返回缓存文件的总字节数
+func (c *DirCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.size
+}
+
+// path 返回缓存文件路径，使用键的前两个字符作为子目录，避免单个目录文件过多
+func (c *DirCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+// removeFiles 删除被淘汰的缓存文件
+func (c *DirCache) removeFiles(entries []*lruEntry) {
+	for _, entry := range entries {
+		_ = os.Remove(c.path(entry.key))
+	}
+}
+
+// validCacheKey 缓存键只能包含小写字母和数字，且至少3个字符，可以直接作为文件名
+func validCacheKey(key string) bool {
+	if len(key) < 3 {
+		return false
+	}
+	return strings.IndexFunc(key, func(r rune) bool {
+		return (r < '0' || r > '9') && (r < 'a' || r > 'z')
+	}) < 0
+}
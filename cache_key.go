@@ -0,0 +1,334 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+)
+
+// cacheKeyVersion 缓存键格式版本，序列化格式变化时修改
+const cacheKeyVersion = "vimage-cache-v1"
+
+// ErrUncacheable 处理器链或选项无法生成稳定的缓存键，例如包含随机干扰或可变状态的字体
+var ErrUncacheable = errors.New("处理器链无法缓存")
+
+// CacheKeyer 自定义处理器的缓存键
+// 处理器默认按字段值生成缓存键，包含无法序列化的字段(如闭包、通道)时可以实现此接口；
+// 处理结果不确定时返回 ErrUncacheable。
+type CacheKeyer interface {
+	CacheKey() (string, error)
+}
+
+var (
+	cacheKeyerType = reflect.TypeFor[CacheKeyer]()
+	fontFaceType   = reflect.TypeFor[font.Face]()
+	basicFaceType  = reflect.TypeFor[*basicfont.Face]()
+	pipelineType   = reflect.TypeFor[*Pipeline]()
+	scalerType     = reflect.TypeFor[draw.Scaler]()
+)
+
+// CacheKey 计算处理结果的缓存键，由输入数据的哈希、处理器链和处理选项的规范序列化组成
+// 处理器按类型和字段值序列化，字段相同的两个处理器链得到相同的键，与处理器实例无关。
+// 包含 NoiseProcessor、非 basicfont 字体或其他无法序列化的字段时返回 ErrUncacheable。
+// options 中的 Observer 不影响缓存键；水印使用的 SetDefaultFont 默认字体按名称、版本和度量计入缓存键。
+func CacheKey(imgData []byte, processors []Processor, options *ProcessorOptions) (string, error) {
+	if options == nil {
+		options = &DefaultProcessorOptions
+	}
+	opts := *options
+	opts.Observer = nil
+
+	input := sha256.Sum256(imgData)
+
+	h := sha256.New()
+	e := &canonicalEncoder{w: h, seen: make(map[seenPointer]int)}
+	e.writeString(cacheKeyVersion)
+	e.writeString(fontIdentity(defaultFont))
+	e.writeBytes(input[:])
+	e.writeInt(int64(len(processors)))
+	for i, p := range processors {
+		if err := e.encode(reflect.ValueOf(p)); err != nil {
+			return "", fmt.Errorf("步骤 %d (%s): %w", i, processorName(p), err)
+		}
+	}
+	if err := e.encode(reflect.ValueOf(opts)); err != nil {
+		return "", fmt.Errorf("处理选项: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// seenPointer 已序列化的指针，类型不同的指针可能地址相同
+type seenPointer struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+// canonicalEncoder 将值按类型和字段序列化到哈希中
+// 每个值都带有类型或长度前缀，不同的值不会得到相同的序列化结果
+type canonicalEncoder struct {
+	w    io.Writer
+	seen map[seenPointer]int // 已序列化的指针及其序号，重复出现时只写入序号，同时避免循环引用
+}
+
+func (e *canonicalEncoder) writeInt(v int64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(v))
+	_, _ = e.w.Write(buf[:])
+}
+
+func (e *canonicalEncoder) writeBytes(b []byte) {
+	e.writeInt(int64(len(b)))
+	_, _ = e.w.Write(b)
+}
+
+func (e *canonicalEncoder) writeString(s string) {
+	e.writeInt(int64(len(s)))
+	_, _ = io.WriteString(e.w, s)
+}
+
+func (e *canonicalEncoder) encode(v reflect.Value) error {
+	if !v.IsValid() {
+		e.writeString("nil")
+		return nil
+	}
+
+	t := v.Type()
+	e.writeString(t.String())
+
+	if t.Implements(cacheKeyerType) {
+		return e.encodeKeyer(v)
+	}
+	if t.Kind() != reflect.Interface && t.Implements(fontFaceType) && t != basicFaceType {
+		// 字体内部包含绘制时修改的缓存，无法稳定序列化；接口类型的字段按实际的值判断，未设置字体时可以缓存
+		return ErrUncacheable
+	}
+	if t.Implements(scalerType) {
+		if name, ok := scalerName(v); ok {
+			e.writeString(name)
+			return nil
+		}
+	}
+	if t == pipelineType && v.CanInterface() && !v.IsNil() {
+		return e.encodePipeline(v.Interface().(*Pipeline))
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			e.writeInt(1)
+		} else {
+			e.writeInt(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.writeInt(int64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		e.writeInt(int64(math.Float64bits(v.Float())))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		e.writeInt(int64(math.Float64bits(real(c))))
+		e.writeInt(int64(math.Float64bits(imag(c))))
+	case reflect.String:
+		e.writeString(v.String())
+	case reflect.Pointer:
+		return e.encodePointer(v)
+	case reflect.Interface:
+		if v.IsNil() {
+			e.writeString("nil")
+			return nil
+		}
+		return e.encode(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			e.writeString(t.Field(i).Name)
+			if err := e.encode(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			e.writeString("nil")
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			e.writeBytes(v.Bytes())
+			return nil
+		}
+		return e.encodeElems(v)
+	case reflect.Array:
+		return e.encodeElems(v)
+	case reflect.Map:
+		return e.encodeMap(v)
+	case reflect.Func:
+		return e.encodeFunc(v)
+	default:
+		// Chan、UnsafePointer
+		return ErrUncacheable
+	}
+	return nil
+}
+
+// encodeKeyer 使用处理器自定义的缓存键
+func (e *canonicalEncoder) encodeKeyer(v reflect.Value) error {
+	if !v.CanInterface() {
+		// 未导出字段中的值无法调用方法，不能确定其缓存键
+		return ErrUncacheable
+	}
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		e.writeString("nil")
+		return nil
+	}
+
+	key, err := v.Interface().(CacheKeyer).CacheKey()
+	if err != nil {
+		return err
+	}
+	e.writeString(key)
+	return nil
+}
+
+// encodePipeline 序列化流水线的步骤
+func (e *canonicalEncoder) encodePipeline(p *Pipeline) error {
+	e.writeInt(int64(len(p.steps)))
+	for i, step := range p.steps {
+		var s any = step.processor
+		if step.contextProcessor != nil {
+			s = step.contextProcessor
+		}
+		if err := e.encode(reflect.ValueOf(s)); err != nil {
+			return fmt.Errorf("流水线步骤 %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (e *canonicalEncoder) encodePointer(v reflect.Value) error {
+	if v.IsNil() {
+		e.writeString("nil")
+		return nil
+	}
+
+	key := seenPointer{typ: v.Type(), addr: v.Pointer()}
+	if index, ok := e.seen[key]; ok {
+		e.writeString("ref")
+		e.writeInt(int64(index))
+		return nil
+	}
+	e.seen[key] = len(e.seen)
+
+	return e.encode(v.Elem())
+}
+
+func (e *canonicalEncoder) encodeElems(v reflect.Value) error {
+	e.writeInt(int64(v.Len()))
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encode(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeMap 按键的序列化结果排序，使结果与遍历顺序无关
+func (e *canonicalEncoder) encodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		e.writeString("nil")
+		return nil
+	}
+
+	type entry struct {
+		key, value []byte
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		var kb, vb bytes.Buffer
+		// 每个键值使用独立的指针序号，序号与遍历顺序无关
+		ke := &canonicalEncoder{w: &kb, seen: maps.Clone(e.seen)}
+		if err := ke.encode(iter.Key()); err != nil {
+			return err
+		}
+		ve := &canonicalEncoder{w: &vb, seen: maps.Clone(e.seen)}
+		if err := ve.encode(iter.Value()); err != nil {
+			return err
+		}
+		entries = append(entries, entry{key: kb.Bytes(), value: vb.Bytes()})
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		return bytes.Compare(a.key, b.key)
+	})
+
+	e.writeInt(int64(len(entries)))
+	for _, en := range entries {
+		e.writeBytes(en.key)
+		e.writeBytes(en.value)
+	}
+	return nil
+}
+
+// encodeFunc 使用函数名序列化，闭包和方法值可能捕获了状态，无法缓存
+func (e *canonicalEncoder) encodeFunc(v reflect.Value) error {
+	if v.IsNil() {
+		e.writeString("nil")
+		return nil
+	}
+
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return ErrUncacheable
+	}
+	name := fn.Name()
+	if strings.Contains(name, ".func") || strings.HasSuffix(name, "-fm") {
+		return ErrUncacheable
+	}
+	e.writeString(name)
+	return nil
+}
+
+// scalerName 返回内置缩放算法的名称，内置的 draw.Kernel 包含匿名函数，不能按字段序列化
+func scalerName(v reflect.Value) (string, bool) {
+	for name, scaler := range scalerNames {
+		sv := reflect.ValueOf(scaler)
+		if sv.Type() != v.Type() {
+			continue
+		}
+		if sv.Kind() != reflect.Pointer || sv.Pointer() == v.Pointer() {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,156 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryCache 内存缓存，按最近使用顺序淘汰，总字节数不超过 maxBytes
+type MemoryCache struct {
+	mu  sync.Mutex
+	lru *byteLRU
+}
+
+// NewMemoryCache 创建内存缓存，maxBytes 为缓存数据的最大总字节数
+func NewMemoryCache(maxBytes int64) *MemoryCache {
+	return &MemoryCache{lru: newByteLRU(maxBytes)}
+}
+
+// Get 实现 CacheBackend 接口
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.lru.get(key)
+	if !ok {
+		return nil, false
+	}
+	return entry.data, true
+}
+
+// Set 实现 CacheBackend 接口，超过 maxBytes 的数据不缓存，并删除该键已有的旧数据
+// 缓存保存 data 本身，调用方写入后不能再修改 data
+func (c *MemoryCache) Set(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if int64(len(data)) > c.lru.maxBytes {
+		c.lru.remove(key)
+		return nil
+	}
+	c.lru.add(key, int64(len(data)), data)
+	c.lru.evict()
+	return nil
+}
+
+// Len 返回缓存的条目数
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.ll.Len()
+}
+
+// Size 返回缓存数据的总字节数
+func (c *MemoryCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.size
+}
+
+// lruEntry 缓存条目，目录缓存中 data 为空
+type lruEntry struct {
+	key  string
+	size int64
+	data []byte
+}
+
+// byteLRU 按字节数限制的最近最少使用列表，不是并发安全的
+type byteLRU struct {
+	maxBytes int64
+	size     int64
+	ll       *list.List // 最近使用的在前面
+	items    map[string]*list.Element
+}
+
+func newByteLRU(maxBytes int64) *byteLRU {
+	return &byteLRU{
+		maxBytes: maxBytes,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get 返回条目并标记为最近使用
+func (l *byteLRU) get(key string) (*lruEntry, bool) {
+	elem, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	l.ll.MoveToFront(elem)
+	return elem.Value.(*lruEntry), true
+}
+
+// add 添加或替换条目，标记为最近使用
+func (l *byteLRU) add(key string, size int64, data []byte) {
+	if elem, ok := l.items[key]; ok {
+		entry := elem.Value.(*lruEntry)
+		l.size += size - entry.size
+		entry.size = size
+		entry.data = data
+		l.ll.MoveToFront(elem)
+		return
+	}
+
+	l.items[key] = l.ll.PushFront(&lruEntry{key: key, size: size, data: data})
+	l.size += size
+}
+
+// pushBack 添加最久未使用的条目，用于按修改时间从新到旧加载已有数据
+func (l *byteLRU) pushBack(key string, size int64) {
+	if _, ok := l.items[key]; ok {
+		return
+	}
+	l.items[key] = l.ll.PushBack(&lruEntry{key: key, size: size})
+	l.size += size
+}
+
+// remove 删除条目
+func (l *byteLRU) remove(key string) {
+	elem, ok := l.items[key]
+	if !ok {
+		return
+	}
+	l.ll.Remove(elem)
+	delete(l.items, key)
+	l.size -= elem.Value.(*lruEntry).size
+}
+
+// evict 淘汰最久未使用的条目直到总字节数不超过限制，返回被淘汰的条目
+func (l *byteLRU) evict() []*lruEntry {
+	var evicted []*lruEntry
+	for l.size > l.maxBytes && l.ll.Len() > 0 {
+		entry := l.ll.Back().Value.(*lruEntry)
+		l.remove(entry.key)
+		evicted = append(evicted, entry)
+	}
+	return evicted
+}
@@ -0,0 +1,259 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+func TestCacheKey(t *testing.T) {
+	data := encodeTestPNG(t, 20, 10)
+	key := func(processors []Processor, options *ProcessorOptions) string {
+		t.Helper()
+		k, err := CacheKey(data, processors, options)
+		if err != nil {
+			t.Fatalf("计算缓存键失败: %v", err)
+		}
+		return k
+	}
+
+	base := key([]Processor{NewZoomWidthProcessor(10), NewCutSquareProcessor("center")}, nil)
+	if k := key([]Processor{NewZoomWidthProcessor(10), NewCutSquareProcessor("center")}, nil); k != base {
+		t.Fatal("相同的处理器链应得到相同的缓存键")
+	}
+	if k := key(nil, &ProcessorOptions{Quality: 90, Observer: NewStatsCollector(0)}); k != key(nil, nil) {
+		t.Fatal("Observer 不应影响缓存键")
+	}
+
+	different := [][]Processor{
+		{NewZoomWidthProcessor(11), NewCutSquareProcessor("center")},
+		{NewZoomWidthProcessor(10), NewCutSquareProcessor("top")},
+		{NewZoomWidthProcessor(10).WithScaler(draw.CatmullRom), NewCutSquareProcessor("center")},
+		{NewZoomWidthProcessor(10)},
+	}
+	for i, processors := range different {
+		if key(processors, nil) == base {
+			t.Fatalf("处理器链 %d 不应与基准得到相同的缓存键", i)
+		}
+	}
+	if key(nil, &ProcessorOptions{Quality: 80}) == key(nil, nil) {
+		t.Fatal("不同的处理选项应得到不同的缓存键")
+	}
+	if k, _ := CacheKey(encodeTestPNG(t, 20, 11), nil, nil); k == key(nil, nil) {
+		t.Fatal("不同的输入应得到不同的缓存键")
+	}
+
+	ttf, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bold, err := truetype.Parse(gobold.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 水印使用默认字体，修改默认字体后缓存键不同
+	previous := defaultFont
+	defer SetDefaultFont(previous)
+	watermark := []Processor{NewWatermarkProcessor("a", 12, color.RGBA{A: 255}, 1, "center", 0)}
+	SetDefaultFont(ttf)
+	regularKey := key(watermark, nil)
+	SetDefaultFont(bold)
+	if key(watermark, nil) == regularKey {
+		t.Fatal("不同的默认字体应得到不同的缓存键")
+	}
+
+	face := truetype.NewFace(ttf, &truetype.Options{Size: 12})
+	pipeline, _ := NewPipeline(NewZoomWidthProcessor(10), NewNoiseProcessor(1, 1, color.RGBA{}, color.RGBA{}))
+	for _, processors := range [][]Processor{
+		{NewNoiseProcessor(1, 1, color.RGBA{}, color.RGBA{})},
+		{NewTextProcessor(TextOptions{Text: "a", Font: face, Color: color.Black})},
+		{pipeline},
+	} {
+		if _, err := CacheKey(data, processors, nil); !errors.Is(err, ErrUncacheable) {
+			t.Fatalf("期望 ErrUncacheable，实际 %v", err)
+		}
+	}
+}
+
+// slowProcessor 记录处理次数，处理前等待 release 关闭
+type slowProcessor struct {
+	calls   *atomic.Int32
+	release chan struct{}
+}
+
+func (p *slowProcessor) Process(img image.Image) (image.Image, error) {
+	p.calls.Add(1)
+	<-p.release
+	return img, nil
+}
+
+func (p *slowProcessor) CacheKey() (string, error) {
+	return "slow", nil
+}
+
+func TestCacheProcessImage(t *testing.T) {
+	cache := NewCache(NewMemoryCache(1 << 20))
+	data := encodeTestPNG(t, 20, 10)
+	calls := &atomic.Int32{}
+	processors := []Processor{&slowProcessor{calls: calls, release: make(chan struct{})}}
+
+	const n = 8
+	results := make([][]byte, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = cache.ProcessImage(context.Background(), data, processors, nil)
+		}(i)
+	}
+
+	// 等待所有请求进入缓存，第一个请求处理时其他请求应等待
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.group.waiting() < n-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(processors[0].(*slowProcessor).release)
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil || !bytes.Equal(results[i], results[0]) {
+			t.Fatalf("请求 %d 结果错误: %v", i, errs[i])
+		}
+	}
+	if c := calls.Load(); c != 1 {
+		t.Fatalf("并发的相同请求应只处理一次，实际 %d 次", c)
+	}
+
+	if _, err := cache.ProcessImage(context.Background(), data, processors, nil); err != nil {
+		t.Fatal(err)
+	}
+	stats := cache.Stats()
+	if calls.Load() != 1 || stats.Misses != 1 || stats.Shared != n-1 || stats.Hits != 1 {
+		t.Fatalf("缓存统计错误: %+v, 处理 %d 次", stats, calls.Load())
+	}
+
+	// 无法缓存的处理器链直接处理
+	noise := []Processor{NewNoiseProcessor(1, 1, color.RGBA{}, color.RGBA{})}
+	if _, err := cache.ProcessImage(context.Background(), data, noise, nil); err != nil || cache.Stats().Uncacheable != 1 {
+		t.Fatalf("无法缓存的处理器链处理错误: %v %+v", err, cache.Stats())
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache(10)
+	_ = cache.Set("a", []byte("1111"))
+	_ = cache.Set("b", []byte("2222"))
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a 应已缓存")
+	}
+
+	// 超出限制时淘汰最久未使用的 b
+	_ = cache.Set("c", []byte("3333"))
+	if _, ok := cache.Get("b"); ok {
+		t.Fatal("b 应被淘汰")
+	}
+	if cache.Len() != 2 || cache.Size() != 8 {
+		t.Fatalf("缓存大小错误: %d %d", cache.Len(), cache.Size())
+	}
+
+	// 超过限制的数据不缓存，不淘汰其他条目，并删除同一个键的旧数据
+	_ = cache.Set("d", make([]byte, 11))
+	if _, ok := cache.Get("d"); ok || cache.Len() != 2 || cache.Size() != 8 {
+		t.Fatalf("超过限制的数据不应缓存: %d %d", cache.Len(), cache.Size())
+	}
+	_ = cache.Set("c", make([]byte, 11))
+	if _, ok := cache.Get("c"); ok || cache.Len() != 1 || cache.Size() != 4 {
+		t.Fatalf("旧数据应删除: %d %d", cache.Len(), cache.Size())
+	}
+}
+
+func TestDirCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDirCache(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"aaa1", "bbb2", "ccc3"}
+	for i, key := range keys {
+		if err := cache.Set(key, bytes.Repeat([]byte{byte(i)}, 40)); err != nil {
+			t.Fatalf("写入缓存失败: %v", err)
+		}
+		// 修改时间精度可能较低，明确设置使用顺序
+		mt := time.Now().Add(time.Duration(i-len(keys)) * time.Minute)
+		_ = os.Chtimes(filepath.Join(dir, key[:2], key), mt, mt)
+	}
+
+	// 超出 100 字节时删除最久未使用的文件
+	if _, ok := cache.Get("aaa1"); ok {
+		t.Fatal("aaa1 应被淘汰")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "aa", "aaa1")); !os.IsNotExist(err) {
+		t.Fatalf("被淘汰的文件应删除: %v", err)
+	}
+	if data, ok := cache.Get("ccc3"); !ok || len(data) != 40 || data[0] != 2 {
+		t.Fatal("ccc3 应已缓存")
+	}
+	if err := cache.Set("../x", nil); err == nil {
+		t.Fatal("无效的缓存键应返回错误")
+	}
+
+	// 重新打开时按修改时间恢复使用顺序，ccc3 刚被读取
+	reopened, err := NewDirCache(dir, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("bbb2"); ok {
+		t.Fatal("bbb2 应被淘汰")
+	}
+	if _, ok := reopened.Get("ccc3"); !ok || reopened.Size() != 40 {
+		t.Fatalf("ccc3 应保留，缓存大小 %d", reopened.Size())
+	}
+
+	// 超过限制的数据不缓存，不淘汰其他文件，并删除同一个键的旧文件
+	if err := reopened.Set("ddd4", make([]byte, 51)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("ddd4"); ok || reopened.Size() != 40 {
+		t.Fatalf("超过限制的数据不应缓存，缓存大小 %d", reopened.Size())
+	}
+	if err := reopened.Set("ccc3", make([]byte, 51)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cc", "ccc3")); !os.IsNotExist(err) || reopened.Size() != 0 {
+		t.Fatalf("旧文件应删除: %v，缓存大小 %d", err, reopened.Size())
+	}
+}
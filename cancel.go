@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"image"
+)
+
+// cancelCheckRows 像素循环中每处理多少行检查一次是否已取消
+const cancelCheckRows = 64
+
+// CancelableProcessor 支持通过 context 取消的处理器
+// 耗时的像素循环会定期检查 ctx，取消或超时后返回 ctx.Err()
+type CancelableProcessor interface {
+	Processor
+	ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error)
+}
+
+// CancelableContextProcessor 支持通过 context 取消的上下文处理器
+type CancelableContextProcessor interface {
+	ContextProcessor
+	ContextProcessWithContext(ctx context.Context, pctx *ImageProcessContext) error
+}
+
+// AsCancelable 将处理器转换为 CancelableProcessor
+// 未实现 CancelableProcessor 的处理器只在处理前后检查 ctx
+func AsCancelable(p Processor) CancelableProcessor {
+	if cp, ok := p.(CancelableProcessor); ok {
+		return cp
+	}
+	return &cancelableProcessor{Processor: p}
+}
+
+// AsCancelableContext 将上下文处理器转换为 CancelableContextProcessor
+// 未实现 CancelableContextProcessor 的处理器只在处理前后检查 ctx
+func AsCancelableContext(p ContextProcessor) CancelableContextProcessor {
+	if cp, ok := p.(CancelableContextProcessor); ok {
+		return cp
+	}
+	return &cancelableContextProcessor{ContextProcessor: p}
+}
+
+// cancelableProcessor 在处理前后检查 ctx 的处理器适配器
+type cancelableProcessor struct {
+	Processor
+}
+
+func (p *cancelableProcessor) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	result, err := p.Process(img)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// cancelableContextProcessor 在处理前后检查 ctx 的上下文处理器适配器
+type cancelableContextProcessor struct {
+	ContextProcessor
+}
+
+func (p *cancelableContextProcessor) ContextProcessWithContext(ctx context.Context, pctx *ImageProcessContext) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := p.ContextProcess(pctx); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// checkCanceled 每 cancelCheckRows 行检查一次 ctx 是否已取消
+func checkCanceled(ctx context.Context, row int) error {
+	if row%cancelCheckRows != 0 {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,86 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// cancelingProcessor 处理时取消 ctx，用于模拟处理过程中客户端断开
+type cancelingProcessor struct {
+	cancel context.CancelFunc
+}
+
+func (p *cancelingProcessor) Process(img image.Image) (image.Image, error) {
+	p.cancel()
+	return img, nil
+}
+
+func TestCancelableProcessors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	img := image.NewRGBA(image.Rect(0, 0, 200, 200))
+	processors := map[string]CancelableProcessor{
+		"马赛克": NewMosaicProcessor([]*MosaicRegion{{FromX: 0, FromY: 0, ToX: 100, ToY: 100}}, 1, DirectionLeft),
+		"圆角":  NewRoundedCornerProcessor(20),
+		"圆形":  NewCutCircleProcessor(),
+		"叠加":  NewOverlayProcessor(image.NewRGBA(image.Rect(0, 0, 50, 50)), 0, 0, 0.5, 1),
+		"适配器": AsCancelable(NewZoomWidthProcessor(100)),
+	}
+
+	for name, p := range processors {
+		t.Run(name, func(t *testing.T) {
+			if _, err := p.ProcessWithContext(ctx, img); !errors.Is(err, context.Canceled) {
+				t.Fatalf("期望 context.Canceled，实际 %v", err)
+			}
+			// 未取消时正常处理
+			if _, err := p.ProcessWithContext(context.Background(), img); err != nil {
+				t.Fatalf("处理失败: %v", err)
+			}
+		})
+	}
+
+	rect := NewDrawRectProcessor(image.Rect(0, 0, 10, 10), color.Black, true)
+	if _, err := ContextProcessWithContext(ctx, img, []ContextProcessor{rect}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled，实际 %v", err)
+	}
+}
+
+func TestProcessImageWithContext(t *testing.T) {
+	data := encodeTestPNG(t, 40, 20)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 处理过程中取消，后续处理器不再执行
+	next := &countingProcessor{size: 10}
+	_, err := ProcessImageWithContext(ctx, data, []Processor{&cancelingProcessor{cancel: cancel}, next}, nil)
+	if !errors.Is(err, context.Canceled) || next.called {
+		t.Fatalf("期望取消后停止处理，实际 %v, called=%v", err, next.called)
+	}
+
+	out, err := ProcessImageWithContext(context.Background(), data, []Processor{NewRoundedCornerProcessor(5)}, nil)
+	if err != nil || len(out) == 0 {
+		t.Fatalf("处理失败: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// vimage-server 图片处理HTTP服务，从本地目录读取源图片并按URL参数实时处理
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vogo/vimage"
+)
+
+func main() {
+	var config Config
+
+	addr := flag.String("addr", ":8080", "监听地址")
+	flag.StringVar(&config.Root, "root", ".", "源图片目录")
+	flag.Int64Var(&config.MaxBytes, "max-bytes", 20<<20, "源图片最大字节数，0 表示不限制")
+	flag.Int64Var(&config.MaxPixels, "max-pixels", 50_000_000, "源图片和每个处理步骤输出的最大像素数，0 表示不限制")
+	flag.IntVar(&config.MaxFrames, "max-frames", 500, "GIF 动画最大帧数，0 表示不限制")
+	flag.Int64Var(&config.MaxAnimationPixels, "max-animation-pixels", 200_000_000,
+		"GIF 动画合成后所有帧的最大像素总数，即帧数乘以画布像素数，0 表示不限制")
+	flag.DurationVar(&config.Timeout, "timeout", 10*time.Second, "单次处理超时时间，0 表示不限制")
+	flag.DurationVar(&config.MaxAge, "max-age", 24*time.Hour, "响应的 Cache-Control max-age")
+	flag.IntVar(&config.Concurrency, "concurrency", 0, "最大并发处理数，0 表示不限制")
+	signKeys := flag.String("sign-keys", os.Getenv("VIMAGE_SIGN_KEYS"),
+		"签名密钥，格式为 id1=hex密钥,id2=hex密钥，第一个用于签名，默认读取环境变量 VIMAGE_SIGN_KEYS")
+	flag.Parse()
+
+	if *signKeys != "" {
+		signer, err := parseSigningKeys(*signKeys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.Signer = signer
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           NewServer(config),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("vimage-server listening on %s, root: %s", *addr, config.Root)
+	log.Fatal(server.ListenAndServe())
+}
+
+// parseSigningKeys 解析签名密钥配置
+func parseSigningKeys(s string) (*vimage.URLSigner, error) {
+	var keys []vimage.SigningKey
+	for _, item := range strings.Split(s, ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("无效的签名密钥配置: %s", item)
+		}
+		b, err := hex.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("签名密钥 %s 不是有效的十六进制: %w", id, err)
+		}
+		keys = append(keys, vimage.SigningKey{ID: id, Secret: b})
+	}
+	return vimage.NewURLSigner(keys...)
+}
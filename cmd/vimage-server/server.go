@@ -0,0 +1,334 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vogo/vimage"
+)
+
+// errProcessPanic 图片处理过程中发生 panic
+var errProcessPanic = errors.New("图片处理异常")
+
+// Config 图片服务配置
+type Config struct {
+	Root               string        // 源图片目录
+	MaxBytes           int64         // 源图片最大字节数，0 表示不限制
+	MaxPixels          int64         // 源图片和每个处理步骤输出的最大像素数，0 表示不限制
+	MaxFrames          int           // GIF 动画最大帧数，0 表示不限制
+	MaxAnimationPixels int64         // GIF 动画合成后所有帧的最大像素总数，0 表示不限制
+	Timeout            time.Duration // 单次处理超时时间，0 表示不限制
+	MaxAge             time.Duration // 响应的 Cache-Control max-age
+	Concurrency        int           // 最大并发处理数，0 表示不限制
+
+	// 签名器，不为空时所有请求都必须带有效签名
+	Signer *vimage.URLSigner
+}
+
+// Server 图片处理HTTP服务
+//
+// 请求路径格式为 /<处理参数>/<源图片路径>，例如 /rs:width:300/q:80/photo.jpg，
+// 也可以通过查询参数 o 指定处理参数，例如 /photo.jpg?o=rs:width:300/q:80
+//
+// 配置了签名器时，请求路径需要以签名片段开头，例如 /s:k1:0:<签名>/rs:width:300/photo.jpg，
+// 使用查询参数时签名内容为 <路径>?o=<转义后的处理参数>
+type Server struct {
+	config Config
+	sem    chan struct{}
+}
+
+// NewServer 创建图片处理服务
+func NewServer(config Config) *Server {
+	s := &Server{config: config}
+	if config.Concurrency > 0 {
+		s.sem = make(chan struct{}, config.Concurrency)
+	}
+	return s
+}
+
+// ServeHTTP 实现 http.Handler 接口
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	escapedPath := r.URL.EscapedPath()
+	query := r.URL.Query().Get("o")
+
+	// 先校验签名，签名无效时不解析任何处理参数
+	if s.config.Signer != nil {
+		target := escapedPath
+		if query != "" {
+			target += "?o=" + url.QueryEscape(query)
+		}
+		unsigned, err := s.config.Signer.Verify(target, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		escapedPath, _, _ = strings.Cut(unsigned, "?")
+	}
+
+	opts, source, err := parseRequest(escapedPath, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	srcPath, err := s.resolve(source)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	info, err := os.Stat(srcPath)
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	if s.config.MaxBytes > 0 && info.Size() > s.config.MaxBytes {
+		http.Error(w, "source image too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// 叠加图像同样限制在源图片目录内，并在加载前检查大小
+	infos := []os.FileInfo{info}
+	for i := range opts.Steps {
+		if opts.Steps[i].Type != vimage.StepOverlay {
+			continue
+		}
+		if opts.Steps[i].Image, err = s.resolve(opts.Steps[i].Image); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overlayInfo, status, err := s.checkOverlay(opts.Steps[i].Image)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		infos = append(infos, overlayInfo)
+	}
+
+	etag := s.etag(escapedPath, query, infos)
+	if match := r.Header.Get("If-None-Match"); match != "" && etagMatch(match, etag) {
+		s.setCacheHeaders(w, etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	processors, err := opts.Processors()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(srcPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	ctx := r.Context()
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	options := opts.ProcessorOptions()
+	options.MaxPixels = s.config.MaxPixels
+	options.MaxFrames = s.config.MaxFrames
+	options.MaxAnimationPixels = s.config.MaxAnimationPixels
+
+	output, result, err := s.process(ctx, f, processors, options)
+	if err != nil {
+		var limitErr *vimage.LimitError
+		switch {
+		case errors.As(err, &limitErr) && limitErr.Step < 0 &&
+			(limitErr.Kind == vimage.LimitPixels || limitErr.Kind == vimage.LimitFrames ||
+				limitErr.Kind == vimage.LimitAnimationPixels):
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		case errors.Is(err, context.DeadlineExceeded):
+			http.Error(w, "processing timeout", http.StatusGatewayTimeout)
+		case errors.Is(err, context.Canceled):
+			// 客户端已断开
+		case errors.Is(err, errProcessPanic):
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		default:
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		}
+		return
+	}
+
+	// 处理成功后才设置缓存头，错误响应不能被缓存
+	s.setCacheHeaders(w, etag)
+	w.Header().Set("Content-Type", result.OutputFormat.MIMEType())
+	w.Header().Set("Content-Length", strconv.Itoa(len(output)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(output)
+	}
+}
+
+// parseRequest 解析处理参数和源图片路径
+func parseRequest(escapedPath, query string) (*vimage.URLOptions, string, error) {
+	if query != "" {
+		opts, err := vimage.ParseURLOptions(query)
+		if err != nil {
+			return nil, "", err
+		}
+		source, err := url.PathUnescape(escapedPath)
+		return opts, source, err
+	}
+
+	// 使用转义后的路径，避免参数值中转义的 '/' 被当作分隔符
+	opts, source, err := vimage.ParseURLPath(escapedPath)
+	if err != nil {
+		return nil, "", err
+	}
+	source, err = url.PathUnescape(source)
+	return opts, source, err
+}
+
+// resolve 将请求路径映射为源图片目录内的文件路径
+func (s *Server) resolve(name string) (string, error) {
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" {
+		return "", fmt.Errorf("未指定图片路径")
+	}
+	return filepath.Join(s.config.Root, filepath.FromSlash(cleaned)), nil
+}
+
+// checkOverlay 检查叠加图像的文件大小和像素数，只读取图片头部
+// 返回的错误不包含文件路径，避免泄露源图片目录的结构。
+func (s *Server) checkOverlay(name string) (os.FileInfo, int, error) {
+	info, err := os.Stat(name)
+	if err != nil || info.IsDir() {
+		return nil, http.StatusNotFound, errors.New("overlay image not found")
+	}
+	if s.config.MaxBytes > 0 && info.Size() > s.config.MaxBytes {
+		return nil, http.StatusRequestEntityTooLarge, errors.New("overlay image too large")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, http.StatusNotFound, errors.New("overlay image not found")
+	}
+	defer func() { _ = f.Close() }()
+
+	config, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid overlay image")
+	}
+	if s.config.MaxPixels > 0 && int64(config.Width)*int64(config.Height) > s.config.MaxPixels {
+		return nil, http.StatusRequestEntityTooLarge, errors.New("overlay image too large")
+	}
+	return info, 0, nil
+}
+
+// setCacheHeaders 设置 ETag 和 Cache-Control 响应头
+func (s *Server) setCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.config.MaxAge.Seconds())))
+}
+
+// etag 根据源文件、叠加图像文件和处理参数计算 ETag
+func (s *Server) etag(escapedPath, query string, infos []os.FileInfo) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s?%s", escapedPath, query)
+	for _, info := range infos {
+		_, _ = fmt.Fprintf(h, "|%d|%d", info.Size(), info.ModTime().UnixNano())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// process 在超时和并发限制下处理图片，返回编码后的数据和处理结果
+func (s *Server) process(ctx context.Context, src io.Reader, processors []vimage.Processor,
+	options *vimage.ProcessorOptions,
+) ([]byte, *vimage.ProcessResult, error) {
+	if s.sem != nil {
+		select {
+		case s.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+
+	type outcome struct {
+		result *vimage.ProcessResult
+		err    error
+	}
+
+	// 先写入缓冲区，处理完成后才能确定响应状态码
+	buf := new(bytes.Buffer)
+	done := make(chan outcome, 1)
+	go func() {
+		if s.sem != nil {
+			defer func() { <-s.sem }()
+		}
+		// 处理器 panic 时返回错误，不能让整个服务退出
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("image processing panic: %v\n%s", r, debug.Stack())
+				done <- outcome{err: fmt.Errorf("%w: %v", errProcessPanic, r)}
+			}
+		}()
+		result, err := vimage.ProcessReaderWithContext(ctx, src, buf, processors, options)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return buf.Bytes(), res.result, res.err
+	case <-ctx.Done():
+		log.Printf("image processing aborted: %v", ctx.Err())
+		return nil, nil, ctx.Err()
+	}
+}
+
+// etagMatch 判断 If-None-Match 是否匹配
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
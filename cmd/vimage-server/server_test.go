@@ -0,0 +1,391 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer 创建测试服务，源图片目录中包含 photo.jpg 和 logo.png
+func newTestServer(t *testing.T, config Config) *Server {
+	t.Helper()
+
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "photo.jpg"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 20, 20))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Root = dir
+	return NewServer(config)
+}
+
+func TestServerProcess(t *testing.T) {
+	s := newTestServer(t, Config{MaxAge: time.Hour})
+
+	req := httptest.NewRequest(http.MethodGet, "/rs:width:200/q:80/photo.jpg", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200，实际 %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/jpeg" {
+		t.Fatalf("Content-Type 错误: %s", ct)
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Fatalf("Cache-Control 错误: %s", cc)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Fatal("缺少 ETag")
+	}
+
+	img, _, err := image.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("响应无法解码: %v", err)
+	}
+	if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 150 {
+		t.Fatalf("期望尺寸 200x150，实际 %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+func TestServerQueryOptions(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/photo.jpg?o=c:center/wm:image:logo.png:center", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200，实际 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	img, _, err := image.Decode(rec.Body)
+	if err != nil {
+		t.Fatalf("响应无法解码: %v", err)
+	}
+	if img.Bounds().Dx() != 300 || img.Bounds().Dy() != 300 {
+		t.Fatalf("期望尺寸 300x300，实际 %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+func TestServerOutputFormat(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	testCases := []struct {
+		path        string
+		contentType string
+	}{
+		{"/rs:width:50/f:png/photo.jpg", "image/png"},
+		{"/rs:width:50/f:tiff/photo.jpg", "image/tiff"},
+		{"/f:jpg/logo.png", "image/jpeg"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("期望状态码 200，实际 %d: %s", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tc.contentType {
+				t.Fatalf("期望 Content-Type %s，实际 %s", tc.contentType, ct)
+			}
+		})
+	}
+}
+
+func TestServerNotModified(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rs:max:100/photo.jpg", nil))
+	etag := rec.Header().Get("ETag")
+
+	req := httptest.NewRequest(http.MethodGet, "/rs:max:100/photo.jpg", nil)
+	req.Header.Set("If-None-Match", `"other", `+etag)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("期望状态码 304，实际 %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatal("304 响应不应包含内容")
+	}
+	if rec.Header().Get("ETag") != etag {
+		t.Fatalf("304 响应 ETag 错误: %s", rec.Header().Get("ETag"))
+	}
+
+	// 不同的处理参数对应不同的 ETag
+	req = httptest.NewRequest(http.MethodGet, "/rs:max:50/photo.jpg", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200，实际 %d", rec.Code)
+	}
+}
+
+func TestServerOverlay(t *testing.T) {
+	s := newTestServer(t, Config{MaxPixels: 150_000})
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 500, 400))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.config.Root, "big.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.config.Root, "bad.png"), []byte("not image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"叠加图像", "/wm:image:logo.png/photo.jpg", http.StatusOK},
+		{"叠加图像不存在", "/wm:image:missing.png/photo.jpg", http.StatusNotFound},
+		{"叠加图像路径穿越", "/wm:image:..%2F..%2Fetc%2Fpasswd/photo.jpg", http.StatusNotFound},
+		{"叠加图像像素过多", "/wm:image:big.png/photo.jpg", http.StatusRequestEntityTooLarge},
+		{"叠加图像格式错误", "/wm:image:bad.png/photo.jpg", http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("期望状态码 %d，实际 %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+			if strings.Contains(rec.Body.String(), s.config.Root) {
+				t.Fatalf("响应不应包含文件路径: %s", rec.Body.String())
+			}
+		})
+	}
+
+	// 叠加图像修改后 ETag 变化
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wm:image:logo.png/photo.jpg", nil))
+	etag := rec.Header().Get("ETag")
+	modified := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(s.config.Root, "logo.png"), modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/wm:image:logo.png/photo.jpg", nil))
+	if rec.Header().Get("ETag") == etag {
+		t.Fatal("叠加图像修改后 ETag 应变化")
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s := newTestServer(t, Config{MaxBytes: 100})
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		code   int
+	}{
+		{"无效参数", http.MethodGet, "/rs:fit:1:1/photo.jpg", http.StatusBadRequest},
+		{"文件不存在", http.MethodGet, "/rs:width:10/missing.jpg", http.StatusNotFound},
+		{"路径穿越", http.MethodGet, "/rs:width:10/../../etc/passwd", http.StatusNotFound},
+		{"文件过大", http.MethodGet, "/rs:width:10/photo.jpg", http.StatusRequestEntityTooLarge},
+		{"方法不允许", http.MethodPost, "/photo.jpg", http.StatusMethodNotAllowed},
+		{"未指定图片", http.MethodGet, "/rs:width:10/", http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("期望状态码 %d，实际 %d: %s", tc.code, rec.Code, rec.Body.String())
+			}
+			if rec.Header().Get("Cache-Control") != "" || rec.Header().Get("ETag") != "" {
+				t.Fatalf("错误响应不应被缓存: %v", rec.Header())
+			}
+		})
+	}
+}
+
+func TestServerPanic(t *testing.T) {
+	s := newTestServer(t, Config{MaxAge: time.Hour, Concurrency: 1})
+
+	// 未限制像素数时分配超大图片会 panic
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rs:exact:4294967296:4294967296/photo.jpg", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("期望状态码 500，实际 %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("Cache-Control") != "" || rec.Header().Get("ETag") != "" {
+		t.Fatalf("错误响应不应被缓存: %v", rec.Header())
+	}
+
+	// panic 后释放并发名额，之后的请求正常处理
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rs:width:10/photo.jpg", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200，实际 %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	s := newTestServer(t, Config{Timeout: time.Nanosecond, Concurrency: 1})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rs:exact:2000:2000/rt:33/photo.jpg", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("期望状态码 504，实际 %d", rec.Code)
+	}
+}
+
+func TestServerPixelLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int64
+		path   string
+		status int
+	}{
+		{"源图片过大", 100_000, "/rs:width:100/photo.jpg", http.StatusRequestEntityTooLarge},
+		{"放大过大", 200_000, "/rs:ratio:2/photo.jpg", http.StatusUnprocessableEntity},
+		{"未超出限制", 200_000, "/rs:width:200/photo.jpg", http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t, Config{MaxPixels: tc.limit})
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("期望状态码 %d，实际 %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerAnimationLimit(t *testing.T) {
+	// 10 帧 100x100 的动画，合成后共 100000 像素
+	g := &gif.GIF{}
+	for i := 0; i < 10; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 100, 100), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, g); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		status int
+	}{
+		{"帧数过多", Config{MaxFrames: 5}, http.StatusRequestEntityTooLarge},
+		{"像素总数过多", Config{MaxAnimationPixels: 99_999}, http.StatusRequestEntityTooLarge},
+		{"未超出限制", Config{MaxFrames: 10, MaxAnimationPixels: 100_000}, http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newTestServer(t, tc.config)
+			if err := os.WriteFile(filepath.Join(s.config.Root, "anim.gif"), buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/rs:width:50/anim.gif", nil))
+			if rec.Code != tc.status {
+				t.Fatalf("期望状态码 %d，实际 %d: %s", tc.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerSignature(t *testing.T) {
+	signer, err := parseSigningKeys("k2=6e6577,k1=6f6c64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, Config{Signer: signer})
+
+	signed := signer.Sign("/rs:width:100/photo.jpg", time.Now().Add(time.Minute))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signed, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200，实际 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// 查询参数形式
+	signed = signer.Sign("/photo.jpg?o="+url.QueryEscape("rs:width:50"), time.Time{})
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, signed, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望状态码 200，实际 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	testCases := []struct {
+		name string
+		path string
+	}{
+		{"未签名", "/rs:width:100/photo.jpg"},
+		{"篡改参数", strings.Replace(signer.Sign("/rs:width:100/photo.jpg", time.Time{}), "100", "5000", 1)},
+		{"篡改查询参数", strings.Replace(signed, "50", "5000", 1)},
+		{"已过期", signer.Sign("/rs:width:100/photo.jpg", time.Now().Add(-time.Minute))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			if rec.Code != http.StatusForbidden {
+				t.Fatalf("期望状态码 403，实际 %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vogo/vimage"
+)
+
+// imageExts 扫描目录时处理的图片扩展名
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".tif":  true,
+	".tiff": true,
+	".webp": true,
+}
+
+// batchConfig 批处理公共参数
+type batchConfig struct {
+	out     string // 输出目录
+	name    string // 输出文件名模板
+	workers int    // 并发数
+	quality int    // 输出质量
+	format  string // 输出格式
+
+	maxBytes   int64 // JPEG 输出最大字节数
+	minQuality int   // 按大小查找质量时的最低质量
+	downscale  bool  // 最低质量仍然超出时缩小图片
+}
+
+// result 单个文件的处理结果
+type result struct {
+	input    string
+	output   string
+	err      error
+	duration time.Duration
+}
+
+// batchCommand 创建批处理子命令，公共参数之外的参数由 define 注册
+func batchCommand(name string, define stepFlags) func(args []string, stdout, stderr io.Writer) int {
+	return func(args []string, stdout, stderr io.Writer) int {
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			_, _ = fmt.Fprintf(stderr, "用法: vimage %s [参数] <文件|目录|通配符>...\n\n", name)
+			fs.PrintDefaults()
+		}
+
+		var config batchConfig
+		fs.StringVar(&config.out, "out", "", "输出目录")
+		fs.StringVar(&config.name, "name", "{name}{ext}",
+			"输出文件名模板，支持 {name} 原文件名(不含扩展名), {ext} 输出格式扩展名, {index} 输入序号")
+		fs.IntVar(&config.workers, "workers", runtime.NumCPU(), "并发处理数")
+		fs.IntVar(&config.quality, "quality", 0, "JPEG输出质量 (1-100)，默认 90")
+		fs.StringVar(&config.format, "format", "", "输出格式: jpeg, png, gif, bmp, tiff，默认与输入格式一致")
+		fs.Int64Var(&config.maxBytes, "max-bytes", 0, "JPEG输出最大字节数，自动降低质量，0 表示不限制")
+		fs.IntVar(&config.minQuality, "min-quality", 0, "按 -max-bytes 降低质量时的最低质量，默认 1")
+		fs.BoolVar(&config.downscale, "downscale", false, "最低质量仍然超出 -max-bytes 时缩小图片")
+		build := define(fs)
+
+		if err := fs.Parse(args); err != nil {
+			return exitUsage
+		}
+		if config.out == "" || fs.NArg() == 0 {
+			fs.Usage()
+			return exitUsage
+		}
+
+		// 构建处理器，参数错误或叠加图片不存在时直接退出
+		var processors []vimage.Processor
+		opts, err := build()
+		if err == nil {
+			processors, err = opts.Processors()
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		if config.quality > 0 {
+			opts.Quality = config.quality
+		}
+		if config.format != "" {
+			if opts.Format, err = vimage.ParseOutputFormat(config.format); err != nil {
+				_, _ = fmt.Fprintln(stderr, err)
+				return exitUsage
+			}
+		}
+
+		inputs, err := collectInputs(fs.Args())
+		if err != nil {
+			_, _ = fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+
+		options := opts.ProcessorOptions()
+		options.MaxJPEGBytes = config.maxBytes
+		options.MinQuality = config.minQuality
+		options.DownscaleToFit = config.downscale
+
+		results := runBatch(inputs, processors, options, config, stdout)
+		return summarize(results, stdout)
+	}
+}
+
+// collectInputs 展开输入参数，支持文件、目录和通配符，目录只扫描第一层的图片文件
+func collectInputs(args []string) ([]string, error) {
+	var inputs []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			inputs = append(inputs, p)
+		}
+	}
+
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("无效的通配符 %s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			// 不存在的文件在处理时报告失败
+			add(arg)
+			continue
+		}
+
+		for _, m := range matches {
+			info, err := os.Stat(m)
+			if err != nil || !info.IsDir() {
+				add(m)
+				continue
+			}
+
+			files, err := scanDir(m)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				add(f)
+			}
+		}
+	}
+
+	if len(inputs) == 0 {
+		return nil, errors.New("没有找到需要处理的图片")
+	}
+	return inputs, nil
+}
+
+// scanDir 返回目录下的图片文件，按文件名排序
+func scanDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && imageExts[strings.ToLower(filepath.Ext(e.Name()))] {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// runBatch 并发处理所有输入文件，每完成一个文件输出一行结果
+// 处理器可以并发使用，所有工作协程共享同一个处理器链
+func runBatch(inputs []string, processors []vimage.Processor, options *vimage.ProcessorOptions,
+	config batchConfig, stdout io.Writer,
+) []result {
+	outputs := make([]string, len(inputs))
+	paths := newOutputPaths(inputs)
+	items := make([]vimage.BatchItem, len(inputs))
+	for i, input := range inputs {
+		items[i] = vimage.BatchItem{
+			Name: input,
+			Open: func() (io.ReadCloser, error) { return os.Open(input) },
+			Save: func(output []byte, _ *vimage.ProcessResult) (err error) {
+				outputs[i], err = saveOutput(input, i, output, config, paths)
+				return err
+			},
+		}
+	}
+
+	batch := &vimage.Batch{
+		Processors: processors,
+		Options:    options,
+		Workers:    config.workers,
+		OnProgress: func(_ vimage.BatchProgress, r *vimage.BatchResult) {
+			printResult(stdout, toResult(r, outputs[r.Index]))
+		},
+	}
+
+	batchResults := batch.RunItems(context.Background(), items)
+	results := make([]result, len(batchResults))
+	for i := range batchResults {
+		results[i] = toResult(&batchResults[i], outputs[i])
+	}
+	return results
+}
+
+func toResult(r *vimage.BatchResult, output string) result {
+	return result{input: r.Item.Name, output: output, err: r.Err, duration: r.Duration}
+}
+
+// outputPaths 记录输入文件和已写入的输出文件，避免输出文件互相覆盖或覆盖输入文件
+type outputPaths struct {
+	mu      sync.Mutex
+	inputs  map[string]bool
+	written map[string]string // 输出文件 -> 输入文件
+}
+
+func newOutputPaths(inputs []string) *outputPaths {
+	p := &outputPaths{inputs: make(map[string]bool, len(inputs)), written: make(map[string]string)}
+	for _, input := range inputs {
+		p.inputs[canonicalPath(input)] = true
+	}
+	return p
+}
+
+// claim 占用输出文件路径，与输入文件或其他输入的输出文件相同时返回错误
+func (p *outputPaths) claim(outPath, input string) error {
+	key := canonicalPath(outPath)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inputs[key] {
+		return fmt.Errorf("输出文件 %s 会覆盖输入文件，请指定其他输出目录或 -name 模板", outPath)
+	}
+	if other, ok := p.written[key]; ok {
+		return fmt.Errorf("输出文件 %s 与 %s 的输出冲突，请在 -name 模板中使用 {index}", outPath, other)
+	}
+	p.written[key] = input
+	return nil
+}
+
+// canonicalPath 返回用于比较的绝对路径，目录中的符号链接会被解析
+func canonicalPath(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return filepath.Clean(p)
+	}
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// saveOutput 将处理结果写入输出目录，返回输出文件路径
+// 输出文件与输入文件或其他输入的输出文件相同时返回错误，不覆盖文件。
+func saveOutput(input string, index int, output []byte, config batchConfig, paths *outputPaths) (string, error) {
+	name := filepath.Base(input)
+	ext := outputExt(filepath.Ext(name), output)
+	outPath := filepath.Join(config.out, strings.NewReplacer(
+		"{name}", strings.TrimSuffix(name, filepath.Ext(name)),
+		"{ext}", ext,
+		"{index}", strconv.Itoa(index+1),
+	).Replace(config.name))
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0o755); err != nil {
+		return "", err
+	}
+	if err := paths.claim(outPath, input); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(outPath, output, 0o644); err != nil {
+		return "", err
+	}
+	return outPath, nil
+}
+
+// outputExt 根据输出内容确定扩展名，格式未变化时保留原扩展名
+func outputExt(inputExt string, output []byte) string {
+	format, err := vimage.DetectFormat(output)
+	if err != nil {
+		return inputExt
+	}
+	if f, err := vimage.ParseImageFormat(inputExt); err == nil && f == format {
+		return inputExt
+	}
+	return format.Extension()
+}
+
+func printResult(w io.Writer, r result) {
+	if r.err != nil {
+		_, _ = fmt.Fprintf(w, "FAIL %s: %v\n", r.input, r.err)
+		return
+	}
+	_, _ = fmt.Fprintf(w, "ok   %s -> %s (%s)\n", r.input, r.output, r.duration.Round(time.Millisecond))
+}
+
+// summarize 输出汇总信息并返回退出码
+func summarize(results []result, w io.Writer) int {
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\n%d succeeded, %d failed\n", len(results)-failed, failed)
+	if failed == 0 {
+		return exitOK
+	}
+
+	for _, r := range results {
+		if r.err != nil {
+			_, _ = fmt.Fprintf(w, "  %s: %v\n", r.input, r.err)
+		}
+	}
+	return exitFailure
+}
@@ -0,0 +1,349 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/vogo/vimage"
+)
+
+// stepFlags 注册子命令参数，返回参数解析完成后构建处理参数的函数
+type stepFlags func(fs *flag.FlagSet) func() (*vimage.URLOptions, error)
+
+// singleStep 将单个步骤包装为处理参数
+func singleStep(step *vimage.StepSpec) func() (*vimage.URLOptions, error) {
+	return func() (*vimage.URLOptions, error) {
+		return &vimage.URLOptions{Steps: []vimage.StepSpec{*step}}, nil
+	}
+}
+
+func resizeFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepZoom}
+	fs.StringVar(&step.Mode, "mode", "exact", "缩放模式: exact, ratio, width, height, max, min, cover, contain, pad")
+	fs.IntVar(&step.Width, "width", 0, "目标宽度")
+	fs.IntVar(&step.Height, "height", 0, "目标高度")
+	fs.IntVar(&step.Size, "size", 0, "最大/最小边长 (max, min 模式)")
+	fs.Float64Var(&step.Ratio, "ratio", 0, "缩放比例 (ratio 模式)")
+	fs.StringVar(&step.Scaler, "scaler", "",
+		"缩放算法: nearest, approx-bilinear, bilinear, catmull-rom, lanczos2, lanczos3, mitchell, box, area，加 linear- 前缀在线性光空间中缩放，加 fast- 前缀先盒式缩小再缩放")
+	fs.Float64Var(&step.Amount, "sharpen", 0, "缩小后的锐化强度，0 表示不锐化")
+	fs.StringVar(&step.Position, "position", "", "裁剪或放置位置 (cover, pad 模式): center, top, bottom, left, right, smart (cover 模式按内容选择)")
+	fs.StringVar(&step.Background, "background", "", "填充颜色 (pad 模式)，格式为 #RRGGBB 或 #RRGGBBAA，默认透明")
+	fs.BoolVar(&step.Blur, "blur", false, "使用模糊后的原图填充 (pad 模式)")
+	fs.BoolVar(&step.NoUpscale, "no-upscale", false, "不放大图片")
+	return singleStep(step)
+}
+
+func cutFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepCut}
+	fs.IntVar(&step.Width, "width", 0, "裁剪宽度")
+	fs.IntVar(&step.Height, "height", 0, "裁剪高度")
+	fs.IntVar(&step.Size, "size", 0, "正方形边长 (-square)，0 表示取短边")
+	fs.BoolVar(&step.Square, "square", false, "裁剪为正方形")
+	fs.Float64Var(&step.Aspect, "aspect", 0, "宽高比，未指定宽度和高度时裁剪该比例的最大区域，例如 1.5 表示 3:2")
+	fs.StringVar(&step.Position, "position", "center", "裁剪位置: center, top, bottom, left, right, smart (按内容选择)")
+	fs.IntVar(&step.X, "x", 0, "自定义区域左上角X坐标 (-region)")
+	fs.IntVar(&step.Y, "y", 0, "自定义区域左上角Y坐标 (-region)")
+	fs.BoolVar(&step.CustomRegion, "region", false, "使用 -x -y 指定的自定义区域")
+	return singleStep(step)
+}
+
+func rotateFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepRotate}
+	fs.Float64Var(&step.Angle, "angle", 0, "旋转角度，正数为顺时针")
+	fs.StringVar(&step.Background, "background", "", "背景颜色，格式为 #RRGGBB 或 #RRGGBBAA，默认透明")
+	fs.BoolVar(&step.KeepSize, "keep-size", false, "保持原始尺寸")
+	return singleStep(step)
+}
+
+func watermarkFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepWatermark}
+	fs.StringVar(&step.Text, "text", "", "水印文字")
+	fs.StringVar(&step.Position, "position", "bottom-right", "水印位置: top-left, top-right, bottom-left, bottom-right, center")
+	fs.Float64Var(&step.Opacity, "opacity", 0, "不透明度 (0-1)，默认 0.5")
+	fs.StringVar(&step.Color, "color", "", "文字颜色，格式为 #RRGGBB 或 #RRGGBBAA，默认白色")
+	fs.Float64Var(&step.FontSize, "font-size", 0, "字体大小，默认 24")
+	fs.Float64Var(&step.Rotation, "rotation", 0, "水印旋转角度")
+	fontPath := fs.String("font", "", "TrueType 字体文件路径")
+
+	return func() (*vimage.URLOptions, error) {
+		if err := setDefaultFont(*fontPath); err != nil {
+			return nil, err
+		}
+		return singleStep(step)()
+	}
+}
+
+func mosaicFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepMosaic}
+	fs.Func("region", "马赛克区域 fromX,fromY,toX,toY，可重复指定", func(s string) error {
+		region, err := parseMosaicRegion(s)
+		if err != nil {
+			return err
+		}
+		step.Regions = append(step.Regions, region)
+		return nil
+	})
+	percent := fs.Float64("percent", 1, "马赛克覆盖区域的百分比 (0-1)")
+	fs.StringVar(&step.Direction, "direction", "left", "马赛克开始方向: left, right, top, bottom")
+
+	return func() (*vimage.URLOptions, error) {
+		step.Percent = float32(*percent)
+		return singleStep(step)()
+	}
+}
+
+func seamCarveFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepSeamCarve}
+	fs.IntVar(&step.Width, "width", 0, "目标宽度，0 表示保持原宽度")
+	fs.IntVar(&step.Height, "height", 0, "目标高度，0 表示保持原高度")
+	fs.Func("protect", "保护区域 fromX,fromY,toX,toY，可重复指定", func(s string) error {
+		region, err := parseMosaicRegion(s)
+		if err != nil {
+			return err
+		}
+		step.Protect = append(step.Protect, region)
+		return nil
+	})
+	fs.Func("remove", "删除区域 fromX,fromY,toX,toY，可重复指定", func(s string) error {
+		region, err := parseMosaicRegion(s)
+		if err != nil {
+			return err
+		}
+		step.Remove = append(step.Remove, region)
+		return nil
+	})
+	return singleStep(step)
+}
+
+func overlayFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	step := &vimage.StepSpec{Type: vimage.StepOverlay}
+	fs.StringVar(&step.Image, "image", "", "叠加图片文件路径")
+	fs.StringVar(&step.Position, "position", "", "叠加位置: top-left, top-right, bottom-left, bottom-right, center, "+
+		"top-center, bottom-center, left-center, right-center，为空时使用 -x -y")
+	fs.IntVar(&step.X, "x", 0, "叠加位置X坐标")
+	fs.IntVar(&step.Y, "y", 0, "叠加位置Y坐标")
+	fs.Float64Var(&step.Opacity, "opacity", 0, "不透明度 (0-1)，默认 1")
+	fs.Float64Var(&step.Scale, "scale", 0, "叠加图片缩放比例")
+	return singleStep(step)
+}
+
+func pipelineFlags(fs *flag.FlagSet) func() (*vimage.URLOptions, error) {
+	specPath := fs.String("spec", "", "处理器链配置文件 (JSON 或 YAML)")
+	urlOptions := fs.String("o", "", "URL处理参数，例如 rs:width:300/c:center/q:80")
+	fontPath := fs.String("font", "", "TrueType 字体文件路径，用于水印和文字")
+
+	return func() (*vimage.URLOptions, error) {
+		if err := setDefaultFont(*fontPath); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case *specPath != "" && *urlOptions != "":
+			return nil, errors.New("-spec 和 -o 只能指定一个")
+		case *specPath != "":
+			spec, err := vimage.LoadPipelineFile(*specPath)
+			if err != nil {
+				return nil, err
+			}
+			return &vimage.URLOptions{Steps: spec.Steps}, nil
+		case *urlOptions != "":
+			return vimage.ParseURLOptions(*urlOptions)
+		default:
+			return nil, errors.New("需要指定 -spec 或 -o")
+		}
+	}
+}
+
+// parseMosaicRegion 解析 fromX,fromY,toX,toY 格式的马赛克区域
+func parseMosaicRegion(s string) (*vimage.MosaicRegion, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("马赛克区域格式应为 fromX,fromY,toX,toY: %s", s)
+	}
+
+	var coords [4]int
+	for i, p := range parts {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil, fmt.Errorf("无效的马赛克区域坐标 %s: %w", p, err)
+		}
+		coords[i] = v
+	}
+
+	return &vimage.MosaicRegion{FromX: coords[0], FromY: coords[1], ToX: coords[2], ToY: coords[3]}, nil
+}
+
+// setDefaultFont 加载字体文件并设置为默认字体，路径为空时不做处理
+func setDefaultFont(path string) error {
+	font, err := loadFontFile(path)
+	if err != nil || font == nil {
+		return err
+	}
+	vimage.SetDefaultFont(font)
+	return nil
+}
+
+// loadFontFile 加载 TrueType 字体文件，路径为空时返回 nil
+func loadFontFile(path string) (*truetype.Font, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取字体文件失败: %w", err)
+	}
+
+	font, err := truetype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析字体文件失败: %w", err)
+	}
+	return font, nil
+}
+
+// runCaptcha 生成验证码图片
+func runCaptcha(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("captcha", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	config := *vimage.DefaultCaptchaConfig
+	text := fs.String("text", "", "验证码文本")
+	out := fs.String("out", "captcha.png", "输出文件路径")
+	fs.IntVar(&config.Width, "width", config.Width, "图片宽度")
+	fs.IntVar(&config.Height, "height", config.Height, "图片高度")
+	fs.IntVar(&config.NoiseLines, "lines", config.NoiseLines, "干扰线数量")
+	fs.IntVar(&config.NoiseDots, "dots", config.NoiseDots, "干扰点数量")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *text == "" {
+		_, _ = fmt.Fprintln(stderr, "需要指定 -text")
+		return exitUsage
+	}
+
+	buf, err := vimage.GenCaptchaImageWithConfig(*text, &config)
+	if err == nil {
+		err = os.WriteFile(*out, buf.Bytes(), 0o644)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "生成验证码失败: %v\n", err)
+		return exitFailure
+	}
+
+	_, _ = fmt.Fprintf(stdout, "ok   %s\n", *out)
+	return exitOK
+}
+
+// runTable 根据CSV文件生成表格图片，CSV第一行为表头
+func runTable(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("table", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	out := fs.String("out", "table.png", "输出文件路径")
+	layout := fs.String("layout", "rows", "表格布局: rows 每条数据一行, columns 每条数据一列")
+	fontPath := fs.String("font", "", "TrueType 字体文件路径，默认使用内置英文字体")
+	widths := fs.String("widths", "", "列宽，逗号分隔 (rows 布局)")
+
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		_, _ = fmt.Fprintln(stderr, "用法: vimage table [参数] <CSV文件>")
+		return exitUsage
+	}
+	if *layout != "rows" && *layout != "columns" {
+		_, _ = fmt.Fprintf(stderr, "无效的表格布局: %s\n", *layout)
+		return exitUsage
+	}
+
+	colWidths, err := parseWidths(*widths)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	if err := writeTable(fs.Arg(0), *out, *layout, *fontPath, colWidths); err != nil {
+		_, _ = fmt.Fprintf(stderr, "生成表格失败: %v\n", err)
+		return exitFailure
+	}
+
+	_, _ = fmt.Fprintf(stdout, "ok   %s\n", *out)
+	return exitOK
+}
+
+func writeTable(csvPath, out, layout, fontPath string, widths []float64) error {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return fmt.Errorf("读取CSV失败: %w", err)
+	}
+	if len(records) == 0 {
+		return errors.New("CSV文件为空")
+	}
+
+	font, err := loadFontFile(fontPath)
+	if err != nil {
+		return err
+	}
+
+	headers, data := records[0], records[1:]
+	var buf *bytes.Buffer
+	if layout == "columns" {
+		buf, err = vimage.GenMultipleColumnsTableImage(font, headers, data)
+	} else {
+		buf, err = vimage.GenMultipleRowsTableImage(font, headers, data, widths)
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(out, buf.Bytes(), 0o644)
+}
+
+func parseWidths(s string) ([]float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var widths []float64
+	for _, p := range strings.Split(s, ",") {
+		w, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的列宽 %s: %w", p, err)
+		}
+		widths = append(widths, w)
+	}
+	return widths, nil
+}
@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// vimage 图片批处理命令行工具，与 vimage-server 使用相同的处理器和参数
+//
+// 用法:
+//
+//	vimage <子命令> [参数] <文件|目录|通配符>...
+//
+// 例如:
+//
+//	vimage resize -mode width -width 300 -out dist photos/
+//	vimage watermark -text ACME -position bottom-right -out dist -name "{name}_wm{ext}" "photos/*.jpg"
+//	vimage pipeline -spec pipeline.yaml -out dist photos/
+//	vimage pipeline -o "rs:width:300/c:center/q:80" -out dist photos/
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// 退出码
+const (
+	exitOK      = 0 // 全部成功
+	exitFailure = 1 // 存在处理失败的文件
+	exitUsage   = 2 // 参数错误
+)
+
+// command 子命令
+type command struct {
+	name  string
+	usage string
+	run   func(args []string, stdout, stderr io.Writer) int
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// commands 返回所有子命令
+func commands() []command {
+	return []command{
+		{"resize", "缩放图片", batchCommand("resize", resizeFlags)},
+		{"cut", "裁剪图片", batchCommand("cut", cutFlags)},
+		{"rotate", "旋转图片", batchCommand("rotate", rotateFlags)},
+		{"watermark", "添加文字水印", batchCommand("watermark", watermarkFlags)},
+		{"mosaic", "添加马赛克", batchCommand("mosaic", mosaicFlags)},
+		{"seam", "接缝裁剪，按内容改变宽高比", batchCommand("seam", seamCarveFlags)},
+		{"overlay", "叠加图片", batchCommand("overlay", overlayFlags)},
+		{"pipeline", "按处理器链配置或URL处理参数处理图片", batchCommand("pipeline", pipelineFlags)},
+		{"captcha", "生成验证码图片", runCaptcha},
+		{"table", "根据CSV生成表格图片", runTable},
+	}
+}
+
+// run 执行命令行，返回退出码
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	for _, cmd := range commands() {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout, stderr)
+		}
+	}
+
+	_, _ = fmt.Fprintf(stderr, "未知的子命令: %s\n\n", args[0])
+	printUsage(stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "用法: vimage <子命令> [参数] <文件|目录|通配符>...")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "子命令:")
+	for _, cmd := range commands() {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "使用 vimage <子命令> -h 查看子命令参数")
+}
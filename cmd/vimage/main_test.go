@@ -0,0 +1,281 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestImages 在临时目录中写入 a.jpg, b.png 和一个非图片文件
+func writeTestImages(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 64, A: 255})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.jpg"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("skip"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func decodeFile(t *testing.T, path string) image.Image {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("无法解码 %s: %v", path, err)
+	}
+	return img
+}
+
+func TestResizeDirectory(t *testing.T) {
+	dir := writeTestImages(t)
+	out := t.TempDir()
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	code := run([]string{"resize", "-mode", "width", "-width", "50", "-out", out,
+		"-name", "{name}_small{ext}", dir}, stdout, stderr)
+	if code != exitOK {
+		t.Fatalf("期望退出码 0，实际 %d: %s%s", code, stdout, stderr)
+	}
+	if !strings.Contains(stdout.String(), "2 succeeded, 0 failed") {
+		t.Fatalf("汇总信息错误: %s", stdout)
+	}
+
+	for _, name := range []string{"a_small.jpg", "b_small.png"} {
+		img := decodeFile(t, filepath.Join(out, name))
+		if img.Bounds().Dx() != 50 || img.Bounds().Dy() != 25 {
+			t.Fatalf("%s 期望尺寸 50x25，实际 %dx%d", name, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+	}
+}
+
+func TestBatchFailure(t *testing.T) {
+	dir := writeTestImages(t)
+	if err := os.WriteFile(filepath.Join(dir, "broken.jpg"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	stdout := new(bytes.Buffer)
+
+	code := run([]string{"rotate", "-angle", "90", "-out", out, "-workers", "2",
+		filepath.Join(dir, "*.jpg"), filepath.Join(dir, "missing.png")}, stdout, new(bytes.Buffer))
+	if code != exitFailure {
+		t.Fatalf("期望退出码 1，实际 %d", code)
+	}
+
+	output := stdout.String()
+	if !strings.Contains(output, "1 succeeded, 2 failed") {
+		t.Fatalf("汇总信息错误: %s", output)
+	}
+	if !strings.Contains(output, "FAIL "+filepath.Join(dir, "broken.jpg")) ||
+		!strings.Contains(output, "FAIL "+filepath.Join(dir, "missing.png")) {
+		t.Fatalf("缺少失败文件信息: %s", output)
+	}
+	if _, err := os.Stat(filepath.Join(out, "a.jpg")); err != nil {
+		t.Fatalf("成功的文件应写入输出目录: %v", err)
+	}
+}
+
+func TestBatchOutputCollision(t *testing.T) {
+	dir := writeTestImages(t)
+	other := filepath.Join(t.TempDir(), "a.jpg")
+	data, err := os.ReadFile(filepath.Join(dir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不同目录中同名的文件不能互相覆盖
+	out := t.TempDir()
+	stdout := new(bytes.Buffer)
+	code := run([]string{"rotate", "-angle", "90", "-out", out, "-workers", "1",
+		filepath.Join(dir, "a.jpg"), other}, stdout, new(bytes.Buffer))
+	if code != exitFailure || !strings.Contains(stdout.String(), "1 succeeded, 1 failed") ||
+		!strings.Contains(stdout.String(), "冲突") {
+		t.Fatalf("期望输出冲突，实际 %d: %s", code, stdout)
+	}
+
+	// 使用 {index} 区分同名文件
+	stdout.Reset()
+	code = run([]string{"rotate", "-angle", "90", "-out", out, "-name", "{name}_{index}{ext}",
+		filepath.Join(dir, "a.jpg"), other}, stdout, new(bytes.Buffer))
+	if code != exitOK {
+		t.Fatalf("期望退出码 0，实际 %d: %s", code, stdout)
+	}
+
+	// 输出目录与输入目录相同时不覆盖源文件
+	stdout.Reset()
+	code = run([]string{"rotate", "-angle", "90", "-out", dir, filepath.Join(dir, "a.jpg")},
+		stdout, new(bytes.Buffer))
+	if code != exitFailure || !strings.Contains(stdout.String(), "覆盖输入文件") {
+		t.Fatalf("期望拒绝覆盖输入文件，实际 %d: %s", code, stdout)
+	}
+	if written, _ := os.ReadFile(filepath.Join(dir, "a.jpg")); !bytes.Equal(written, data) {
+		t.Fatal("输入文件不应被修改")
+	}
+}
+
+func TestPipelineURLOptions(t *testing.T) {
+	dir := writeTestImages(t)
+	out := t.TempDir()
+
+	code := run([]string{"pipeline", "-o", "c:center/rs:max:40/q:70", "-out", out, "-name", "{index}{ext}",
+		filepath.Join(dir, "a.jpg")}, new(bytes.Buffer), new(bytes.Buffer))
+	if code != exitOK {
+		t.Fatalf("期望退出码 0，实际 %d", code)
+	}
+
+	img := decodeFile(t, filepath.Join(out, "1.jpg"))
+	if img.Bounds().Dx() != 40 || img.Bounds().Dy() != 40 {
+		t.Fatalf("期望尺寸 40x40，实际 %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+func TestOutputFormat(t *testing.T) {
+	dir := writeTestImages(t)
+	out := t.TempDir()
+
+	code := run([]string{"cut", "-square", "-format", "jpg", "-out", out, dir}, new(bytes.Buffer), new(bytes.Buffer))
+	if code != exitOK {
+		t.Fatalf("期望退出码 0，实际 %d", code)
+	}
+
+	// b.png 转换为 JPEG 后扩展名随之变化
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		if img := decodeFile(t, filepath.Join(out, name)); img.Bounds().Dx() != 100 {
+			t.Fatalf("%s 期望宽度 100，实际 %d", name, img.Bounds().Dx())
+		}
+	}
+
+	if code := run([]string{"cut", "-square", "-format", "webp", "-out", out, dir},
+		new(bytes.Buffer), new(bytes.Buffer)); code != exitUsage {
+		t.Fatalf("不支持的输出格式期望退出码 2，实际 %d", code)
+	}
+}
+
+func TestPipelineSpecFile(t *testing.T) {
+	dir := writeTestImages(t)
+	out := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "pipeline.yaml")
+	err := os.WriteFile(spec, []byte("steps:\n  - type: cut\n    square: true\n  - type: circle\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := run([]string{"pipeline", "-spec", spec, "-out", out, filepath.Join(dir, "a.jpg")},
+		new(bytes.Buffer), new(bytes.Buffer))
+	if code != exitOK {
+		t.Fatalf("期望退出码 0，实际 %d", code)
+	}
+
+	img := decodeFile(t, filepath.Join(out, "a.jpg"))
+	if img.Bounds().Dx() != 100 || img.Bounds().Dy() != 100 {
+		t.Fatalf("期望尺寸 100x100，实际 %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	dir := writeTestImages(t)
+
+	testCases := []struct {
+		name string
+		args []string
+	}{
+		{"无子命令", nil},
+		{"未知子命令", []string{"blur"}},
+		{"缺少输出目录", []string{"resize", "-width", "10", dir}},
+		{"缺少输入", []string{"resize", "-width", "10", "-out", t.TempDir()}},
+		{"无效参数", []string{"resize", "-mode", "fit", "-out", t.TempDir(), dir}},
+		{"叠加图片不存在", []string{"overlay", "-image", "missing.png", "-out", t.TempDir(), dir}},
+		{"无效马赛克区域", []string{"mosaic", "-region", "1,2,3", "-out", t.TempDir(), dir}},
+		{"缺少处理器链", []string{"pipeline", "-out", t.TempDir(), dir}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := run(tc.args, new(bytes.Buffer), new(bytes.Buffer)); code != exitUsage {
+				t.Fatalf("期望退出码 2，实际 %d", code)
+			}
+		})
+	}
+}
+
+func TestCaptchaAndTable(t *testing.T) {
+	dir := t.TempDir()
+
+	captcha := filepath.Join(dir, "captcha.png")
+	if code := run([]string{"captcha", "-text", "AB12", "-out", captcha}, new(bytes.Buffer), new(bytes.Buffer)); code != exitOK {
+		t.Fatalf("生成验证码失败，退出码 %d", code)
+	}
+	if img := decodeFile(t, captcha); img.Bounds().Dx() != 120 {
+		t.Fatalf("验证码宽度错误: %d", img.Bounds().Dx())
+	}
+
+	csvPath := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(csvPath, []byte("name,size\nphoto,120\nlogo,20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, layout := range []string{"rows", "columns"} {
+		out := filepath.Join(dir, layout+".png")
+		code := run([]string{"table", "-layout", layout, "-out", out, csvPath}, new(bytes.Buffer), new(bytes.Buffer))
+		if code != exitOK {
+			t.Fatalf("生成 %s 表格失败，退出码 %d", layout, code)
+		}
+		decodeFile(t, out)
+	}
+}
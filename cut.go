@@ -20,6 +20,7 @@ package vimage
 import (
 	"fmt"
 	"image"
+	"math"
 )
 
 // CutPosition 定义切割位置
@@ -36,6 +37,8 @@ const (
 	CutPositionLeft CutPosition = "left"
 	// CutPositionRight 从右侧切割
 	CutPositionRight CutPosition = "right"
+	// CutPositionSmart 根据图片内容选择边缘、色彩和肤色最丰富的区域，见 SmartCrop
+	CutPositionSmart CutPosition = "smart"
 )
 
 // CutProcessor 图像切割处理器
@@ -53,6 +56,8 @@ type CutProcessor struct {
 	UseCustomRegion bool
 	// 是否为正方形模式（自动使用较小边）
 	SquareMode bool
+	// 宽高比，Width 和 Height 都为 0 时切割该比例的最大区域
+	Aspect float64
 }
 
 // Process 实现Processor接口
@@ -62,12 +67,86 @@ func (p *CutProcessor) Process(img image.Image) (image.Image, error) {
 	origWidth := bounds.Dx()
 	origHeight := bounds.Dy()
 
-	// 确定目标尺寸
+	// 计算并验证目标尺寸
+	width, height, err := p.PlanSize(origWidth, origHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	// 正方形模式下如果已经是正方形且尺寸匹配，直接返回
+	if p.SquareMode && origWidth == width && origHeight == height && !p.UseCustomRegion {
+		return img, nil
+	}
+
+	// 计算切割区域
+	region := p.region(img, width, height)
+
+	// 尝试使用SubImage以提高性能
+	subImg, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if ok {
+		return subImg.SubImage(region), nil
+	}
+
+	// 如果不支持SubImage，手动复制像素
+	cutImg := image.NewRGBA(image.Rect(0, 0, width, height))
+	for dy := 0; dy < height; dy++ {
+		for dx := 0; dx < width; dx++ {
+			cutImg.Set(dx, dy, img.At(region.Min.X+dx, region.Min.Y+dy))
+		}
+	}
+
+	return cutImg, nil
+}
+
+// Region 返回在 img 中切割的区域，坐标与 img.Bounds() 一致
+// smart 位置选择的区域可以保存下来，之后使用 NewCutProcessorWithRegion 得到相同的切割结果
+func (p *CutProcessor) Region(img image.Image) (image.Rectangle, error) {
+	bounds := img.Bounds()
+	width, height, err := p.PlanSize(bounds.Dx(), bounds.Dy())
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return p.region(img, width, height), nil
+}
+
+// contentAware 实现 geometryResolver 接口，smart 位置根据图片内容选择切割区域
+func (p *CutProcessor) contentAware() bool {
+	return !p.UseCustomRegion && p.Position == CutPositionSmart
+}
+
+// resolveGeometry 实现 geometryResolver 接口，返回切割 img 中相同区域的处理器
+func (p *CutProcessor) resolveGeometry(img image.Image) (Processor, error) {
+	region, err := p.Region(img)
+	if err != nil {
+		return nil, err
+	}
+	origin := img.Bounds().Min
+	return NewCutProcessorWithRegion(region.Dx(), region.Dy(), region.Min.X-origin.X, region.Min.Y-origin.Y), nil
+}
+
+// region 计算切割区域，尺寸已经过 PlanSize 验证
+func (p *CutProcessor) region(img image.Image, width, height int) image.Rectangle {
+	var x, y int
+	if p.UseCustomRegion {
+		// 使用自定义区域，PlanSize 已验证区域有效
+		x, y = p.X, p.Y
+	} else {
+		// 根据位置计算起始点
+		x, y = cropOffset(img, p.Position, width, height)
+	}
+	origin := img.Bounds().Min
+	return image.Rect(origin.X+x, origin.Y+y, origin.X+x+width, origin.Y+y+height)
+}
+
+// PlanSize 实现 Planner 接口，计算并验证切割后的尺寸
+// 正方形模式下未指定的边使用另一边或原图较小边
+func (p *CutProcessor) PlanSize(origWidth, origHeight int) (int, int, error) {
 	width := p.Width
 	height := p.Height
 
 	if p.SquareMode {
-		// 正方形模式：如果没有指定尺寸，使用较小边
 		if width == 0 && height == 0 {
 			size := min(origWidth, origHeight)
 			width = size
@@ -79,84 +158,44 @@ func (p *CutProcessor) Process(img image.Image) (image.Image, error) {
 			// 指定了高度，宽度使用相同值
 			width = height
 		}
+
 		// 如果宽高都指定了，确保它们相等
 		if width != height {
-			return nil, fmt.Errorf("正方形模式下宽度和高度必须相等: %dx%d", width, height)
+			return 0, 0, fmt.Errorf("正方形模式下宽度和高度必须相等: %dx%d", width, height)
 		}
+	}
 
-		// 如果已经是正方形且尺寸匹配，直接返回
-		if origWidth == origHeight && origWidth == width && !p.UseCustomRegion {
-			return img, nil
+	// 按宽高比切割最大区域
+	if width == 0 && height == 0 && !p.SquareMode {
+		if p.Aspect != 0 && !validCutAspect(p.Aspect) {
+			return 0, 0, fmt.Errorf("无效的切割宽高比: %v，必须在 %v-%v 之间", p.Aspect, 1/maxCutAspect, maxCutAspect)
+		}
+		if p.Aspect > 0 {
+			width, height = aspectSize(origWidth, origHeight, p.Aspect)
 		}
 	}
 
 	// 验证目标尺寸
 	if width <= 0 || height <= 0 {
-		return nil, fmt.Errorf("无效的切割尺寸: %dx%d", width, height)
+		return 0, 0, fmt.Errorf("无效的切割尺寸: %dx%d", width, height)
 	}
 
 	// 检查目标尺寸是否超过原始尺寸
 	if width > origWidth || height > origHeight {
-		return nil, fmt.Errorf("切割尺寸(%dx%d)超过原始尺寸(%dx%d)",
+		return 0, 0, fmt.Errorf("切割尺寸(%dx%d)超过原始尺寸(%dx%d)",
 			width, height, origWidth, origHeight)
 	}
 
-	// 计算切割的起始位置
-	var x, y int
-
+	// 验证自定义区域是否有效
 	if p.UseCustomRegion {
-		// 使用自定义区域
-		x = p.X
-		y = p.Y
-
-		// 验证自定义区域是否有效
+		x, y := p.X, p.Y
 		if x < 0 || y < 0 || x+width > origWidth || y+height > origHeight {
-			return nil, fmt.Errorf("无效的切割区域: 起点(%d,%d), 尺寸(%dx%d), 原始尺寸(%dx%d)",
+			return 0, 0, fmt.Errorf("无效的切割区域: 起点(%d,%d), 尺寸(%dx%d), 原始尺寸(%dx%d)",
 				x, y, width, height, origWidth, origHeight)
 		}
-	} else {
-		// 根据位置计算起始点
-		switch p.Position {
-		case CutPositionTop:
-			// 从顶部开始，水平居中
-			x = (origWidth - width) / 2
-			y = 0
-		case CutPositionBottom:
-			// 从底部开始，水平居中
-			x = (origWidth - width) / 2
-			y = origHeight - height
-		case CutPositionLeft:
-			// 从左侧开始，垂直居中
-			x = 0
-			y = (origHeight - height) / 2
-		case CutPositionRight:
-			// 从右侧开始，垂直居中
-			x = origWidth - width
-			y = (origHeight - height) / 2
-		default: // CutPositionCenter 或其他值
-			// 居中切割
-			x = (origWidth - width) / 2
-			y = (origHeight - height) / 2
-		}
-	}
-
-	// 尝试使用SubImage以提高性能
-	subImg, ok := img.(interface {
-		SubImage(r image.Rectangle) image.Image
-	})
-	if ok {
-		return subImg.SubImage(image.Rect(x, y, x+width, y+height)), nil
-	}
-
-	// 如果不支持SubImage，手动复制像素
-	cutImg := image.NewRGBA(image.Rect(0, 0, width, height))
-	for dy := 0; dy < height; dy++ {
-		for dx := 0; dx < width; dx++ {
-			cutImg.Set(dx, dy, img.At(x+dx, y+dy))
-		}
 	}
 
-	return cutImg, nil
+	return width, height, nil
 }
 
 // NewCutProcessor 创建新的矩形切割处理器（使用预定义位置）
@@ -170,6 +209,19 @@ func NewCutProcessor(width, height int, position CutPosition) *CutProcessor {
 	}
 }
 
+// NewCutAspectProcessor 创建按宽高比切割最大区域的处理器，例如 16.0/9 表示 16:9
+func NewCutAspectProcessor(aspect float64, position CutPosition) *CutProcessor {
+	return &CutProcessor{
+		Position: position,
+		Aspect:   aspect,
+	}
+}
+
+// NewSmartCutProcessor 创建根据图片内容选择切割位置的处理器
+func NewSmartCutProcessor(width, height int) *CutProcessor {
+	return NewCutProcessor(width, height, CutPositionSmart)
+}
+
 // NewCutProcessorWithRegion 创建新的切割处理器（使用自定义区域）
 func NewCutProcessorWithRegion(width, height, x, y int) *CutProcessor {
 	return &CutProcessor{
@@ -223,3 +275,51 @@ func NewCutSquareProcessorWithRegion(size, x, y int) *CutProcessor {
 
 // Type alias for backward compatibility
 type CutSquareProcessor = CutProcessor
+
+// maxCutAspect 切割宽高比的最大值，最小值为其倒数
+const maxCutAspect = 100.0
+
+// validCutAspect 宽高比是否为有效范围内的有限值
+func validCutAspect(aspect float64) bool {
+	return aspect >= 1/maxCutAspect && aspect <= maxCutAspect
+}
+
+// aspectSize 返回 width x height 中宽高比为 aspect 的最大区域尺寸
+func aspectSize(width, height int, aspect float64) (int, int) {
+	if float64(width) > float64(height)*aspect {
+		return max(int(math.Round(float64(height)*aspect)), 1), height
+	}
+	return width, max(int(math.Round(float64(width)/aspect)), 1)
+}
+
+// cropOffset 计算在 img 中切割 width x height 区域的左上角坐标，坐标相对于 img.Bounds().Min
+// smart 位置根据图片内容计算，其他位置与 positionOffset 相同
+func cropOffset(img image.Image, position CutPosition, width, height int) (int, int) {
+	if position == CutPositionSmart {
+		return smartCropOffset(img, width, height)
+	}
+	bounds := img.Bounds()
+	return positionOffset(position, bounds.Dx(), bounds.Dy(), width, height)
+}
+
+// positionOffset 计算大小为 width x height 的区域在 outerWidth x outerHeight 中按位置放置时的左上角坐标
+// 区域大于外部时坐标为负数
+func positionOffset(position CutPosition, outerWidth, outerHeight, width, height int) (int, int) {
+	switch position {
+	case CutPositionTop:
+		// 从顶部开始，水平居中
+		return (outerWidth - width) / 2, 0
+	case CutPositionBottom:
+		// 从底部开始，水平居中
+		return (outerWidth - width) / 2, outerHeight - height
+	case CutPositionLeft:
+		// 从左侧开始，垂直居中
+		return 0, (outerHeight - height) / 2
+	case CutPositionRight:
+		// 从右侧开始，垂直居中
+		return outerWidth - width, (outerHeight - height) / 2
+	default: // CutPositionCenter 或其他值
+		// 居中
+		return (outerWidth - width) / 2, (outerHeight - height) / 2
+	}
+}
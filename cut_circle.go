@@ -18,12 +18,16 @@
 package vimage
 
 import (
+	"context"
 	"errors"
 	"image"
 	"image/color"
 	"math"
 )
 
+// errNotSquare is returned when circular cropping is applied to a non-square image
+var errNotSquare = errors.New("image must be square for circular cropping")
+
 // CutCircleProcessor implements the Processor interface for circular image cropping
 type CutCircleProcessor struct{}
 
@@ -36,15 +40,33 @@ func (p *CutCircleProcessor) Process(img image.Image) (image.Image, error) {
 	return Circle(img)
 }
 
+// ProcessWithContext implements the CancelableProcessor interface.
+func (p *CutCircleProcessor) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
+	return CircleWithContext(ctx, img)
+}
+
+// PlanSize 实现 Planner 接口，处理后尺寸不变，图像必须是正方形
+func (p *CutCircleProcessor) PlanSize(width, height int) (int, int, error) {
+	if width != height {
+		return 0, 0, errNotSquare
+	}
+	return width, height, nil
+}
+
 // Circle crops the image into a circle, making pixels outside the circle transparent
 // If the image is not square, returns an error
 func Circle(img image.Image) (image.Image, error) {
+	return CircleWithContext(context.Background(), img)
+}
+
+// CircleWithContext is like Circle but stops and returns ctx.Err() when ctx is done.
+func CircleWithContext(ctx context.Context, img image.Image) (image.Image, error) {
 	// Check if image is square
 	bounds := img.Bounds()
 	width := bounds.Dx()
 	height := bounds.Dy()
 	if width != height {
-		return nil, errors.New("image must be square for circular cropping")
+		return nil, errNotSquare
 	}
 
 	// Get circle radius (default to half of width/height)
@@ -56,6 +78,9 @@ func Circle(img image.Image) (image.Image, error) {
 	centerY := float64(height) / 2
 
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if err := checkCanceled(ctx, y-bounds.Min.Y); err != nil {
+			return nil, err
+		}
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			distance := math.Sqrt(math.Pow(float64(x)-centerX, 2) + math.Pow(float64(y)-centerY, 2))
 			if distance <= radius {
@@ -65,3 +65,8 @@ func (p *DrawCircleProcessor) ContextProcess(ctx *ImageProcessContext) error {
 func (p *DrawCircleProcessor) Process(img image.Image) (image.Image, error) {
 	return ContextProcess(img, []ContextProcessor{p})
 }
+
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *DrawCircleProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
@@ -96,3 +96,8 @@ func (p *DrawRectProcessor) ContextProcess(ctx *ImageProcessContext) error {
 func (p *DrawRectProcessor) Process(img image.Image) (image.Image, error) {
 	return ContextProcess(img, []ContextProcessor{p})
 }
+
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *DrawRectProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
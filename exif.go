@@ -0,0 +1,435 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Orientation EXIF 方向标签 (0x0112) 的取值
+type Orientation int
+
+// EXIF 方向，描述存储的像素需要如何变换才能正确显示
+const (
+	OrientationUnknown    Orientation = 0 // 没有方向信息
+	OrientationNormal     Orientation = 1 // 正常
+	OrientationFlipH      Orientation = 2 // 水平翻转
+	OrientationRotate180  Orientation = 3 // 旋转180度
+	OrientationFlipV      Orientation = 4 // 垂直翻转
+	OrientationTranspose  Orientation = 5 // 沿左上-右下对角线翻转
+	OrientationRotate90   Orientation = 6 // 顺时针旋转90度
+	OrientationTransverse Orientation = 7 // 沿右上-左下对角线翻转
+	OrientationRotate270  Orientation = 8 // 顺时针旋转270度
+)
+
+// ErrExifNotFound 图片中没有 EXIF 数据
+var ErrExifNotFound = errors.New("图片中没有EXIF数据")
+
+// EXIF 标签
+const (
+	exifTagMake             = 0x010f
+	exifTagModel            = 0x0110
+	exifTagOrientation      = 0x0112
+	exifTagSoftware         = 0x0131
+	exifTagDateTime         = 0x0132
+	exifTagArtist           = 0x013b
+	exifTagCopyright        = 0x8298
+	exifTagExifIFD          = 0x8769
+	exifTagGPSIFD           = 0x8825
+	exifTagDateTimeOriginal = 0x9003
+	exifTagMakerNote        = 0x927c
+	exifTagInteropIFD       = 0xa005
+
+	gpsTagLatitudeRef  = 0x0001
+	gpsTagLatitude     = 0x0002
+	gpsTagLongitudeRef = 0x0003
+	gpsTagLongitude    = 0x0004
+)
+
+// TIFF 数据类型
+const (
+	tiffTypeASCII    = 2
+	tiffTypeShort    = 3
+	tiffTypeLong     = 4
+	tiffTypeRational = 5
+)
+
+// tiffTypeSizes TIFF 数据类型对应的字节数
+var tiffTypeSizes = map[uint16]int{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// ExifInfo 常用的 EXIF 字段
+type ExifInfo struct {
+	Orientation      Orientation // 方向
+	Make             string      // 相机厂商
+	Model            string      // 相机型号
+	Software         string      // 软件
+	DateTime         string      // 修改时间
+	DateTimeOriginal string      // 拍摄时间
+	Artist           string      // 作者
+	Copyright        string      // 版权
+	GPS              *GPSInfo    // 位置信息，没有时为 nil
+}
+
+// GPSInfo EXIF 中的位置信息
+type GPSInfo struct {
+	Latitude  float64 // 纬度，南纬为负数
+	Longitude float64 // 经度，西经为负数
+}
+
+// ReadExif 读取 JPEG 或 PNG 图片的 EXIF 字段，不解码像素
+// 没有 EXIF 数据时返回 ErrExifNotFound
+func ReadExif(r io.Reader) (*ExifInfo, error) {
+	meta, err := ReadMetadata(r)
+	if err != nil {
+		return nil, err
+	}
+	return meta.ExifInfo()
+}
+
+// ReadOrientation 读取图片的 EXIF 方向，不解码像素
+// 没有方向信息时返回 OrientationUnknown
+func ReadOrientation(r io.Reader) (Orientation, error) {
+	meta, err := ReadMetadata(r)
+	if err != nil {
+		return OrientationUnknown, err
+	}
+	return parseExifOrientation(meta.EXIF), nil
+}
+
+// tiffEntry IFD 条目
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // 原始字节序的值
+}
+
+// tiffData 解析后的 TIFF 结构，只保留 IFD0 及其 EXIF、GPS 子 IFD
+type tiffData struct {
+	order binary.ByteOrder
+	ifd0  []tiffEntry
+	exif  []tiffEntry
+	gps   []tiffEntry
+}
+
+// parseTIFF 解析 EXIF 中的 TIFF 结构
+func parseTIFF(data []byte) (*tiffData, error) {
+	if len(data) < 8 {
+		return nil, errors.New("EXIF数据过短")
+	}
+
+	t := &tiffData{}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errors.New("无效的EXIF字节序")
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, errors.New("无效的EXIF标识")
+	}
+
+	var err error
+	if t.ifd0, err = readIFD(data, t.order, t.order.Uint32(data[4:])); err != nil {
+		return nil, err
+	}
+
+	// 子 IFD 解析失败时忽略，不影响 IFD0 中的字段
+	if e := findEntry(t.ifd0, exifTagExifIFD); e != nil && len(e.value) == 4 {
+		t.exif, _ = readIFD(data, t.order, t.order.Uint32(e.value))
+	}
+	if e := findEntry(t.ifd0, exifTagGPSIFD); e != nil && len(e.value) == 4 {
+		t.gps, _ = readIFD(data, t.order, t.order.Uint32(e.value))
+	}
+
+	return t, nil
+}
+
+// readIFD 读取指定偏移处的 IFD
+func readIFD(data []byte, order binary.ByteOrder, offset uint32) ([]tiffEntry, error) {
+	if offset < 8 || int64(offset)+2 > int64(len(data)) {
+		return nil, errors.New("无效的IFD偏移")
+	}
+
+	count := int(order.Uint16(data[offset:]))
+	entries := make([]tiffEntry, 0, count)
+	for i := 0; i < count; i++ {
+		pos := int(offset) + 2 + i*12
+		if pos+12 > len(data) {
+			return nil, errors.New("IFD数据不完整")
+		}
+
+		e := tiffEntry{
+			tag:   order.Uint16(data[pos:]),
+			typ:   order.Uint16(data[pos+2:]),
+			count: order.Uint32(data[pos+4:]),
+		}
+		size, ok := tiffTypeSizes[e.typ]
+		if !ok {
+			continue
+		}
+
+		n := int64(size) * int64(e.count)
+		if n <= 4 {
+			e.value = data[pos+8 : pos+8+int(n)]
+		} else {
+			start := int64(order.Uint32(data[pos+8:]))
+			if start+n > int64(len(data)) {
+				continue
+			}
+			e.value = data[start : start+n]
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func findEntry(entries []tiffEntry, tag uint16) *tiffEntry {
+	for i := range entries {
+		if entries[i].tag == tag {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// str 读取 ASCII 类型的值
+func (t *tiffData) str(entries []tiffEntry, tag uint16) string {
+	e := findEntry(entries, tag)
+	if e == nil || e.typ != tiffTypeASCII {
+		return ""
+	}
+	return strings.TrimRight(string(e.value), "\x00 ")
+}
+
+// orientation 读取方向标签
+func (t *tiffData) orientation() Orientation {
+	e := findEntry(t.ifd0, exifTagOrientation)
+	if e == nil || e.typ != tiffTypeShort || len(e.value) < 2 {
+		return OrientationUnknown
+	}
+	o := Orientation(t.order.Uint16(e.value))
+	if o < OrientationNormal || o > OrientationRotate270 {
+		return OrientationUnknown
+	}
+	return o
+}
+
+// gpsCoordinate 读取度分秒格式的坐标
+func (t *tiffData) gpsCoordinate(tag, refTag uint16, negative string) (float64, bool) {
+	e := findEntry(t.gps, tag)
+	if e == nil || e.typ != tiffTypeRational || len(e.value) < 24 {
+		return 0, false
+	}
+
+	var v float64
+	for i, unit := range []float64{1, 60, 3600} {
+		num := t.order.Uint32(e.value[i*8:])
+		den := t.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			return 0, false
+		}
+		v += float64(num) / float64(den) / unit
+	}
+
+	if t.str(t.gps, refTag) == negative {
+		v = -v
+	}
+	return v, true
+}
+
+// info 转换为常用字段
+func (t *tiffData) info() *ExifInfo {
+	info := &ExifInfo{
+		Orientation:      t.orientation(),
+		Make:             t.str(t.ifd0, exifTagMake),
+		Model:            t.str(t.ifd0, exifTagModel),
+		Software:         t.str(t.ifd0, exifTagSoftware),
+		DateTime:         t.str(t.ifd0, exifTagDateTime),
+		DateTimeOriginal: t.str(t.exif, exifTagDateTimeOriginal),
+		Artist:           t.str(t.ifd0, exifTagArtist),
+		Copyright:        t.str(t.ifd0, exifTagCopyright),
+	}
+
+	lat, ok1 := t.gpsCoordinate(gpsTagLatitude, gpsTagLatitudeRef, "S")
+	lng, ok2 := t.gpsCoordinate(gpsTagLongitude, gpsTagLongitudeRef, "W")
+	if ok1 && ok2 {
+		info.GPS = &GPSInfo{Latitude: lat, Longitude: lng}
+	}
+
+	return info
+}
+
+// encode 编码为 TIFF 结构，不包含缩略图 IFD
+func (t *tiffData) encode() []byte {
+	buf := make([]byte, 8)
+	if t.order == binary.LittleEndian {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	t.order.PutUint16(buf[2:], 42)
+	t.order.PutUint32(buf[4:], 8)
+
+	subs := map[uint16][]tiffEntry{}
+	if len(t.exif) > 0 {
+		subs[exifTagExifIFD] = t.exif
+	}
+	if len(t.gps) > 0 {
+		subs[exifTagGPSIFD] = t.gps
+	}
+
+	return t.writeIFD(buf, t.ifd0, subs)
+}
+
+// writeIFD 在 buf 末尾写入 IFD 及其数据，子 IFD 写在之后并回填偏移
+func (t *tiffData) writeIFD(buf []byte, entries []tiffEntry, subs map[uint16][]tiffEntry) []byte {
+	// 子 IFD 指针只在对应子 IFD 存在时写入
+	list := make([]tiffEntry, 0, len(entries)+len(subs))
+	for _, e := range entries {
+		if e.tag == exifTagExifIFD || e.tag == exifTagGPSIFD {
+			continue
+		}
+		list = append(list, e)
+	}
+	for tag := range subs {
+		list = append(list, tiffEntry{tag: tag, typ: tiffTypeLong, count: 1, value: make([]byte, 4)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].tag < list[j].tag })
+
+	start := len(buf)
+	buf = append(buf, make([]byte, 2+12*len(list)+4)...)
+	t.order.PutUint16(buf[start:], uint16(len(list)))
+
+	pointers := map[uint16]int{}
+	for i, e := range list {
+		pos := start + 2 + i*12
+		t.order.PutUint16(buf[pos:], e.tag)
+		t.order.PutUint16(buf[pos+2:], e.typ)
+		t.order.PutUint32(buf[pos+4:], e.count)
+
+		if _, ok := subs[e.tag]; ok {
+			pointers[e.tag] = pos + 8
+			continue
+		}
+		if len(e.value) <= 4 {
+			copy(buf[pos+8:], e.value)
+			continue
+		}
+
+		// 数据按字对齐
+		if len(buf)%2 == 1 {
+			buf = append(buf, 0)
+		}
+		t.order.PutUint32(buf[pos+8:], uint32(len(buf)))
+		buf = append(buf, e.value...)
+	}
+
+	for _, tag := range []uint16{exifTagExifIFD, exifTagGPSIFD} {
+		pos, ok := pointers[tag]
+		if !ok {
+			continue
+		}
+		if len(buf)%2 == 1 {
+			buf = append(buf, 0)
+		}
+		t.order.PutUint32(buf[pos:], uint32(len(buf)))
+		buf = t.writeIFD(buf, subs[tag], nil)
+	}
+
+	return buf
+}
+
+// parseExifOrientation 从 EXIF 数据中读取方向标签
+func parseExifOrientation(exif []byte) Orientation {
+	if len(exif) == 0 {
+		return OrientationUnknown
+	}
+	t, err := parseTIFF(exif)
+	if err != nil {
+		return OrientationUnknown
+	}
+	return t.orientation()
+}
+
+// swapsSize 校正方向后宽高是否互换
+func (o Orientation) swapsSize() bool {
+	return o >= OrientationTranspose && o <= OrientationRotate270
+}
+
+// ApplyOrientation 按 EXIF 方向变换图片，使其按正确的方向显示
+func ApplyOrientation(img image.Image, o Orientation) image.Image {
+	if o <= OrientationNormal || o > OrientationRotate270 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	src, ok := img.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		src = image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	}
+
+	// 目标坐标 (x, y) 对应的源坐标
+	var srcAt func(x, y int) (int, int)
+	dw, dh := w, h
+	switch o {
+	case OrientationFlipH:
+		srcAt = func(x, y int) (int, int) { return w - 1 - x, y }
+	case OrientationRotate180:
+		srcAt = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case OrientationFlipV:
+		srcAt = func(x, y int) (int, int) { return x, h - 1 - y }
+	case OrientationTranspose:
+		dw, dh = h, w
+		srcAt = func(x, y int) (int, int) { return y, x }
+	case OrientationRotate90:
+		dw, dh = h, w
+		srcAt = func(x, y int) (int, int) { return y, h - 1 - x }
+	case OrientationTransverse:
+		dw, dh = h, w
+		srcAt = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case OrientationRotate270:
+		dw, dh = h, w
+		srcAt = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		row := dst.Pix[y*dst.Stride:]
+		for x := 0; x < dw; x++ {
+			sx, sy := srcAt(x, y)
+			copy(row[x*4:x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,167 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// buildExif 构建只包含方向标签的 EXIF 数据
+func buildExif(order binary.ByteOrder, o Orientation) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(exifHeader)
+	if order == binary.LittleEndian {
+		buf.WriteString("II")
+	} else {
+		buf.WriteString("MM")
+	}
+	_ = binary.Write(buf, order, uint16(42))
+	_ = binary.Write(buf, order, uint32(8))
+	_ = binary.Write(buf, order, uint16(1))
+	_ = binary.Write(buf, order, uint16(exifTagOrientation))
+	_ = binary.Write(buf, order, uint16(3))
+	_ = binary.Write(buf, order, uint32(1))
+	_ = binary.Write(buf, order, uint16(o))
+	_ = binary.Write(buf, order, uint16(0))
+	_ = binary.Write(buf, order, uint32(0))
+	return buf.Bytes()
+}
+
+// insertAPP1 在 SOI 之后插入 APP1 段
+func insertAPP1(jpegData, payload []byte) []byte {
+	seg := []byte{0xff, jpegMarkerAPP1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+
+	out := append([]byte{}, jpegData[:2]...)
+	out = append(out, seg...)
+	out = append(out, payload...)
+	return append(out, jpegData[2:]...)
+}
+
+func encodeTestJPEG(t *testing.T, w, h int, o Orientation) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return insertAPP1(buf.Bytes(), buildExif(binary.BigEndian, o))
+}
+
+func TestReadOrientation(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		buf := new(bytes.Buffer)
+		_ = jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil)
+		data := insertAPP1(buf.Bytes(), buildExif(order, OrientationRotate90))
+
+		o, err := ReadOrientation(bytes.NewReader(data))
+		if err != nil || o != OrientationRotate90 {
+			t.Fatalf("%v: 期望方向 6，实际 %d, %v", order, o, err)
+		}
+	}
+
+	// 没有 EXIF 的 JPEG 和非 JPEG 图片
+	for _, data := range [][]byte{encodeTestPNG(t, 4, 4), []byte("xx")} {
+		if o, err := ReadOrientation(bytes.NewReader(data)); err != nil || o != OrientationUnknown {
+			t.Fatalf("期望没有方向信息，实际 %d, %v", o, err)
+		}
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// 3x2 图片，每个像素颜色不同
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 3; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x*10 + y), A: 255})
+		}
+	}
+
+	// 顺时针旋转90度后左上角像素位于右上角
+	rotated := ApplyOrientation(src, OrientationRotate90)
+	if rotated.Bounds().Dx() != 2 || rotated.Bounds().Dy() != 3 {
+		t.Fatalf("期望尺寸 2x3，实际 %v", rotated.Bounds())
+	}
+	if rotated.At(1, 0) != src.At(0, 0) || rotated.At(0, 0) != src.At(0, 1) {
+		t.Fatal("顺时针旋转90度结果错误")
+	}
+
+	if flipped := ApplyOrientation(src, OrientationFlipH); flipped.At(0, 0) != src.At(2, 0) {
+		t.Fatal("水平翻转结果错误")
+	}
+
+	// 存储的图片经过逆变换得到，校正后应与原图一致
+	inverse := map[Orientation]Orientation{
+		OrientationFlipH:      OrientationFlipH,
+		OrientationRotate180:  OrientationRotate180,
+		OrientationFlipV:      OrientationFlipV,
+		OrientationTranspose:  OrientationTranspose,
+		OrientationRotate90:   OrientationRotate270,
+		OrientationTransverse: OrientationTransverse,
+		OrientationRotate270:  OrientationRotate90,
+	}
+	for o, inv := range inverse {
+		restored := ApplyOrientation(ApplyOrientation(src, inv), o)
+		if restored.Bounds() != src.Bounds() {
+			t.Fatalf("方向 %d: 尺寸错误 %v", o, restored.Bounds())
+		}
+		for y := 0; y < 2; y++ {
+			for x := 0; x < 3; x++ {
+				if restored.At(x, y) != src.At(x, y) {
+					t.Fatalf("方向 %d: 像素 (%d,%d) 不一致", o, x, y)
+				}
+			}
+		}
+	}
+
+	if ApplyOrientation(src, OrientationNormal) != image.Image(src) {
+		t.Fatal("正常方向应返回原图")
+	}
+}
+
+func TestProcessReaderAutoOrient(t *testing.T) {
+	data := encodeTestJPEG(t, 40, 20, OrientationRotate90)
+
+	// 校正方向后按宽度缩放
+	out := new(bytes.Buffer)
+	result, err := ProcessReader(bytes.NewReader(data), out, []Processor{NewZoomWidthProcessor(10)}, nil)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if result.Orientation != OrientationRotate90 {
+		t.Fatalf("期望方向 6，实际 %d", result.Orientation)
+	}
+	if result.Width != 10 || result.Height != 20 {
+		t.Fatalf("期望尺寸 10x20，实际 %dx%d", result.Width, result.Height)
+	}
+
+	// 关闭自动校正
+	out.Reset()
+	result, err = ProcessReader(bytes.NewReader(data), out, nil, &ProcessorOptions{DisableAutoOrient: true})
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if result.Width != 40 || result.Height != 20 || result.Orientation != OrientationRotate90 {
+		t.Fatalf("关闭校正后期望尺寸 40x20，实际 %dx%d", result.Width, result.Height)
+	}
+}
@@ -20,6 +20,8 @@ package vimage
 import (
 	_ "embed"
 	"fmt"
+	"image"
+	"image/draw"
 	"io"
 	"net/http"
 	"os"
@@ -27,6 +29,9 @@ import (
 	"time"
 
 	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
 )
 
 var (
@@ -112,3 +117,81 @@ func GetDefaultFont() (*truetype.Font, error) {
 
 	return defaultFont, nil
 }
+
+// fontIdentity 返回字体的标识，由字体名称、版本和度量组成，用于缓存键
+// 名称、版本和度量都相同的两个字体被视为同一个字体。
+func fontIdentity(f *truetype.Font) string {
+	if f == nil {
+		return ""
+	}
+	unitsPerEm := f.FUnitsPerEm()
+	return fmt.Sprintf("%s|%s|%d|%v", f.Name(truetype.NameIDFontFullName), f.Name(truetype.NameIDNameTableVersion),
+		unitsPerEm, f.Bounds(fixed.Int26_6(unitsPerEm)))
+}
+
+// syncFace 并发安全的字体，所有方法在锁内调用被包装的字体
+type syncFace struct {
+	mu   sync.Mutex
+	face font.Face
+}
+
+// NewSyncFace 包装字体，使其可以被多个 goroutine 同时使用
+// truetype.Face 等字体内部有字形缓存，返回的字形遮罩在下一次调用前有效，不能被多个 goroutine 同时使用。
+// 包装后的字体在锁内获取字形并复制遮罩，被多个处理器共享的字体需要包装一次后再传给各个处理器。
+// basicfont 没有内部状态，与已经包装的字体一样原样返回。
+func NewSyncFace(face font.Face) font.Face {
+	switch face.(type) {
+	case nil, *basicfont.Face, *syncFace:
+		return face
+	}
+	return &syncFace{face: face}
+}
+
+// Close 实现 font.Face 接口
+func (f *syncFace) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Close()
+}
+
+// Glyph 实现 font.Face 接口，返回的遮罩是复制的，不会被之后的调用修改
+func (f *syncFace) Glyph(dot fixed.Point26_6, r rune) (image.Rectangle, image.Image, image.Point, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	dr, mask, maskp, advance, ok := f.face.Glyph(dot, r)
+	if !ok || mask == nil {
+		return dr, mask, maskp, advance, ok
+	}
+	copied := image.NewAlpha(image.Rectangle{Min: maskp, Max: maskp.Add(dr.Size())})
+	draw.Draw(copied, copied.Rect, mask, maskp, draw.Src)
+	return dr, copied, maskp, advance, ok
+}
+
+// GlyphBounds 实现 font.Face 接口
+func (f *syncFace) GlyphBounds(r rune) (fixed.Rectangle26_6, fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.GlyphBounds(r)
+}
+
+// GlyphAdvance 实现 font.Face 接口
+func (f *syncFace) GlyphAdvance(r rune) (fixed.Int26_6, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.GlyphAdvance(r)
+}
+
+// Kern 实现 font.Face 接口
+func (f *syncFace) Kern(r0, r1 rune) fixed.Int26_6 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Kern(r0, r1)
+}
+
+// Metrics 实现 font.Face 接口
+func (f *syncFace) Metrics() font.Metrics {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.face.Metrics()
+}
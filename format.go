@@ -0,0 +1,209 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+
+	// 注册 WebP 解码器，WebP 只支持解码
+	_ "golang.org/x/image/webp"
+)
+
+// ImageFormat 图片格式，取值与 image.Decode 返回的格式名称一致
+type ImageFormat string
+
+// 支持的图片格式
+const (
+	FormatAuto ImageFormat = ""     // 与输入格式一致
+	FormatJPEG ImageFormat = "jpeg" // JPEG
+	FormatPNG  ImageFormat = "png"  // PNG
+	FormatGIF  ImageFormat = "gif"  // GIF
+	FormatBMP  ImageFormat = "bmp"  // BMP
+	FormatTIFF ImageFormat = "tiff" // TIFF
+	FormatWebP ImageFormat = "webp" // WebP，只支持解码
+)
+
+// formatAliases 格式别名
+var formatAliases = map[string]ImageFormat{
+	"jpg": FormatJPEG,
+	"tif": FormatTIFF,
+}
+
+// formatInfo 格式的MIME类型和扩展名
+var formatInfo = map[ImageFormat]struct {
+	mime string
+	ext  string
+}{
+	FormatJPEG: {"image/jpeg", ".jpg"},
+	FormatPNG:  {"image/png", ".png"},
+	FormatGIF:  {"image/gif", ".gif"},
+	FormatBMP:  {"image/bmp", ".bmp"},
+	FormatTIFF: {"image/tiff", ".tiff"},
+	FormatWebP: {"image/webp", ".webp"},
+}
+
+// UnsupportedFormatError 不支持的图片格式
+type UnsupportedFormatError struct {
+	Format string // 格式名称
+	Encode bool   // 是否为编码时不支持
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	switch {
+	case e.Encode:
+		return "不支持编码为图片格式: " + e.Format
+	case e.Format == "":
+		return "无法识别的图片格式"
+	default:
+		return "不支持的图片格式: " + e.Format
+	}
+}
+
+// Unwrap 解码时不支持的格式可以通过 errors.Is(err, image.ErrFormat) 判断
+func (e *UnsupportedFormatError) Unwrap() error {
+	if e.Encode {
+		return nil
+	}
+	return image.ErrFormat
+}
+
+// ParseImageFormat 解析图片格式名称，不区分大小写，支持 jpg、tif 等别名
+func ParseImageFormat(s string) (ImageFormat, error) {
+	name := strings.ToLower(strings.TrimPrefix(s, "."))
+	if f, ok := formatAliases[name]; ok {
+		return f, nil
+	}
+	if _, ok := formatInfo[ImageFormat(name)]; ok {
+		return ImageFormat(name), nil
+	}
+	return "", &UnsupportedFormatError{Format: s}
+}
+
+// ParseOutputFormat 解析输出格式名称，不支持编码的格式返回错误
+func ParseOutputFormat(s string) (ImageFormat, error) {
+	f, err := ParseImageFormat(s)
+	if err != nil {
+		return "", &UnsupportedFormatError{Format: s, Encode: true}
+	}
+	if !f.CanEncode() {
+		return "", &UnsupportedFormatError{Format: string(f), Encode: true}
+	}
+	return f, nil
+}
+
+// MIMEType 返回格式对应的MIME类型
+func (f ImageFormat) MIMEType() string {
+	return formatInfo[f].mime
+}
+
+// Extension 返回格式对应的文件扩展名，包含 '.'
+func (f ImageFormat) Extension() string {
+	return formatInfo[f].ext
+}
+
+// CanEncode 是否支持编码为该格式
+func (f ImageFormat) CanEncode() bool {
+	_, ok := formatInfo[f]
+	return ok && f != FormatWebP
+}
+
+// DetectFormat 根据图片头部检测图片格式，不解码像素
+func DetectFormat(data []byte) (ImageFormat, error) {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	return ImageFormat(format), nil
+}
+
+// JPEGChroma JPEG色度采样方式
+type JPEGChroma string
+
+// 支持的JPEG色度采样方式
+const (
+	JPEGChroma420 JPEGChroma = ""    // 4:2:0 采样，色度分辨率为亮度的一半，默认
+	JPEGChroma444 JPEGChroma = "444" // 4:4:4 采样，保留完整的色度分辨率，适合文字和细线
+)
+
+// EncodeImage 按指定格式编码图片
+// format 为空时使用 PNG；options 为空时使用默认选项
+func EncodeImage(w io.Writer, img image.Image, format ImageFormat, options *ProcessorOptions) error {
+	if options == nil {
+		options = &DefaultProcessorOptions
+	}
+	if format == FormatAuto {
+		format = FormatPNG
+	}
+
+	switch format {
+	case FormatJPEG:
+		return encodeJPEG(w, img, options)
+	case FormatPNG:
+		encoder := &png.Encoder{CompressionLevel: options.PNGCompression}
+		return encoder.Encode(w, img)
+	case FormatGIF:
+		numColors := options.GIFColors
+		if numColors <= 0 || numColors > 256 {
+			numColors = 256
+		}
+		return gif.Encode(w, img, &gif.Options{NumColors: numColors})
+	case FormatBMP:
+		return bmp.Encode(w, img)
+	case FormatTIFF:
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	default:
+		return &UnsupportedFormatError{Format: string(format), Encode: true}
+	}
+}
+
+func encodeJPEG(w io.Writer, img image.Image, options *ProcessorOptions) error {
+	quality := options.Quality
+	if quality <= 0 {
+		quality = DefaultProcessorOptions.Quality
+	}
+
+	if options.JPEGChroma != JPEGChroma420 && options.JPEGChroma != JPEGChroma444 {
+		return fmt.Errorf("不支持的JPEG色度采样方式: %s", options.JPEGChroma)
+	}
+
+	// 灰度图只有亮度，没有色度采样
+	if options.JPEGGray {
+		if _, ok := img.(*image.Gray); !ok {
+			gray := image.NewGray(img.Bounds())
+			draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+			img = gray
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	}
+
+	if options.JPEGChroma == JPEGChroma444 {
+		return encodeJPEG444(w, img, quality)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
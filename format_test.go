@@ -0,0 +1,206 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodeTestPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: 100, A: 255})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImageOutputFormat(t *testing.T) {
+	data := encodeTestPNG(t, 40, 30)
+
+	testCases := []struct {
+		name    string
+		options *ProcessorOptions
+		format  ImageFormat
+	}{
+		{"默认与输入一致", nil, FormatPNG},
+		{"PNG转JPEG", &ProcessorOptions{Format: FormatJPEG, Quality: 80}, FormatJPEG},
+		{"JPEG灰度", &ProcessorOptions{Format: FormatJPEG, JPEGGray: true}, FormatJPEG},
+		{"JPEG 4:4:4", &ProcessorOptions{Format: FormatJPEG, JPEGChroma: JPEGChroma444}, FormatJPEG},
+		{"PNG最佳压缩", &ProcessorOptions{Format: FormatPNG, PNGCompression: png.BestCompression}, FormatPNG},
+		{"GIF调色板", &ProcessorOptions{Format: FormatGIF, GIFColors: 16}, FormatGIF},
+		{"BMP", &ProcessorOptions{Format: FormatBMP}, FormatBMP},
+		{"TIFF", &ProcessorOptions{Format: FormatTIFF}, FormatTIFF},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := ProcessImage(data, []Processor{NewZoomWidthProcessor(20)}, tc.options)
+			if err != nil {
+				t.Fatalf("处理失败: %v", err)
+			}
+
+			img, format, err := image.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("输出无法解码: %v", err)
+			}
+			if ImageFormat(format) != tc.format {
+				t.Fatalf("期望输出格式 %s，实际 %s", tc.format, format)
+			}
+			if img.Bounds().Dx() != 20 || img.Bounds().Dy() != 15 {
+				t.Fatalf("期望尺寸 20x15，实际 %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+			}
+
+			if tc.options != nil && tc.options.JPEGGray {
+				if _, ok := img.(*image.Gray); !ok {
+					t.Fatalf("期望灰度图，实际 %T", img)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessImageUnsupportedFormat(t *testing.T) {
+	var formatErr *UnsupportedFormatError
+
+	_, err := ProcessImage([]byte("not an image"), nil, nil)
+	if !errors.As(err, &formatErr) || formatErr.Encode {
+		t.Fatalf("期望解码格式错误，实际 %v", err)
+	}
+	if !errors.Is(err, image.ErrFormat) {
+		t.Fatal("解码格式错误应能匹配 image.ErrFormat")
+	}
+
+	_, err = ProcessImage(encodeTestPNG(t, 4, 4), nil, &ProcessorOptions{Format: FormatWebP})
+	if !errors.As(err, &formatErr) || !formatErr.Encode || formatErr.Format != "webp" {
+		t.Fatalf("期望编码格式错误，实际 %v", err)
+	}
+
+	_, err = ProcessImage(encodeTestPNG(t, 4, 4), nil, &ProcessorOptions{Format: FormatJPEG, JPEGChroma: "422"})
+	if err == nil {
+		t.Fatal("不支持的色度采样方式应返回错误")
+	}
+}
+
+func TestParseImageFormat(t *testing.T) {
+	testCases := []struct {
+		input  string
+		format ImageFormat
+	}{
+		{"jpg", FormatJPEG},
+		{"JPEG", FormatJPEG},
+		{".png", FormatPNG},
+		{"tif", FormatTIFF},
+		{"webp", FormatWebP},
+	}
+
+	for _, tc := range testCases {
+		f, err := ParseImageFormat(tc.input)
+		if err != nil || f != tc.format {
+			t.Fatalf("%s: 期望 %s，实际 %s, %v", tc.input, tc.format, f, err)
+		}
+	}
+
+	var formatErr *UnsupportedFormatError
+	if _, err := ParseImageFormat("heic"); !errors.As(err, &formatErr) {
+		t.Fatalf("期望格式错误，实际 %v", err)
+	}
+	if _, err := ParseOutputFormat("webp"); !errors.As(err, &formatErr) || !formatErr.Encode {
+		t.Fatalf("WebP 不支持编码，实际 %v", err)
+	}
+	if f, err := ParseOutputFormat("jpg"); err != nil || f.Extension() != ".jpg" || f.MIMEType() != "image/jpeg" {
+		t.Fatalf("解析 jpg 错误: %s, %v", f, err)
+	}
+}
+
+func TestEncodeJPEG444(t *testing.T) {
+	// 红蓝相间的竖线，宽高不是 8 的倍数
+	img := image.NewRGBA(image.Rect(0, 0, 37, 21))
+	for y := 0; y < 21; y++ {
+		for x := 0; x < 37; x++ {
+			c := color.RGBA{R: 220, G: 30, B: 40, A: 255}
+			if x%2 == 1 {
+				c = color.RGBA{R: 30, G: 40, B: 220, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	maxDiff := func(chroma JPEGChroma) int {
+		t.Helper()
+		buf := new(bytes.Buffer)
+		if err := EncodeImage(buf, img, FormatJPEG, &ProcessorOptions{Quality: 95, JPEGChroma: chroma}); err != nil {
+			t.Fatalf("编码失败: %v", err)
+		}
+		out, err := jpeg.Decode(buf)
+		if err != nil {
+			t.Fatalf("输出无法解码: %v", err)
+		}
+		if out.Bounds() != img.Bounds() {
+			t.Fatalf("尺寸错误: %v", out.Bounds())
+		}
+		if ycbcr, ok := out.(*image.YCbCr); chroma == JPEGChroma444 && (!ok || ycbcr.SubsampleRatio != image.YCbCrSubsampleRatio444) {
+			t.Fatalf("期望 4:4:4 采样，实际 %T", out)
+		}
+
+		diff := 0
+		for y := 0; y < 21; y++ {
+			for x := 0; x < 37; x++ {
+				c := color.RGBAModel.Convert(out.At(x, y)).(color.RGBA)
+				want := img.RGBAAt(x, y)
+				diff = max(diff, absDiff(int(c.R)-int(want.R)), absDiff(int(c.G)-int(want.G)), absDiff(int(c.B)-int(want.B)))
+			}
+		}
+		return diff
+	}
+
+	// 4:2:0 相邻两列的颜色混合，4:4:4 保留每一列的颜色
+	if d := maxDiff(JPEGChroma444); d > 24 {
+		t.Fatalf("4:4:4 颜色误差过大: %d", d)
+	}
+	if d := maxDiff(JPEGChroma420); d < 60 {
+		t.Fatalf("4:2:0 应混合相邻列的颜色，误差 %d", d)
+	}
+
+	// 质量越低文件越小
+	var sizes []int
+	for _, quality := range []int{30, 90} {
+		buf := new(bytes.Buffer)
+		if err := encodeJPEG444(buf, img, quality); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, buf.Len())
+	}
+	if sizes[0] >= sizes[1] {
+		t.Fatalf("低质量的文件应更小: %v", sizes)
+	}
+}
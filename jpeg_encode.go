@@ -0,0 +1,387 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// JPEG 标记
+const (
+	jpegSOF0 = 0xc0 // 基线顺序编码
+	jpegSOF1 = 0xc1 // 扩展顺序编码
+	jpegDHT  = 0xc4
+	jpegRST0 = 0xd0
+	jpegRST7 = 0xd7
+	jpegSOI  = 0xd8
+	jpegEOI  = 0xd9
+	jpegSOS  = 0xda
+	jpegDQT  = 0xdb
+	jpegDRI  = 0xdd
+	jpegAPP0 = 0xe0
+	jpegAPPE = 0xee
+)
+
+// jpegUnscaledQuant 未按质量缩放的量化表，按 Z 字形顺序排列，取自 JPEG 规范附录 K.1
+var jpegUnscaledQuant = [2][64]byte{
+	// 亮度
+	{
+		16, 11, 12, 14, 12, 10, 16, 14,
+		13, 14, 18, 17, 16, 19, 24, 40,
+		26, 24, 22, 22, 24, 49, 35, 37,
+		29, 40, 58, 51, 61, 60, 57, 51,
+		56, 55, 64, 72, 92, 78, 64, 68,
+		87, 69, 55, 56, 80, 109, 81, 87,
+		95, 98, 103, 104, 103, 62, 77, 113,
+		121, 112, 100, 120, 92, 101, 103, 99,
+	},
+	// 色度
+	{
+		17, 18, 18, 24, 21, 24, 47, 26,
+		26, 47, 99, 66, 56, 66, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegHuffmanSpec 霍夫曼编码表，count[i] 为长度 i+1 位的编码数，value 为按编码顺序排列的符号
+type jpegHuffmanSpec struct {
+	count [16]byte
+	value []byte
+}
+
+// jpegHuffmanSpecs 亮度直流、亮度交流、色度直流、色度交流的霍夫曼编码表，取自 JPEG 规范附录 K.3
+var jpegHuffmanSpecs = [4]jpegHuffmanSpec{
+	// 亮度直流
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// 亮度交流
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	// 色度直流
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	// 色度交流
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// jpegUnzig Z 字形顺序到自然顺序的映射
+var jpegUnzig = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegDCTCos 正向 DCT 的系数，jpegDCTCos[u][x] = C(u)/2 * cos((2x+1)uπ/16)
+var jpegDCTCos = func() (t [8][8]float64) {
+	for u := 0; u < 8; u++ {
+		c := 0.5
+		if u == 0 {
+			c = 0.5 / math.Sqrt2
+		}
+		for x := 0; x < 8; x++ {
+			t[u][x] = c * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16)
+		}
+	}
+	return t
+}()
+
+// jpegHuffmanCode 符号的霍夫曼编码
+type jpegHuffmanCode struct {
+	bits int
+	code uint32
+}
+
+// jpegWriter444 以 4:4:4 色度采样写入基线 JPEG
+type jpegWriter444 struct {
+	w     *bufio.Writer
+	quant [2][64]int32
+	codes [4][256]jpegHuffmanCode
+	acc   uint32 // 待写入的位
+	nBits int    // acc 中的位数
+}
+
+// encodeJPEG444 以 4:4:4 色度采样编码基线 JPEG，每个颜色分量都保留完整分辨率
+// 标准库编码器只支持 4:2:0，红色文字、细线等颜色细节会变模糊；4:4:4 保留颜色细节，文件更大。
+func encodeJPEG444(w io.Writer, img image.Image, quality int) error {
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 || b.Dx() > 0xffff || b.Dy() > 0xffff {
+		return errors.New("JPEG 图片尺寸无效")
+	}
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(b)
+		draw.Draw(rgba, b, img, b.Min, draw.Src)
+	}
+
+	e := &jpegWriter444{w: bufio.NewWriter(w)}
+	e.init(quality)
+	e.writeHeader(b.Dx(), b.Dy())
+
+	var blocks [3][64]float64
+	var prevDC [3]int32
+	for by := b.Min.Y; by < b.Max.Y; by += 8 {
+		for bx := b.Min.X; bx < b.Max.X; bx += 8 {
+			for i := 0; i < 64; i++ {
+				// 超出图片的部分重复边缘像素
+				x := min(bx+i%8, b.Max.X-1)
+				y := min(by+i/8, b.Max.Y-1)
+				p := rgba.Pix[rgba.PixOffset(x, y):]
+				yy, cb, cr := color.RGBToYCbCr(p[0], p[1], p[2])
+				blocks[0][i] = float64(yy) - 128
+				blocks[1][i] = float64(cb) - 128
+				blocks[2][i] = float64(cr) - 128
+			}
+			for c := 0; c < 3; c++ {
+				table := min(c, 1)
+				prevDC[c] = e.writeBlock(&blocks[c], table, prevDC[c])
+			}
+		}
+	}
+
+	e.flush()
+	_, _ = e.w.Write([]byte{0xff, jpegEOI})
+	return e.w.Flush()
+}
+
+// init 按质量缩放量化表，生成霍夫曼编码，缩放方式与标准库和 libjpeg 相同
+func (e *jpegWriter444) init(quality int) {
+	quality = max(1, min(quality, 100))
+	scale := 200 - quality*2
+	if quality < 50 {
+		scale = 5000 / quality
+	}
+	for i := range e.quant {
+		for j, q := range jpegUnscaledQuant[i] {
+			e.quant[i][j] = max(1, min((int32(q)*int32(scale)+50)/100, 255))
+		}
+	}
+
+	for i, spec := range jpegHuffmanSpecs {
+		code, k := uint32(0), 0
+		for n, count := range spec.count {
+			for j := 0; j < int(count); j++ {
+				e.codes[i][spec.value[k]] = jpegHuffmanCode{bits: n + 1, code: code}
+				code++
+				k++
+			}
+			code <<= 1
+		}
+	}
+}
+
+// writeHeader 写入 SOI、DQT、SOF0、DHT 和 SOS 标记
+func (e *jpegWriter444) writeHeader(width, height int) {
+	_, _ = e.w.Write([]byte{0xff, jpegSOI})
+
+	e.writeMarker(jpegDQT, 2*65)
+	for i := range e.quant {
+		_ = e.w.WriteByte(byte(i))
+		for _, q := range e.quant[i] {
+			_ = e.w.WriteByte(byte(q))
+		}
+	}
+
+	// 三个分量的采样因子都是 1x1，即 4:4:4
+	e.writeMarker(jpegSOF0, 6+3*3)
+	_, _ = e.w.Write([]byte{8, byte(height >> 8), byte(height), byte(width >> 8), byte(width), 3,
+		1, 0x11, 0,
+		2, 0x11, 1,
+		3, 0x11, 1,
+	})
+
+	n := 0
+	for _, spec := range jpegHuffmanSpecs {
+		n += 17 + len(spec.value)
+	}
+	e.writeMarker(jpegDHT, n)
+	for i, spec := range jpegHuffmanSpecs {
+		// 高 4 位为表类型(0 直流，1 交流)，低 4 位为表号
+		_ = e.w.WriteByte(byte(i%2)<<4 | byte(i/2))
+		_, _ = e.w.Write(spec.count[:])
+		_, _ = e.w.Write(spec.value)
+	}
+
+	e.writeMarker(jpegSOS, 1+3*2+3)
+	_, _ = e.w.Write([]byte{3, 1, 0x00, 2, 0x11, 3, 0x11, 0, 63, 0})
+}
+
+// writeMarker 写入标记和段长度，length 不包含长度字段本身
+func (e *jpegWriter444) writeMarker(marker byte, length int) {
+	length += 2
+	_, _ = e.w.Write([]byte{0xff, marker, byte(length >> 8), byte(length)})
+}
+
+// writeBlock 对 8x8 块做 DCT、量化和霍夫曼编码，table 为 0 时使用亮度表，返回块的直流分量
+func (e *jpegWriter444) writeBlock(block *[64]float64, table int, prevDC int32) int32 {
+	// 先对每一行做一维 DCT，再对每一列做一维 DCT
+	var tmp, coef [64]float64
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for x := 0; x < 8; x++ {
+				sum += jpegDCTCos[u][x] * block[y*8+x]
+			}
+			tmp[y*8+u] = sum
+		}
+	}
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			var sum float64
+			for y := 0; y < 8; y++ {
+				sum += jpegDCTCos[v][y] * tmp[y*8+u]
+			}
+			coef[v*8+u] = sum
+		}
+	}
+
+	var zz [64]int32
+	for k := 0; k < 64; k++ {
+		zz[k] = int32(math.Round(coef[jpegUnzig[k]] / float64(e.quant[table][k])))
+	}
+
+	dc, ac := &e.codes[table*2], &e.codes[table*2+1]
+	e.writeValue(dc, 0, zz[0]-prevDC)
+	run := 0
+	for k := 1; k < 64; k++ {
+		if zz[k] == 0 {
+			run++
+			continue
+		}
+		for run > 15 {
+			e.writeCode(ac[0xf0])
+			run -= 16
+		}
+		e.writeValue(ac, run, zz[k])
+		run = 0
+	}
+	if run > 0 {
+		e.writeCode(ac[0x00])
+	}
+	return zz[0]
+}
+
+// writeValue 写入游程和数值类别的霍夫曼编码，再写入数值本身
+func (e *jpegWriter444) writeValue(codes *[256]jpegHuffmanCode, run int, v int32) {
+	a, bits := v, v
+	if a < 0 {
+		a = -a
+		bits = v - 1 // 负数写入反码
+	}
+	n := 0
+	for ; a > 0; a >>= 1 {
+		n++
+	}
+	e.writeCode(codes[run<<4|n])
+	if n > 0 {
+		e.writeBits(uint32(bits)&(1<<n-1), n)
+	}
+}
+
+func (e *jpegWriter444) writeCode(c jpegHuffmanCode) {
+	e.writeBits(c.code, c.bits)
+}
+
+// writeBits 写入 n 位，0xff 字节后插入 0x00
+func (e *jpegWriter444) writeBits(bits uint32, n int) {
+	e.acc = e.acc<<n | bits
+	e.nBits += n
+	for e.nBits >= 8 {
+		e.nBits -= 8
+		b := byte(e.acc >> e.nBits)
+		_ = e.w.WriteByte(b)
+		if b == 0xff {
+			_ = e.w.WriteByte(0)
+		}
+	}
+}
+
+// flush 用 1 填充最后一个字节
+func (e *jpegWriter444) flush() {
+	if e.nBits > 0 {
+		e.writeBits(1<<(8-e.nBits)-1, 8-e.nBits)
+	}
+}
@@ -0,0 +1,654 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// jpegReduceScale JPEG 缩小解码的倍数，每个 8x8 块只解码直流分量，得到 1/8 尺寸的图片
+const jpegReduceScale = 8
+
+// jpegHuffmanLUTBits 霍夫曼快速查找表的位数，更长的编码逐位查找
+const jpegHuffmanLUTBits = 9
+
+// errJPEGReduceUnsupported 图片不支持缩小解码，例如渐进式编码、CMYK 或 RGB 颜色空间
+var errJPEGReduceUnsupported = errors.New("JPEG 不支持缩小解码")
+
+// reduceDecode 第一个处理器将 JPEG 缩小到 1/8 以下时，改为以 1/8 分辨率解码
+// 缩小解码只解码每个 8x8 块的直流分量，等同于 8 倍的盒式缩小，解码耗时和内存都大幅减少。
+// 第一个缩放处理器改为从缩小后的图片缩放到按原图尺寸规划的目标尺寸，返回新的处理器链。
+func (s *source) reduceDecode(options *ProcessorOptions, processors []Processor) []Processor {
+	if options.DisableReducedDecode || s.format != FormatJPEG || len(processors) == 0 {
+		return processors
+	}
+	zoom, ok := processors[0].(*ZoomProcessor)
+	if !ok {
+		return processors
+	}
+
+	width, height := s.size(options)
+	targetWidth, targetHeight, err := zoom.PlanSize(width, height)
+	if err != nil {
+		return processors
+	}
+	scaledWidth, scaledHeight := zoom.scaledSize(width, height)
+	if scaledWidth > width/jpegReduceScale || scaledHeight > height/jpegReduceScale || !jpegReducible(s.header) {
+		return processors
+	}
+	s.reduced = true
+
+	// 按比例计算尺寸的模式改为精确缩放，cover 和 pad 只依赖目标尺寸
+	reduced := *zoom
+	reduced.NoUpscale = false
+	if zoom.scalesOnly() {
+		reduced.Mode = ZoomModeExact
+		reduced.Width, reduced.Height = targetWidth, targetHeight
+	}
+
+	return append([]Processor{&reduced}, processors[1:]...)
+}
+
+// jpegReducible 根据 JPEG 头部判断能否缩小解码
+func jpegReducible(header []byte) bool {
+	return newJPEGReducer(bytes.NewReader(header)).readHeader() == nil
+}
+
+// decodeJPEGReduced 以 1/8 分辨率解码基线或扩展顺序编码的 JPEG 图片
+// 输出尺寸为原图尺寸除以 8 向上取整，彩色图片返回保持原色度采样的 *image.YCbCr，灰度图片返回 *image.Gray。
+func decodeJPEGReduced(r io.Reader) (image.Image, error) {
+	return newJPEGReducer(r).decode()
+}
+
+// jpegComponent JPEG 颜色分量
+type jpegComponent struct {
+	id       byte
+	h, v     int // 采样因子
+	tq       int // 量化表
+	td, ta   int // 当前扫描使用的直流和交流霍夫曼表
+	blocksX  int // 分量实际的水平块数
+	blocksY  int // 分量实际的垂直块数
+	stride   int // plane 每行的块数，包含 MCU 的填充
+	plane    []uint8
+	previous int // 上一个块的直流系数
+}
+
+// jpegHuffman 霍夫曼表，见 JPEG 标准 F.2.2.3
+type jpegHuffman struct {
+	lut     [1 << jpegHuffmanLUTBits]uint16 // 编码长度 << 8 | 值，0 表示需要逐位查找
+	minCode [17]int32
+	maxCode [17]int32 // -1 表示没有该长度的编码
+	valPtr  [17]int32
+	vals    []uint8
+}
+
+// jpegReducer JPEG 缩小解码器
+type jpegReducer struct {
+	r               *bufio.Reader
+	width, height   int
+	comps           []jpegComponent
+	hMax, vMax      int
+	quant           [4]int // 量化表的直流量化值
+	huffman         [2][4]*jpegHuffman
+	restartInterval int
+	jfif            bool
+	adobeTransform  int // Adobe APP14 的颜色变换，-1 表示没有
+	scans           int
+
+	// 熵编码数据的位缓冲
+	acc    uint32
+	nBits  int
+	marker byte // 熵编码数据中遇到的标记，之后按 0 补位
+}
+
+func newJPEGReducer(r io.Reader) *jpegReducer {
+	return &jpegReducer{r: bufio.NewReader(r), adobeTransform: -1}
+}
+
+// readHeader 读取到帧头为止，图片不支持缩小解码时返回 errJPEGReduceUnsupported
+func (d *jpegReducer) readHeader() error {
+	var soi [2]byte
+	if _, err := io.ReadFull(d.r, soi[:]); err != nil {
+		return err
+	}
+	if soi[0] != 0xff || soi[1] != jpegSOI {
+		return errors.New("JPEG 缺少 SOI 标记")
+	}
+	for d.comps == nil {
+		marker, err := d.nextMarker()
+		if err != nil {
+			return err
+		}
+		if err := d.segment(marker); err != nil {
+			return err
+		}
+	}
+	return d.checkColor()
+}
+
+// decode 解码所有扫描并生成缩小后的图片，最后一个扫描之后数据被截断时返回已解码的部分
+func (d *jpegReducer) decode() (image.Image, error) {
+	if err := d.readHeader(); err != nil {
+		return nil, err
+	}
+	for {
+		marker, err := d.nextMarker()
+		if err != nil {
+			if errors.Is(err, io.EOF) && d.scans > 0 {
+				break
+			}
+			return nil, err
+		}
+		if marker == jpegEOI {
+			break
+		}
+		if err := d.segment(marker); err != nil {
+			return nil, err
+		}
+	}
+	if d.scans == 0 {
+		return nil, errors.New("JPEG 缺少图像数据")
+	}
+	return d.image(), nil
+}
+
+// nextMarker 读取下一个标记，跳过标记前的填充字节
+func (d *jpegReducer) nextMarker() (byte, error) {
+	if d.marker != 0 {
+		marker := d.marker
+		d.marker = 0
+		return marker, nil
+	}
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0xff {
+			continue
+		}
+		for b == 0xff {
+			if b, err = d.r.ReadByte(); err != nil {
+				return 0, err
+			}
+		}
+		if b != 0 {
+			return b, nil
+		}
+	}
+}
+
+// segment 处理一个标记段
+func (d *jpegReducer) segment(marker byte) error {
+	if marker >= jpegRST0 && marker <= jpegRST7 {
+		return nil
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		return err
+	}
+	n := int(size[0])<<8 | int(size[1]) - 2
+	if n < 0 {
+		return errors.New("JPEG 标记段长度错误")
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(d.r, data); err != nil {
+		return err
+	}
+
+	switch {
+	case marker == jpegSOF0 || marker == jpegSOF1:
+		return d.parseSOF(data)
+	case marker >= 0xc2 && marker <= 0xcf && marker != jpegDHT && marker != 0xc8 && marker != 0xcc:
+		// 渐进式、无损和算术编码
+		return errJPEGReduceUnsupported
+	case marker == jpegDHT:
+		return d.parseDHT(data)
+	case marker == jpegDQT:
+		return d.parseDQT(data)
+	case marker == jpegDRI:
+		if n < 2 {
+			return errors.New("JPEG DRI 长度错误")
+		}
+		d.restartInterval = int(data[0])<<8 | int(data[1])
+	case marker == jpegSOS:
+		return d.parseSOS(data)
+	case marker == jpegAPP0:
+		d.jfif = n >= 5 && string(data[:5]) == "JFIF\x00"
+	case marker == jpegAPPE:
+		if n >= 12 && string(data[:5]) == "Adobe" {
+			d.adobeTransform = int(data[11])
+		}
+	}
+	return nil
+}
+
+// parseSOF 解析帧头，只支持 8 位精度的灰度和 YCbCr 图片
+func (d *jpegReducer) parseSOF(data []byte) error {
+	if len(data) < 6 {
+		return errors.New("JPEG SOF 长度错误")
+	}
+	if d.comps != nil {
+		return errors.New("JPEG 包含多个 SOF")
+	}
+	if data[0] != 8 {
+		return errJPEGReduceUnsupported
+	}
+	d.height = int(data[1])<<8 | int(data[2])
+	d.width = int(data[3])<<8 | int(data[4])
+	n := int(data[5])
+	if d.width == 0 || d.height == 0 || (n != 1 && n != 3) || len(data) < 6+n*3 {
+		return errJPEGReduceUnsupported
+	}
+
+	d.comps = make([]jpegComponent, n)
+	d.hMax, d.vMax = 1, 1
+	for i := range d.comps {
+		c := &d.comps[i]
+		p := data[6+i*3:]
+		c.id, c.h, c.v, c.tq = p[0], int(p[1]>>4), int(p[1]&0x0f), int(p[2])
+		if c.h < 1 || c.h > 4 || c.v < 1 || c.v > 4 || c.tq > 3 {
+			return errors.New("JPEG 分量参数错误")
+		}
+		d.hMax, d.vMax = max(d.hMax, c.h), max(d.vMax, c.v)
+	}
+	if n == 3 && ycbcrSubsampleRatio(d.comps) < 0 {
+		return errJPEGReduceUnsupported
+	}
+	return nil
+}
+
+// checkColor 彩色图片只支持 YCbCr 颜色空间，判断方式与 image/jpeg 相同
+func (d *jpegReducer) checkColor() error {
+	if len(d.comps) != 3 {
+		return nil
+	}
+	rgb := false
+	switch {
+	case d.adobeTransform >= 0:
+		rgb = d.adobeTransform == 0
+	case !d.jfif:
+		rgb = d.comps[0].id == 'R' && d.comps[1].id == 'G' && d.comps[2].id == 'B'
+	}
+	if rgb {
+		return errJPEGReduceUnsupported
+	}
+	return nil
+}
+
+// ycbcrSubsampleRatio 返回分量对应的色度采样方式，不支持时返回 -1
+func ycbcrSubsampleRatio(comps []jpegComponent) image.YCbCrSubsampleRatio {
+	if comps[1].h != 1 || comps[1].v != 1 || comps[2].h != 1 || comps[2].v != 1 {
+		return -1
+	}
+	switch [2]int{comps[0].h, comps[0].v} {
+	case [2]int{1, 1}:
+		return image.YCbCrSubsampleRatio444
+	case [2]int{1, 2}:
+		return image.YCbCrSubsampleRatio440
+	case [2]int{2, 1}:
+		return image.YCbCrSubsampleRatio422
+	case [2]int{2, 2}:
+		return image.YCbCrSubsampleRatio420
+	case [2]int{4, 1}:
+		return image.YCbCrSubsampleRatio411
+	case [2]int{4, 2}:
+		return image.YCbCrSubsampleRatio410
+	}
+	return -1
+}
+
+// parseDQT 解析量化表，只保存直流量化值
+func (d *jpegReducer) parseDQT(data []byte) error {
+	for len(data) > 0 {
+		precision, tq := data[0]>>4, int(data[0]&0x0f)
+		size := 1 + 64
+		if precision != 0 {
+			size = 1 + 128
+		}
+		if tq > 3 || len(data) < size {
+			return errors.New("JPEG DQT 错误")
+		}
+		if precision != 0 {
+			d.quant[tq] = int(data[1])<<8 | int(data[2])
+		} else {
+			d.quant[tq] = int(data[1])
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+// parseDHT 解析霍夫曼表
+func (d *jpegReducer) parseDHT(data []byte) error {
+	for len(data) > 0 {
+		if len(data) < 17 {
+			return errors.New("JPEG DHT 错误")
+		}
+		class, th := int(data[0]>>4), int(data[0]&0x0f)
+		total := 0
+		for _, count := range data[1:17] {
+			total += int(count)
+		}
+		if class > 1 || th > 3 || total > 256 || len(data) < 17+total {
+			return errors.New("JPEG DHT 错误")
+		}
+
+		h := &jpegHuffman{vals: data[17 : 17+total]}
+		code, k := int32(0), int32(0)
+		for length := 1; length <= 16; length++ {
+			count := int32(data[length])
+			h.maxCode[length] = -1
+			if count > 0 {
+				h.valPtr[length] = k
+				h.minCode[length] = code
+				for i := int32(0); i < count; i++ {
+					// 编码超出该长度的取值范围说明编码数量错误，与 image/jpeg 相同返回错误
+					if code >= 1<<length {
+						return errors.New("JPEG DHT 编码数量错误")
+					}
+					if length <= jpegHuffmanLUTBits {
+						shift := jpegHuffmanLUTBits - length
+						entry := uint16(length)<<8 | uint16(h.vals[k+i])
+						for j := code << shift; j < (code+1)<<shift; j++ {
+							h.lut[j] = entry
+						}
+					}
+					code++
+				}
+				k += count
+				h.maxCode[length] = code - 1
+			}
+			code <<= 1
+		}
+		d.huffman[class][th] = h
+		data = data[17+total:]
+	}
+	return nil
+}
+
+// parseSOS 解析扫描头并解码扫描数据
+func (d *jpegReducer) parseSOS(data []byte) error {
+	if d.comps == nil || len(data) < 1 {
+		return errors.New("JPEG SOS 错误")
+	}
+	n := int(data[0])
+	if n < 1 || n > len(d.comps) || len(data) < 1+n*2+3 {
+		return errors.New("JPEG SOS 错误")
+	}
+	if d.comps[0].plane == nil {
+		d.allocate()
+	}
+
+	scan := make([]*jpegComponent, n)
+	for i := range scan {
+		id, tables := data[1+i*2], data[2+i*2]
+		for j := range d.comps {
+			if d.comps[j].id == id {
+				scan[i] = &d.comps[j]
+			}
+		}
+		if scan[i] == nil {
+			return fmt.Errorf("JPEG SOS 分量 %d 不存在", id)
+		}
+		scan[i].td, scan[i].ta = int(tables>>4), int(tables&0x0f)
+		if scan[i].td > 3 || scan[i].ta > 3 || d.huffman[0][scan[i].td] == nil || d.huffman[1][scan[i].ta] == nil {
+			return errors.New("JPEG 缺少霍夫曼表")
+		}
+	}
+
+	d.scans++
+	return d.decodeScan(scan)
+}
+
+// allocate 按 MCU 对齐分配每个分量的直流值平面
+func (d *jpegReducer) allocate() {
+	mcusX := (d.width + 8*d.hMax - 1) / (8 * d.hMax)
+	mcusY := (d.height + 8*d.vMax - 1) / (8 * d.vMax)
+	for i := range d.comps {
+		c := &d.comps[i]
+		c.blocksX = ((d.width*c.h+d.hMax-1)/d.hMax + 7) / 8
+		c.blocksY = ((d.height*c.v+d.vMax-1)/d.vMax + 7) / 8
+		c.stride = mcusX * c.h
+		c.plane = make([]uint8, c.stride*mcusY*c.v)
+		// 没有扫描数据的块为中间值
+		for j := range c.plane {
+			c.plane[j] = 128
+		}
+	}
+}
+
+// decodeScan 解码一个扫描，单分量扫描按分量的块顺序，多分量扫描按 MCU 顺序
+func (d *jpegReducer) decodeScan(scan []*jpegComponent) error {
+	d.acc, d.nBits, d.marker = 0, 0, 0
+	for _, c := range scan {
+		c.previous = 0
+	}
+
+	mcusX := (d.width + 8*d.hMax - 1) / (8 * d.hMax)
+	mcusY := (d.height + 8*d.vMax - 1) / (8 * d.vMax)
+	if len(scan) == 1 {
+		mcusX, mcusY = scan[0].blocksX, scan[0].blocksY
+	}
+
+	mcu := 0
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			if d.restartInterval > 0 && mcu > 0 && mcu%d.restartInterval == 0 {
+				if err := d.restart(scan); err != nil {
+					return err
+				}
+			}
+			mcu++
+
+			if len(scan) == 1 {
+				if err := d.decodeBlock(scan[0], my*scan[0].stride+mx); err != nil {
+					return err
+				}
+				continue
+			}
+			for _, c := range scan {
+				for by := 0; by < c.v; by++ {
+					for bx := 0; bx < c.h; bx++ {
+						if err := d.decodeBlock(c, (my*c.v+by)*c.stride+mx*c.h+bx); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// restart 丢弃剩余的位并读取重启标记，重置直流预测值
+func (d *jpegReducer) restart(scan []*jpegComponent) error {
+	d.acc, d.nBits = 0, 0
+	for d.marker == 0 {
+		if _, err := d.scanByte(); err != nil {
+			return err
+		}
+	}
+	if d.marker < jpegRST0 || d.marker > jpegRST7 {
+		return errors.New("JPEG 缺少重启标记")
+	}
+	d.marker = 0
+	for _, c := range scan {
+		c.previous = 0
+	}
+	return nil
+}
+
+// decodeBlock 解码一个块的直流系数并跳过交流系数，只有直流分量时反 DCT 的结果为直流系数除以 8
+func (d *jpegReducer) decodeBlock(c *jpegComponent, index int) error {
+	t, err := d.decodeHuffman(d.huffman[0][c.td])
+	if err != nil {
+		return err
+	}
+	diff, err := d.receive(int(t))
+	if err != nil {
+		return err
+	}
+	c.previous += diff
+
+	ac := d.huffman[1][c.ta]
+	for k := 1; k < 64; {
+		rs, err := d.decodeHuffman(ac)
+		if err != nil {
+			return err
+		}
+		r, s := int(rs>>4), int(rs&0x0f)
+		if s == 0 {
+			if r != 15 {
+				break
+			}
+			k += 16
+			continue
+		}
+		if err := d.ensure(s); err != nil {
+			return err
+		}
+		d.nBits -= s
+		k += r + 1
+	}
+
+	v := c.previous * d.quant[c.tq]
+	if v >= 0 {
+		v = (v + 4) / 8
+	} else {
+		v = (v - 4) / 8
+	}
+	c.plane[index] = uint8(min(max(v+128, 0), 255))
+	return nil
+}
+
+// scanByte 读取熵编码数据的一个字节，处理 0xff00 转义，遇到标记后返回 0
+func (d *jpegReducer) scanByte() (byte, error) {
+	if d.marker != 0 {
+		return 0, nil
+	}
+	b, err := d.r.ReadByte()
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if b != 0xff {
+		return b, nil
+	}
+	for {
+		next, err := d.r.ReadByte()
+		if err != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		switch next {
+		case 0:
+			return 0xff, nil
+		case 0xff:
+			continue
+		}
+		d.marker = next
+		return 0, nil
+	}
+}
+
+// ensure 保证位缓冲中至少有 n 位，n 不超过 16
+func (d *jpegReducer) ensure(n int) error {
+	for d.nBits < n {
+		b, err := d.scanByte()
+		if err != nil {
+			return err
+		}
+		d.acc = d.acc<<8 | uint32(b)
+		d.nBits += 8
+	}
+	return nil
+}
+
+// peek 返回位缓冲中的前 n 位
+func (d *jpegReducer) peek(n int) int32 {
+	return int32(d.acc>>(d.nBits-n)) & (1<<n - 1)
+}
+
+// decodeHuffman 解码一个霍夫曼编码的值
+func (d *jpegReducer) decodeHuffman(h *jpegHuffman) (uint8, error) {
+	if err := d.ensure(16); err != nil {
+		return 0, err
+	}
+	if entry := h.lut[d.peek(jpegHuffmanLUTBits)]; entry != 0 {
+		d.nBits -= int(entry >> 8)
+		return uint8(entry), nil
+	}
+	for length := jpegHuffmanLUTBits + 1; length <= 16; length++ {
+		if code := d.peek(length); code <= h.maxCode[length] {
+			i := h.valPtr[length] + code - h.minCode[length]
+			if i < 0 || int(i) >= len(h.vals) {
+				break
+			}
+			d.nBits -= length
+			return h.vals[i], nil
+		}
+	}
+	return 0, errors.New("JPEG 霍夫曼编码错误")
+}
+
+// receive 读取 s 位并按 JPEG 标准 F.2.2.1 扩展为有符号数
+func (d *jpegReducer) receive(s int) (int, error) {
+	if s == 0 {
+		return 0, nil
+	}
+	if s > 16 {
+		return 0, errors.New("JPEG 直流系数错误")
+	}
+	if err := d.ensure(s); err != nil {
+		return 0, err
+	}
+	v := int(d.peek(s))
+	d.nBits -= s
+	if v < 1<<(s-1) {
+		v -= 1<<s - 1
+	}
+	return v, nil
+}
+
+// image 将直流值平面裁剪为缩小后的图片
+func (d *jpegReducer) image() image.Image {
+	rect := image.Rect(0, 0, (d.width+jpegReduceScale-1)/jpegReduceScale, (d.height+jpegReduceScale-1)/jpegReduceScale)
+	if len(d.comps) == 1 {
+		gray := image.NewGray(rect)
+		copyPlane(gray.Pix, gray.Stride, rect.Dy(), &d.comps[0])
+		return gray
+	}
+
+	img := image.NewYCbCr(rect, ycbcrSubsampleRatio(d.comps))
+	copyPlane(img.Y, img.YStride, rect.Dy(), &d.comps[0])
+	chromaHeight := len(img.Cb) / img.CStride
+	copyPlane(img.Cb, img.CStride, chromaHeight, &d.comps[1])
+	copyPlane(img.Cr, img.CStride, chromaHeight, &d.comps[2])
+	return img
+}
+
+// copyPlane 将分量的直流值平面复制到图片平面
+func copyPlane(dst []uint8, stride, rows int, c *jpegComponent) {
+	for y := 0; y < rows; y++ {
+		copy(dst[y*stride:y*stride+stride], c.plane[y*c.stride:])
+	}
+}
@@ -0,0 +1,274 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// gradientImage 水平红色渐变、垂直绿色渐变的图片
+func gradientImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetRGBA(x, y, color.RGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeGradientJPEG(t testing.TB, img image.Image) []byte {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// maxChannelDiff 两张图片对应像素 RGB 通道的最大差异
+func maxChannelDiff(a, b image.Image) int {
+	diff := 0
+	bounds := a.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.RGBAModel.Convert(a.At(x, y)).(color.RGBA)
+			cb := color.RGBAModel.Convert(b.At(x, y)).(color.RGBA)
+			diff = max(diff, absDiff(int(ca.R)-int(cb.R)), absDiff(int(ca.G)-int(cb.G)), absDiff(int(ca.B)-int(cb.B)))
+		}
+	}
+	return diff
+}
+
+func TestDecodeJPEGReduced(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 100, 60))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i % gray.Stride * 2)
+	}
+	// 4:2:0 采样的色度直流值是 16x16 像素的平均值，渐变图片的误差较大
+	tests := []struct {
+		name    string
+		img     image.Image
+		maxDiff int
+	}{
+		{"ycbcr420", gradientImage(203, 157), 12},
+		{"gray", gray, 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			img := tc.img
+			data := encodeGradientJPEG(t, img)
+			reduced, err := decodeJPEGReduced(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("缩小解码失败: %v", err)
+			}
+			b := img.Bounds()
+			if want := image.Rect(0, 0, (b.Dx()+7)/8, (b.Dy()+7)/8); reduced.Bounds() != want {
+				t.Fatalf("尺寸期望 %v，实际 %v", want, reduced.Bounds())
+			}
+
+			// 与完整解码后按 8 倍盒式缩小的结果接近
+			full, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := maxChannelDiff(reduced, boxShrink(full, full.Bounds(), 8)); diff > tc.maxDiff {
+				t.Fatalf("与完整解码的差异过大: %d", diff)
+			}
+		})
+	}
+
+	// 渐进式编码不支持缩小解码
+	progressive := []byte{0xff, 0xd8, 0xff, 0xc2, 0x00, 0x0b, 8, 0, 16, 0, 16, 1, 1, 0x11, 0}
+	if jpegReducible(progressive) {
+		t.Fatal("期望渐进式编码不支持缩小解码")
+	}
+	if _, err := decodeJPEGReduced(bytes.NewReader([]byte("not jpeg"))); err == nil {
+		t.Fatal("期望格式错误")
+	}
+}
+
+// corruptDHT 将第一个霍夫曼表的编码数量改为 3 个长度为 1 的编码
+func corruptDHT(t *testing.T, data []byte) []byte {
+	i := bytes.Index(data, []byte{0xff, jpegDHT})
+	if i < 0 {
+		t.Fatal("缺少 DHT")
+	}
+	corrupted := bytes.Clone(data)
+	counts := corrupted[i+5 : i+21]
+	counts[0], counts[1] = 3, 0
+	return corrupted
+}
+
+func TestDecodeJPEGReducedMalformed(t *testing.T) {
+	data := corruptDHT(t, encodeGradientJPEG(t, gradientImage(256, 256)))
+	if _, err := decodeJPEGReduced(bytes.NewReader(data)); err == nil {
+		t.Fatal("期望霍夫曼表错误")
+	}
+	// 缩小解码失败时返回错误，不能 panic
+	if _, err := ProcessImage(data, []Processor{NewZoomWidthProcessor(10)}, nil); err == nil {
+		t.Fatal("期望解码错误")
+	}
+}
+
+func FuzzDecodeJPEGReduced(f *testing.F) {
+	gray := image.NewGray(image.Rect(0, 0, 40, 24))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i * 7)
+	}
+	f.Add(encodeGradientJPEG(f, gradientImage(35, 27)))
+	f.Add(encodeGradientJPEG(f, gray))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// 限制图片尺寸，避免模糊测试分配过多内存
+		d := newJPEGReducer(bytes.NewReader(data))
+		if err := d.readHeader(); err != nil || d.width*d.height > 1<<22 {
+			return
+		}
+		img, err := decodeJPEGReduced(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if want := image.Rect(0, 0, (d.width+7)/8, (d.height+7)/8); img.Bounds() != want {
+			t.Fatalf("尺寸期望 %v，实际 %v", want, img.Bounds())
+		}
+	})
+}
+
+func TestProcessImageReducedDecode(t *testing.T) {
+	data := encodeGradientJPEG(t, gradientImage(800, 600))
+
+	tests := []struct {
+		name          string
+		processor     *ZoomProcessor
+		width, height int
+	}{
+		{"ratio", NewZoomRatioProcessor(0.1), 80, 60},
+		{"width", NewZoomWidthProcessor(50), 50, 38},
+		{"cover", NewZoomCoverProcessor(60, 60, CutPositionCenter), 60, 60},
+		{"pad", NewZoomPadProcessor(60, 60, testWhite), 60, 60},
+		{"不满足缩小比例", NewZoomWidthProcessor(200), 200, 150},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := ProcessImage(data, []Processor{tc.processor}, &ProcessorOptions{Format: FormatPNG})
+			if err != nil {
+				t.Fatalf("处理失败: %v", err)
+			}
+			full, err := ProcessImage(data, []Processor{tc.processor}, &ProcessorOptions{
+				Format:               FormatPNG,
+				DisableReducedDecode: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reducedImg, _, _ := image.Decode(bytes.NewReader(out))
+			fullImg, _, _ := image.Decode(bytes.NewReader(full))
+			if b := reducedImg.Bounds(); b.Dx() != tc.width || b.Dy() != tc.height || b != fullImg.Bounds() {
+				t.Fatalf("尺寸期望 %dx%d，实际 %v，完整解码 %v", tc.width, tc.height, b, fullImg.Bounds())
+			}
+			if diff := maxChannelDiff(reducedImg, fullImg); diff > 12 {
+				t.Fatalf("与完整解码的差异过大: %d", diff)
+			}
+		})
+	}
+}
+
+func TestProgressiveScaler(t *testing.T) {
+	if f := shrinkFactor(1000, 800, 100, 100); f != 8 {
+		t.Fatalf("缩小倍数期望 8，实际 %d", f)
+	}
+	if f := shrinkFactor(150, 150, 100, 100); f != 1 {
+		t.Fatalf("缩小倍数期望 1，实际 %d", f)
+	}
+
+	img := gradientImage(1000, 700)
+	progressive, err := NewZoomProcessor(100, 70).WithScaler(NewProgressiveScaler(nil)).Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	area, _ := NewZoomProcessor(100, 70).WithScaler(AreaAverage).Process(img)
+	if diff := maxChannelDiff(progressive, area); diff > 4 {
+		t.Fatalf("与面积平均的差异过大: %d", diff)
+	}
+
+	// fast- 前缀可以与 linear- 前缀组合，并且可以缓存
+	scaler, ok := parseScaler("fast-linear-lanczos3")
+	if !ok || scaler != NewProgressiveScaler(NewLinearScaler(Lanczos3)) {
+		t.Fatalf("缩放算法解析错误: %v", scaler)
+	}
+	if _, err := CacheKey([]byte("data"), []Processor{NewZoomProcessor(10, 10).WithScaler(scaler)}, nil); err != nil {
+		t.Fatalf("期望可以缓存: %v", err)
+	}
+
+	// YCbCr 和灰度图片直接读取像素平面
+	ycbcr, _ := jpeg.Decode(bytes.NewReader(encodeGradientJPEG(t, img)))
+	gray := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(i % 2 * 255)
+	}
+	for name, src := range map[string]image.Image{"ycbcr": ycbcr, "gray": gray} {
+		want := boxShrink(rgbaRegion(src, src.Bounds()), src.Bounds(), 4)
+		if diff := maxChannelDiff(boxShrink(src, src.Bounds(), 4), want); diff > 2 {
+			t.Fatalf("%s 盒式缩小错误: %d", name, diff)
+		}
+	}
+}
+
+// 以下基准测试比较大图缩小为 200px 缩略图的耗时，源图为 4000x3000 以控制测试内存
+
+func BenchmarkZoomThumbnail(b *testing.B) {
+	img := gradientImage(4000, 3000)
+	scalers := []struct {
+		name      string
+		processor *ZoomProcessor
+	}{
+		{"BiLinear", NewZoomWidthProcessor(200)},
+		{"Progressive", NewZoomWidthProcessor(200).WithScaler(NewProgressiveScaler(nil))},
+		{"ProgressiveLanczos3", NewZoomWidthProcessor(200).WithScaler(NewProgressiveScaler(Lanczos3))},
+	}
+	for _, s := range scalers {
+		b.Run(s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := s.processor.Process(img); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkProcessJPEGThumbnail(b *testing.B) {
+	data := encodeGradientJPEG(b, gradientImage(4000, 3000))
+	for _, reduced := range []bool{false, true} {
+		b.Run(fmt.Sprintf("reduced=%v", reduced), func(b *testing.B) {
+			options := &ProcessorOptions{Quality: 85, DisableReducedDecode: !reduced}
+			processors := []Processor{NewZoomWidthProcessor(200)}
+			for i := 0; i < b.N; i++ {
+				if _, err := ProcessImage(data, processors, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
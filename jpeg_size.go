@@ -0,0 +1,152 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"math"
+)
+
+const (
+	// maxDownscaleRounds 按目标大小编码时最多缩小的次数
+	maxDownscaleRounds = 8
+	// minDownscaleSize 缩小后较短边的最小像素数
+	minDownscaleSize = 16
+)
+
+// JPEGSizeResult 按目标大小编码 JPEG 的结果
+type JPEGSizeResult struct {
+	Quality  int // 最终使用的质量
+	Attempts int // 编码次数，包括缩小后的编码
+	Width    int // 输出宽度，缩小后与输入不同
+	Height   int // 输出高度
+	Size     int // 输出字节数
+}
+
+// EncodeJPEGToSize 将图片编码为不超过 maxBytes 字节的 JPEG
+// 在 options.MinQuality 和 options.Quality 之间二分查找不超过 maxBytes 的最高质量；
+// 最低质量仍然超出时，options.DownscaleToFit 为 true 则使用 ZoomProcessor 按比例缩小后重新查找，
+// 否则返回 Kind 为 LimitOutputBytes 的 *LimitError。
+func EncodeJPEGToSize(w io.Writer, img image.Image, maxBytes int64, options *ProcessorOptions) (*JPEGSizeResult, error) {
+	if options == nil {
+		options = &DefaultProcessorOptions
+	}
+
+	data, result, err := encodeJPEGToSize(img, maxBytes, options)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// encodeJPEGToSize 按目标大小编码，返回编码后的数据
+func encodeJPEGToSize(img image.Image, maxBytes int64, options *ProcessorOptions) ([]byte, *JPEGSizeResult, error) {
+	hi := options.Quality
+	if hi <= 0 {
+		hi = DefaultProcessorOptions.Quality
+	}
+	lo := min(max(options.MinQuality, 1), hi)
+	if maxBytes <= 0 {
+		return nil, nil, &LimitError{Kind: LimitOutputBytes, Step: -1, Max: maxBytes}
+	}
+
+	result := &JPEGSizeResult{}
+	for round := 0; ; round++ {
+		data, quality, smallest, err := searchJPEGQuality(img, maxBytes, lo, hi, options, &result.Attempts)
+		if err != nil {
+			return nil, nil, err
+		}
+		if data != nil {
+			bounds := img.Bounds()
+			result.Quality = quality
+			result.Width = bounds.Dx()
+			result.Height = bounds.Dy()
+			result.Size = len(data)
+			return data, result, nil
+		}
+
+		// 最低质量仍然超出，按字节数比例缩小，JPEG 大小大致与像素数成正比
+		bounds := img.Bounds()
+		if !options.DownscaleToFit || round >= maxDownscaleRounds || min(bounds.Dx(), bounds.Dy()) <= minDownscaleSize {
+			return nil, nil, &LimitError{Kind: LimitOutputBytes, Step: -1, Value: int64(smallest), Max: maxBytes}
+		}
+		ratio := min(math.Sqrt(float64(maxBytes)/float64(smallest))*0.95, 0.9)
+		ratio = max(ratio, float64(minDownscaleSize)/float64(min(bounds.Dx(), bounds.Dy())))
+		img, err = NewZoomRatioProcessor(ratio).Process(img)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// searchJPEGQuality 二分查找不超过 maxBytes 的最高质量
+// 找到时返回编码数据和质量，否则返回最低质量的编码大小
+func searchJPEGQuality(img image.Image, maxBytes int64, lo, hi int, options *ProcessorOptions,
+	attempts *int,
+) ([]byte, int, int, error) {
+	opts := *options
+	encode := func(quality int) ([]byte, error) {
+		*attempts++
+		opts.Quality = quality
+		buf := new(bytes.Buffer)
+		if err := encodeJPEG(buf, img, &opts); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	// 最高质量满足时无需查找
+	data, err := encode(hi)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if int64(len(data)) <= maxBytes {
+		return data, hi, 0, nil
+	}
+	if lo == hi {
+		return nil, 0, len(data), nil
+	}
+
+	best, err := encode(lo)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if int64(len(best)) > maxBytes {
+		return nil, 0, len(best), nil
+	}
+
+	// 不变式: lo 满足，hi 超出
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		data, err := encode(mid)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if int64(len(data)) <= maxBytes {
+			lo, best = mid, data
+		} else {
+			hi = mid
+		}
+	}
+	return best, lo, 0, nil
+}
@@ -0,0 +1,117 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// noisyImage 生成高频细节较多、压缩后较大的图片
+func noisyImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	seed := uint32(1)
+	for i := range img.Pix {
+		seed = seed*1664525 + 1013904223
+		img.Pix[i] = uint8(seed >> 24)
+	}
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+func TestEncodeJPEGToSize(t *testing.T) {
+	img := noisyImage(128, 128)
+	const maxBytes = 12 << 10
+
+	buf := new(bytes.Buffer)
+	result, err := EncodeJPEGToSize(buf, img, maxBytes, &ProcessorOptions{Quality: 95, MinQuality: 5})
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	if buf.Len() > maxBytes || result.Size != buf.Len() || result.Attempts < 3 || result.Width != 128 {
+		t.Fatalf("编码结果错误: %+v, 大小 %d", result, buf.Len())
+	}
+
+	// 选择的是满足大小的最高质量
+	higher := new(bytes.Buffer)
+	if err := encodeJPEG(higher, img, &ProcessorOptions{Quality: result.Quality + 1}); err != nil {
+		t.Fatal(err)
+	}
+	if higher.Len() <= maxBytes {
+		t.Fatalf("质量 %d 也满足大小限制，期望选择更高的质量", result.Quality+1)
+	}
+
+	// 最低质量仍然超出
+	var limitErr *LimitError
+	_, err = EncodeJPEGToSize(new(bytes.Buffer), img, maxBytes, &ProcessorOptions{Quality: 95, MinQuality: 90})
+	if !errors.As(err, &limitErr) || limitErr.Kind != LimitOutputBytes {
+		t.Fatalf("期望 LimitOutputBytes 错误，实际 %v", err)
+	}
+
+	// 缩小后满足
+	buf.Reset()
+	result, err = EncodeJPEGToSize(buf, img, maxBytes, &ProcessorOptions{Quality: 95, MinQuality: 90, DownscaleToFit: true})
+	if err != nil {
+		t.Fatalf("缩小后编码失败: %v", err)
+	}
+	if buf.Len() > maxBytes || result.Width >= 128 || result.Width != result.Height || result.Quality < 90 {
+		t.Fatalf("缩小后编码结果错误: %+v, 大小 %d", result, buf.Len())
+	}
+}
+
+func TestProcessImageMaxJPEGBytes(t *testing.T) {
+	data := encodeTestPNG(t, 10, 10)
+	big := new(bytes.Buffer)
+	if err := EncodeImage(big, noisyImage(256, 256), FormatPNG, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	options := &ProcessorOptions{Format: FormatJPEG, Quality: 90, MaxJPEGBytes: 20 << 10, DownscaleToFit: true}
+	out := new(bytes.Buffer)
+	result, err := ProcessReader(bytes.NewReader(big.Bytes()), out, nil, options)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if out.Len() > 20<<10 || result.Attempts < 2 || result.Quality == 0 {
+		t.Fatalf("处理结果错误: %+v, 大小 %d", result, out.Len())
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(out.Bytes()))
+	if err != nil || cfg.Width != result.Width || cfg.ColorModel != color.YCbCrModel {
+		t.Fatalf("输出图片错误: %+v, %v", cfg, err)
+	}
+
+	// 小图片一次编码即满足
+	result, err = ProcessReader(bytes.NewReader(data), new(bytes.Buffer), nil, options)
+	if err != nil || result.Attempts != 1 || result.Quality != 90 {
+		t.Fatalf("处理结果错误: %+v, %v", result, err)
+	}
+
+	// 保留的元数据已经超出大小限制
+	withMeta := encodeTestJPEGWithMetadata(t, OrientationNormal)
+	options = &ProcessorOptions{Metadata: MetadataKeepAll, MaxJPEGBytes: 10 << 10, DownscaleToFit: true}
+	_, err = ProcessReader(bytes.NewReader(withMeta), new(bytes.Buffer), nil, options)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Kind != LimitOutputBytes || limitErr.Value <= limitErr.Max {
+		t.Fatalf("期望 LimitOutputBytes 错误，实际 %v", err)
+	}
+}
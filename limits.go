@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// ErrLimitExceeded 超出资源限制，可以通过 errors.Is 匹配所有 *LimitError
+var ErrLimitExceeded = errors.New("超出资源限制")
+
+// LimitKind 资源限制类型
+type LimitKind string
+
+const (
+	LimitInputBytes      LimitKind = "input_bytes"      // 输入数据字节数
+	LimitPixels          LimitKind = "pixels"           // 像素数
+	LimitOutputWidth     LimitKind = "output_width"     // 输出宽度
+	LimitOutputHeight    LimitKind = "output_height"    // 输出高度
+	LimitFrames          LimitKind = "frames"           // GIF 动画帧数
+	LimitOutputBytes     LimitKind = "output_bytes"     // JPEG 输出字节数
+	LimitAnimationPixels LimitKind = "animation_pixels" // GIF 动画合成后所有帧的像素总数
+	LimitSeamCarve       LimitKind = "seam_carve"       // 接缝裁剪的计算量
+)
+
+// LimitError 超出资源限制的错误
+type LimitError struct {
+	Kind  LimitKind // 限制类型
+	Step  int       // 超出限制的处理步骤序号，-1 表示输入图片，处理器返回的错误由 Plan 和 ProcessWithContext 填写
+	Value int64     // 实际值或预测值
+	Max   int64     // 限制值
+}
+
+func (e *LimitError) Error() string {
+	where := "输入图片"
+	if e.Step >= 0 {
+		where = fmt.Sprintf("步骤 %d", e.Step)
+	}
+
+	switch e.Kind {
+	case LimitInputBytes:
+		return fmt.Sprintf("输入数据超过 %d 字节", e.Max)
+	case LimitPixels:
+		return fmt.Sprintf("%s像素数 %d 超过限制 %d", where, e.Value, e.Max)
+	case LimitOutputWidth:
+		return fmt.Sprintf("输出宽度 %d 超过限制 %d", e.Value, e.Max)
+	case LimitOutputHeight:
+		return fmt.Sprintf("输出高度 %d 超过限制 %d", e.Value, e.Max)
+	case LimitOutputBytes:
+		return fmt.Sprintf("输出数据 %d 字节超过限制 %d", e.Value, e.Max)
+	case LimitFrames:
+		return fmt.Sprintf("动画帧数 %d 超过限制 %d", e.Value, e.Max)
+	case LimitAnimationPixels:
+		return fmt.Sprintf("动画像素总数 %d 超过限制 %d", e.Value, e.Max)
+	case LimitSeamCarve:
+		return fmt.Sprintf("接缝裁剪的计算量 %d 超过限制 %d", e.Value, e.Max)
+	default:
+		return fmt.Sprintf("%s超出资源限制 %s", where, e.Kind)
+	}
+}
+
+// Is 使 errors.Is(err, ErrLimitExceeded) 成立
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// hasSizeLimits 是否设置了需要在解码前检查的尺寸限制
+func (o *ProcessorOptions) hasSizeLimits() bool {
+	return o.MaxPixels > 0 || o.MaxOutputWidth > 0 || o.MaxOutputHeight > 0
+}
+
+// checkPixels 检查像素数，step 为 -1 表示输入图片
+// 宽高的乘积可能溢出 int64，先用除法比较，超出限制时 Value 最大为 math.MaxInt64。
+func (o *ProcessorOptions) checkPixels(step, width, height int) error {
+	if width <= 0 || height <= 0 {
+		if step < 0 {
+			return fmt.Errorf("无效的输入图片尺寸: %dx%d", width, height)
+		}
+		return fmt.Errorf("步骤 %d 的输出尺寸无效: %dx%d", step, width, height)
+	}
+	if o.MaxPixels > 0 && int64(width) > o.MaxPixels/int64(height) {
+		pixels := int64(math.MaxInt64)
+		if int64(width) <= math.MaxInt64/int64(height) {
+			pixels = int64(width) * int64(height)
+		}
+		return &LimitError{Kind: LimitPixels, Step: step, Value: pixels, Max: o.MaxPixels}
+	}
+	return nil
+}
+
+// checkFrames 检查动画帧数
+func (o *ProcessorOptions) checkFrames(frames int) error {
+	if o.MaxFrames > 0 && frames > o.MaxFrames {
+		return &LimitError{Kind: LimitFrames, Step: -1, Value: int64(frames), Max: int64(o.MaxFrames)}
+	}
+	return nil
+}
+
+// checkAnimation 检查动画帧数和合成后所有帧的像素总数，canvas 为合成画布
+func (o *ProcessorOptions) checkAnimation(frames int, canvas image.Rectangle) error {
+	if err := o.checkFrames(frames); err != nil {
+		return err
+	}
+	total := int64(frames) * int64(canvas.Dx()) * int64(canvas.Dy())
+	if o.MaxAnimationPixels > 0 && total > o.MaxAnimationPixels {
+		return &LimitError{Kind: LimitAnimationPixels, Step: -1, Value: total, Max: o.MaxAnimationPixels}
+	}
+	return nil
+}
+
+// checkOutputSize 检查输出尺寸
+func (o *ProcessorOptions) checkOutputSize(width, height int) error {
+	if o.MaxOutputWidth > 0 && width > o.MaxOutputWidth {
+		return &LimitError{Kind: LimitOutputWidth, Step: -1, Value: int64(width), Max: int64(o.MaxOutputWidth)}
+	}
+	if o.MaxOutputHeight > 0 && height > o.MaxOutputHeight {
+		return &LimitError{Kind: LimitOutputHeight, Step: -1, Value: int64(height), Max: int64(o.MaxOutputHeight)}
+	}
+	return nil
+}
+
+// checkPlan 检查输入尺寸以及处理器链规划中每一步的输出尺寸
+// 规划不完整时最终尺寸在处理完成后再检查
+func (o *ProcessorOptions) checkPlan(width, height int, plan *PlanResult) error {
+	if err := o.checkPixels(-1, width, height); err != nil {
+		return err
+	}
+
+	for _, step := range plan.Steps {
+		if err := o.checkPixels(step.Index, step.OutputWidth, step.OutputHeight); err != nil {
+			return err
+		}
+	}
+
+	if plan.Partial {
+		return nil
+	}
+	return o.checkOutputSize(plan.Width, plan.Height)
+}
+
+// limitedReader 读取超过限制时返回 *LimitError
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	max       int64
+	err       error // 超出限制后的错误，用于替换解码器包装后的错误
+}
+
+func newLimitedReader(r io.Reader, max int64) *limitedReader {
+	return &limitedReader{r: r, remaining: max, max: max}
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	// 多读一个字节以判断是否超出限制
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.err = &LimitError{Kind: LimitInputBytes, Step: -1, Value: l.max - l.remaining, Max: l.max}
+		return n + int(l.remaining), l.err
+	}
+	return n, err
+}
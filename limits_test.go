@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"image"
+	"testing"
+)
+
+// countingProcessor 记录是否被调用，且不实现尺寸预测
+type countingProcessor struct {
+	called bool
+	size   int
+}
+
+func (p *countingProcessor) Process(img image.Image) (image.Image, error) {
+	p.called = true
+	return image.NewRGBA(image.Rect(0, 0, p.size, p.size)), nil
+}
+
+func TestProcessImageLimits(t *testing.T) {
+	data := encodeTestPNG(t, 40, 20)
+
+	tests := []struct {
+		name       string
+		processors []Processor
+		options    ProcessorOptions
+		kind       LimitKind
+		step       int
+	}{
+		{"输入字节数", nil, ProcessorOptions{MaxInputBytes: 10}, LimitInputBytes, -1},
+		{"输入像素数", nil, ProcessorOptions{MaxPixels: 799}, LimitPixels, -1},
+		{"放大像素数", []Processor{NewZoomWidthProcessor(20), NewZoomRatioProcessor(4)},
+			ProcessorOptions{MaxPixels: 1000}, LimitPixels, 1},
+		{"旋转后高度", []Processor{NewRotateProcessor(90)}, ProcessorOptions{MaxOutputHeight: 30}, LimitOutputHeight, -1},
+		{"切割后高度", []Processor{NewCutSquareProcessor("center")}, ProcessorOptions{MaxOutputHeight: 19}, LimitOutputHeight, -1},
+		{"像素数溢出", []Processor{NewZoomProcessor(4294967296, 4294967296)}, ProcessorOptions{MaxPixels: 1000}, LimitPixels, 0},
+		{"像素数溢出为负数", []Processor{NewZoomProcessor(3037000500, 3037000500)}, ProcessorOptions{MaxPixels: 1000}, LimitPixels, 0},
+		{"无法预测的输出尺寸", []Processor{&countingProcessor{size: 64}}, ProcessorOptions{MaxOutputWidth: 50}, LimitOutputWidth, -1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ProcessImage(data, tc.processors, &tc.options)
+			if !errors.Is(err, ErrLimitExceeded) {
+				t.Fatalf("期望 ErrLimitExceeded，实际 %v", err)
+			}
+
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || limitErr.Kind != tc.kind || limitErr.Step != tc.step {
+				t.Fatalf("限制错误不匹配: %+v", limitErr)
+			}
+		})
+	}
+
+	// 超出限制时不调用处理器
+	p := &countingProcessor{size: 10}
+	_, err := ProcessImage(data, []Processor{NewZoomRatioProcessor(10), p}, &ProcessorOptions{MaxPixels: 10_000})
+	if !errors.Is(err, ErrLimitExceeded) || p.called {
+		t.Fatalf("期望在处理前返回限制错误，实际 %v, called=%v", err, p.called)
+	}
+
+	// 未超出限制
+	out, err := ProcessImage(data, []Processor{NewZoomWidthProcessor(20)}, &ProcessorOptions{
+		MaxInputBytes: int64(len(data)), MaxPixels: 800, MaxOutputWidth: 20, MaxOutputHeight: 10,
+	})
+	if err != nil || len(out) == 0 {
+		t.Fatalf("处理失败: %v", err)
+	}
+}
+
+func TestProcessImageLimitsOrientation(t *testing.T) {
+	// 40x20 图片校正方向后为 20x40
+	data := encodeTestJPEG(t, 40, 20, OrientationRotate90)
+
+	if _, err := ProcessImage(data, nil, &ProcessorOptions{MaxOutputWidth: 20}); err != nil {
+		t.Fatalf("校正方向后宽度未超出限制: %v", err)
+	}
+
+	_, err := ProcessImage(data, nil, &ProcessorOptions{MaxOutputHeight: 30})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Kind != LimitOutputHeight || limitErr.Value != 40 {
+		t.Fatalf("期望高度超出限制，实际 %v", err)
+	}
+}
@@ -0,0 +1,568 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"regexp"
+	"sort"
+)
+
+// MetadataPolicy 元数据处理策略
+type MetadataPolicy int
+
+const (
+	MetadataStrip     MetadataPolicy = iota // 删除所有元数据，默认
+	MetadataKeepAll                         // 保留所有元数据，包括位置信息
+	MetadataAllowlist                       // 只保留 MetadataKeep 中列出的元数据，始终删除位置信息
+)
+
+// MetadataField 白名单策略下可保留的元数据
+type MetadataField string
+
+const (
+	MetadataICC       MetadataField = "icc"       // ICC 色彩配置
+	MetadataXMP       MetadataField = "xmp"       // XMP 数据，删除 exif 命名空间中的 GPS 属性
+	MetadataExif      MetadataField = "exif"      // 除位置信息和厂商私有数据外的所有 EXIF 字段
+	MetadataCopyright MetadataField = "copyright" // 版权
+	MetadataArtist    MetadataField = "artist"    // 作者
+	MetadataCamera    MetadataField = "camera"    // 相机厂商和型号
+	MetadataDateTime  MetadataField = "datetime"  // 修改时间和拍摄时间
+)
+
+// Metadata 图片元数据的原始数据
+type Metadata struct {
+	EXIF []byte // TIFF 结构的 EXIF 数据，不包含 "Exif\0\0" 前缀
+	ICC  []byte // ICC 色彩配置
+	XMP  []byte // XMP 数据包
+}
+
+// JPEG 标记
+const (
+	jpegMarkerSOI  = 0xd8
+	jpegMarkerAPP0 = 0xe0
+	jpegMarkerAPP1 = 0xe1
+	jpegMarkerAPP2 = 0xe2
+	jpegMarkerAPPF = 0xef
+	jpegMarkerCOM  = 0xfe
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	xmpHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+	pngHeader  = []byte("\x89PNG\r\n\x1a\n")
+)
+
+const (
+	// jpegMaxSegment JPEG 段的最大数据长度，不包含标记和长度字段
+	jpegMaxSegment = 65533
+	// pngMaxMetaChunk 读取的 PNG 元数据块的最大长度
+	pngMaxMetaChunk = 16 << 20
+	// pngXMPKeyword PNG 中 XMP 数据的关键字
+	pngXMPKeyword = "XML:com.adobe.xmp"
+)
+
+// ReadMetadata 读取 JPEG 或 PNG 图片的元数据，不解码像素
+// 其他格式或没有元数据时返回空的 Metadata
+func ReadMetadata(r io.Reader) (*Metadata, error) {
+	meta, _, err := readMetadata(r)
+	return meta, err
+}
+
+// ExifInfo 解析 EXIF 中的常用字段，没有 EXIF 数据时返回 ErrExifNotFound
+func (m *Metadata) ExifInfo() (*ExifInfo, error) {
+	if len(m.EXIF) == 0 {
+		return nil, ErrExifNotFound
+	}
+	t, err := parseTIFF(m.EXIF)
+	if err != nil {
+		return nil, err
+	}
+	return t.info(), nil
+}
+
+// empty 是否没有任何元数据
+func (m *Metadata) empty() bool {
+	return m == nil || len(m.EXIF) == 0 && len(m.ICC) == 0 && len(m.XMP) == 0
+}
+
+// readMetadata 读取图片头部的元数据，返回已读取的原始数据，调用方可以拼接剩余数据继续解码
+// 头部数据不完整时不返回错误，交给解码器报告
+func readMetadata(r io.Reader) (*Metadata, []byte, error) {
+	consumed := new(bytes.Buffer)
+	tee := io.TeeReader(r, consumed)
+	meta := &Metadata{}
+
+	var sig [8]byte
+	if _, err := io.ReadFull(tee, sig[:2]); err != nil {
+		return meta, consumed.Bytes(), nilIfEOF(err)
+	}
+
+	var err error
+	switch {
+	case sig[0] == 0xff && sig[1] == jpegMarkerSOI:
+		err = readJPEGMetadata(tee, meta)
+	case sig[0] == pngHeader[0] && sig[1] == pngHeader[1]:
+		if _, err = io.ReadFull(tee, sig[2:]); err == nil && bytes.Equal(sig[:], pngHeader) {
+			err = readPNGMetadata(tee, meta)
+		}
+	}
+
+	return meta, consumed.Bytes(), nilIfEOF(err)
+}
+
+// readJPEGMetadata 读取 SOI 之后的 APPn 和 COM 段，直到遇到其他标记
+func readJPEGMetadata(r io.Reader, meta *Metadata) error {
+	// ICC 配置可能分为多段，按序号拼接
+	iccChunks := map[byte][]byte{}
+
+	for {
+		var marker [4]byte
+		if _, err := io.ReadFull(r, marker[:2]); err != nil {
+			return err
+		}
+		m := marker[1]
+		if marker[0] != 0xff || !(m >= jpegMarkerAPP0 && m <= jpegMarkerAPPF || m == jpegMarkerCOM) {
+			break
+		}
+
+		if _, err := io.ReadFull(r, marker[2:]); err != nil {
+			return err
+		}
+		n := int(binary.BigEndian.Uint16(marker[2:])) - 2
+		if n < 0 {
+			break
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+
+		switch {
+		case m == jpegMarkerAPP1 && bytes.HasPrefix(data, exifHeader) && meta.EXIF == nil:
+			meta.EXIF = data[len(exifHeader):]
+		case m == jpegMarkerAPP1 && bytes.HasPrefix(data, xmpHeader) && meta.XMP == nil:
+			meta.XMP = data[len(xmpHeader):]
+		case m == jpegMarkerAPP2 && bytes.HasPrefix(data, iccHeader) && len(data) > len(iccHeader)+2:
+			iccChunks[data[len(iccHeader)]] = data[len(iccHeader)+2:]
+		}
+	}
+
+	if len(iccChunks) > 0 {
+		seqs := make([]int, 0, len(iccChunks))
+		for seq := range iccChunks {
+			seqs = append(seqs, int(seq))
+		}
+		sort.Ints(seqs)
+		for _, seq := range seqs {
+			meta.ICC = append(meta.ICC, iccChunks[byte(seq)]...)
+		}
+	}
+
+	return nil
+}
+
+// readPNGMetadata 读取 IDAT 之前的 iCCP、eXIf 和 XMP 数据块
+func readPNGMetadata(r io.Reader, meta *Metadata) error {
+	for {
+		var head [8]byte
+		if _, err := io.ReadFull(r, head[:]); err != nil {
+			return err
+		}
+		n := binary.BigEndian.Uint32(head[:4])
+		typ := string(head[4:])
+		if typ == "IDAT" || typ == "IEND" || n > pngMaxMetaChunk {
+			return nil
+		}
+
+		data := make([]byte, n+4)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		data = data[:n]
+
+		switch typ {
+		case "eXIf":
+			meta.EXIF = data
+		case "iCCP":
+			meta.ICC = parsePNGICCP(data)
+		case "iTXt":
+			if xmp := parsePNGXMP(data); xmp != nil {
+				meta.XMP = xmp
+			}
+		}
+	}
+}
+
+// parsePNGICCP 解析 iCCP 数据块: 名称, 0, 压缩方式, zlib 数据
+func parsePNGICCP(data []byte) []byte {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 || i+2 > len(data) {
+		return nil
+	}
+	return inflate(data[i+2:])
+}
+
+// parsePNGXMP 解析关键字为 XML:com.adobe.xmp 的 iTXt 数据块
+func parsePNGXMP(data []byte) []byte {
+	prefix := pngXMPKeyword + "\x00"
+	if !bytes.HasPrefix(data, []byte(prefix)) || len(data) < len(prefix)+2 {
+		return nil
+	}
+	compressed := data[len(prefix)] == 1
+	rest := data[len(prefix)+2:]
+
+	// 跳过语言标签和翻译后的关键字
+	for k := 0; k < 2; k++ {
+		i := bytes.IndexByte(rest, 0)
+		if i < 0 {
+			return nil
+		}
+		rest = rest[i+1:]
+	}
+
+	if compressed {
+		return inflate(rest)
+	}
+	return rest
+}
+
+func inflate(data []byte) []byte {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = zr.Close() }()
+
+	out, err := io.ReadAll(io.LimitReader(zr, pngMaxMetaChunk))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// nilIfEOF 头部数据不完整时交给解码器报告错误
+func nilIfEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
+// filter 按策略筛选需要写入输出图片的元数据
+// orientationApplied 为 true 时像素已经按 EXIF 方向校正，输出的方向标签需要重置
+func (m *Metadata) filter(policy MetadataPolicy, keep []MetadataField, orientationApplied bool) *Metadata {
+	if m.empty() {
+		return nil
+	}
+
+	switch policy {
+	case MetadataKeepAll:
+		out := *m
+		if orientationApplied {
+			out.EXIF = resetExifOrientation(m.EXIF)
+		}
+		return &out
+	case MetadataAllowlist:
+		fields := make(map[MetadataField]bool, len(keep))
+		for _, f := range keep {
+			fields[f] = true
+		}
+
+		out := &Metadata{}
+		if fields[MetadataICC] {
+			out.ICC = m.ICC
+		}
+		if fields[MetadataXMP] {
+			out.XMP = stripXMPLocation(m.XMP)
+		}
+		out.EXIF = filterExif(m.EXIF, fields, orientationApplied)
+		return out
+	default:
+		return nil
+	}
+}
+
+// xmpExifNamespace 匹配绑定到 EXIF 命名空间的 XMP 前缀，GPS 属性都在该命名空间中
+var xmpExifNamespace = regexp.MustCompile(`xmlns:([\w.-]+)\s*=\s*["']http://ns\.adobe\.com/exif/1\.0/["']`)
+
+// stripXMPLocation 删除 XMP 中 EXIF 命名空间的 GPS 属性，包括属性和元素两种写法
+// 无法完整删除时，例如元素没有结束标签，删除整个 XMP 数据包。
+func stripXMPLocation(xmp []byte) []byte {
+	if len(xmp) == 0 {
+		return nil
+	}
+
+	prefixes := map[string]bool{"exif": true}
+	for _, m := range xmpExifNamespace.FindAllSubmatch(xmp, -1) {
+		prefixes[string(m[1])] = true
+	}
+
+	out := xmp
+	for prefix := range prefixes {
+		q := regexp.QuoteMeta(prefix)
+		attr := regexp.MustCompile(`\s+` + q + `:GPS[\w.-]*\s*=\s*(?:"[^"]*"|'[^']*')`)
+		out = attr.ReplaceAll(out, nil)
+
+		start := regexp.MustCompile(`<` + q + `:(GPS[\w.-]*)(?:\s[^>]*)?/?>`)
+		for {
+			loc := start.FindSubmatchIndex(out)
+			if loc == nil {
+				break
+			}
+			end := loc[1]
+			if out[end-2] != '/' {
+				closing := []byte("</" + prefix + ":" + string(out[loc[2]:loc[3]]) + ">")
+				i := bytes.Index(out[end:], closing)
+				if i < 0 {
+					return nil
+				}
+				end += i + len(closing)
+			}
+			out = append(append([]byte{}, out[:loc[0]]...), out[end:]...)
+		}
+
+		if bytes.Contains(out, []byte(prefix+":GPS")) {
+			return nil
+		}
+	}
+	return out
+}
+
+// allowlistTags 白名单字段对应的 EXIF 标签
+var allowlistTags = map[MetadataField][]uint16{
+	MetadataCopyright: {exifTagCopyright},
+	MetadataArtist:    {exifTagArtist},
+	MetadataCamera:    {exifTagMake, exifTagModel},
+	MetadataDateTime:  {exifTagDateTime, exifTagDateTimeOriginal, 0x9004},
+}
+
+// dropExifTags 保留所有 EXIF 字段时仍然删除的标签:
+// 位置信息、厂商私有数据、互操作性 IFD 指针和原图尺寸
+var dropExifTags = map[uint16]bool{
+	exifTagGPSIFD:     true,
+	exifTagMakerNote:  true,
+	exifTagInteropIFD: true,
+	0xa002:            true,
+	0xa003:            true,
+}
+
+// filterExif 按白名单重建 EXIF 数据，始终删除位置信息
+func filterExif(exif []byte, fields map[MetadataField]bool, orientationApplied bool) []byte {
+	if len(exif) == 0 {
+		return nil
+	}
+	t, err := parseTIFF(exif)
+	if err != nil {
+		return nil
+	}
+
+	allowed := map[uint16]bool{}
+	for f, tags := range allowlistTags {
+		if fields[f] {
+			for _, tag := range tags {
+				allowed[tag] = true
+			}
+		}
+	}
+	all := fields[MetadataExif]
+
+	keepEntry := func(e tiffEntry) bool {
+		if e.tag == exifTagOrientation {
+			// 像素未校正时保留方向，否则图片无法正确显示
+			return !orientationApplied || all
+		}
+		return all && !dropExifTags[e.tag] || allowed[e.tag]
+	}
+
+	out := &tiffData{order: t.order}
+	for _, e := range t.ifd0 {
+		if keepEntry(e) {
+			if e.tag == exifTagOrientation && orientationApplied {
+				e.value = normalOrientationValue(t.order)
+			}
+			out.ifd0 = append(out.ifd0, e)
+		}
+	}
+	for _, e := range t.exif {
+		if keepEntry(e) {
+			out.exif = append(out.exif, e)
+		}
+	}
+
+	if len(out.ifd0) == 0 && len(out.exif) == 0 {
+		return nil
+	}
+	return out.encode()
+}
+
+func normalOrientationValue(order binary.ByteOrder) []byte {
+	v := make([]byte, 2)
+	order.PutUint16(v, uint16(OrientationNormal))
+	return v
+}
+
+// resetExifOrientation 复制 EXIF 数据并将方向标签改为正常，其余数据保持不变
+func resetExifOrientation(exif []byte) []byte {
+	t, err := parseTIFF(exif)
+	if err != nil || t.orientation() <= OrientationNormal {
+		return exif
+	}
+
+	out := append([]byte{}, exif...)
+	offset := int(t.order.Uint32(out[4:]))
+	count := int(t.order.Uint16(out[offset:]))
+	for i := 0; i < count; i++ {
+		pos := offset + 2 + i*12
+		if t.order.Uint16(out[pos:]) == exifTagOrientation {
+			t.order.PutUint16(out[pos+8:], uint16(OrientationNormal))
+			break
+		}
+	}
+	return out
+}
+
+// metadataSegments 编码需要插入输出图片的元数据，format 不支持元数据时返回 nil
+// 返回插入位置和数据：JPEG 插入在 SOI 之后，PNG 插入在 IHDR 之后
+func (m *Metadata) segments(format ImageFormat) (int, []byte) {
+	if m.empty() {
+		return 0, nil
+	}
+
+	buf := new(bytes.Buffer)
+	switch format {
+	case FormatJPEG:
+		if len(m.EXIF) > 0 {
+			writeJPEGSegment(buf, jpegMarkerAPP1, exifHeader, m.EXIF)
+		}
+		if len(m.XMP) > 0 {
+			writeJPEGSegment(buf, jpegMarkerAPP1, xmpHeader, m.XMP)
+		}
+		if len(m.ICC) > 0 {
+			writeJPEGICC(buf, m.ICC)
+		}
+		return 2, buf.Bytes()
+	case FormatPNG:
+		if len(m.ICC) > 0 {
+			z := new(bytes.Buffer)
+			zw := zlib.NewWriter(z)
+			_, _ = zw.Write(m.ICC)
+			_ = zw.Close()
+			writePNGChunk(buf, "iCCP", append([]byte("icc\x00\x00"), z.Bytes()...))
+		}
+		if len(m.EXIF) > 0 {
+			writePNGChunk(buf, "eXIf", m.EXIF)
+		}
+		if len(m.XMP) > 0 {
+			writePNGChunk(buf, "iTXt", append([]byte(pngXMPKeyword+"\x00\x00\x00\x00\x00"), m.XMP...))
+		}
+		return len(pngHeader) + 25, buf.Bytes()
+	default:
+		return 0, nil
+	}
+}
+
+// writeJPEGSegment 写入 JPEG 段，超过最大长度时忽略
+func writeJPEGSegment(buf *bytes.Buffer, marker byte, header, data []byte) {
+	n := len(header) + len(data)
+	if n > jpegMaxSegment {
+		return
+	}
+	buf.Write([]byte{0xff, marker, byte((n + 2) >> 8), byte(n + 2)})
+	buf.Write(header)
+	buf.Write(data)
+}
+
+// writeJPEGICC 将 ICC 配置拆分为多个 APP2 段写入
+func writeJPEGICC(buf *bytes.Buffer, icc []byte) {
+	chunkSize := jpegMaxSegment - len(iccHeader) - 2
+	total := (len(icc) + chunkSize - 1) / chunkSize
+	if total > 255 {
+		return
+	}
+
+	for i := 0; i < total; i++ {
+		end := min((i+1)*chunkSize, len(icc))
+		header := append(append([]byte{}, iccHeader...), byte(i+1), byte(total))
+		writeJPEGSegment(buf, jpegMarkerAPP2, header, icc[i*chunkSize:end])
+	}
+}
+
+func writePNGChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var head [8]byte
+	binary.BigEndian.PutUint32(head[:4], uint32(len(data)))
+	copy(head[4:], typ)
+	buf.Write(head[:])
+	buf.Write(data)
+
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(head[4:])
+	_, _ = crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	buf.Write(sum[:])
+}
+
+// metadataWriter 在编码输出的指定位置插入元数据
+type metadataWriter struct {
+	w      io.Writer
+	offset int    // 插入位置
+	inject []byte // 待插入的数据，插入后置空
+	n      int    // 已写入的编码数据长度
+}
+
+// newMetadataWriter 创建插入元数据的 Writer，没有需要插入的数据时返回 w
+func newMetadataWriter(w io.Writer, format ImageFormat, meta *Metadata) io.Writer {
+	offset, data := meta.segments(format)
+	if len(data) == 0 {
+		return w
+	}
+	return &metadataWriter{w: w, offset: offset, inject: data}
+}
+
+func (mw *metadataWriter) Write(p []byte) (int, error) {
+	if mw.inject == nil || mw.n+len(p) < mw.offset {
+		n, err := mw.w.Write(p)
+		mw.n += n
+		return n, err
+	}
+
+	k := mw.offset - mw.n
+	n, err := mw.w.Write(p[:k])
+	mw.n += n
+	if err != nil {
+		return n, err
+	}
+	if _, err := mw.w.Write(mw.inject); err != nil {
+		return n, err
+	}
+	mw.inject = nil
+
+	if k == len(p) {
+		return n, nil
+	}
+	m, err := mw.w.Write(p[k:])
+	mw.n += m
+	return n + m, err
+}
@@ -0,0 +1,238 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// buildTestExif 构建包含相机、版权、拍摄时间和位置信息的 EXIF 数据
+func buildTestExif(o Orientation) []byte {
+	order := binary.LittleEndian
+	ascii := func(tag uint16, s string) tiffEntry {
+		v := append([]byte(s), 0)
+		return tiffEntry{tag: tag, typ: tiffTypeASCII, count: uint32(len(v)), value: v}
+	}
+	rational := func(tag uint16, values ...uint32) tiffEntry {
+		v := make([]byte, 0, len(values)*8)
+		for _, n := range values {
+			v = order.AppendUint32(v, n)
+			v = order.AppendUint32(v, 1)
+		}
+		return tiffEntry{tag: tag, typ: tiffTypeRational, count: uint32(len(values)), value: v}
+	}
+
+	t := &tiffData{
+		order: order,
+		ifd0: []tiffEntry{
+			ascii(exifTagMake, "ACME"),
+			ascii(exifTagModel, "Phone X"),
+			{tag: exifTagOrientation, typ: tiffTypeShort, count: 1, value: order.AppendUint16(nil, uint16(o))},
+			ascii(exifTagCopyright, "(c) vimage"),
+		},
+		exif: []tiffEntry{
+			ascii(exifTagDateTimeOriginal, "2024:01:02 03:04:05"),
+			ascii(exifTagMakerNote, "private"),
+		},
+		gps: []tiffEntry{
+			ascii(gpsTagLatitudeRef, "N"),
+			rational(gpsTagLatitude, 31, 30, 0),
+			ascii(gpsTagLongitudeRef, "W"),
+			rational(gpsTagLongitude, 121, 15, 0),
+		},
+	}
+	return t.encode()
+}
+
+// encodeTestJPEGWithMetadata 生成 40x20 并带有 EXIF、ICC 和 XMP 的 JPEG 图片
+func encodeTestJPEGWithMetadata(t *testing.T, o Orientation) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, 40, 20)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := &Metadata{
+		EXIF: buildTestExif(o),
+		ICC:  bytes.Repeat([]byte("icc-profile"), 7000), // 超过单个段的长度，需要分段
+		XMP:  []byte("<x:xmpmeta>test</x:xmpmeta>"),
+	}
+	offset, segments := meta.segments(FormatJPEG)
+
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:offset]...), segments...), data[offset:]...)
+}
+
+func TestReadExif(t *testing.T) {
+	data := encodeTestJPEGWithMetadata(t, OrientationRotate90)
+
+	info, err := ReadExif(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("读取EXIF失败: %v", err)
+	}
+
+	if info.Orientation != OrientationRotate90 || info.Make != "ACME" || info.Model != "Phone X" ||
+		info.Copyright != "(c) vimage" || info.DateTimeOriginal != "2024:01:02 03:04:05" {
+		t.Fatalf("EXIF字段错误: %+v", info)
+	}
+	if info.GPS == nil || math.Abs(info.GPS.Latitude-31.5) > 1e-9 || math.Abs(info.GPS.Longitude+121.25) > 1e-9 {
+		t.Fatalf("位置信息错误: %+v", info.GPS)
+	}
+
+	meta, err := ReadMetadata(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(meta.ICC) != 77000 || string(meta.XMP) != "<x:xmpmeta>test</x:xmpmeta>" {
+		t.Fatalf("ICC或XMP读取错误: %d, %s", len(meta.ICC), meta.XMP)
+	}
+
+	if _, err := ReadExif(bytes.NewReader(encodeTestPNG(t, 4, 4))); !errors.Is(err, ErrExifNotFound) {
+		t.Fatalf("期望 ErrExifNotFound，实际 %v", err)
+	}
+}
+
+func TestProcessImageMetadata(t *testing.T) {
+	data := encodeTestJPEGWithMetadata(t, OrientationRotate90)
+	src, _ := ReadMetadata(bytes.NewReader(data))
+
+	process := func(options *ProcessorOptions) *Metadata {
+		t.Helper()
+		out, err := ProcessImage(data, []Processor{NewZoomWidthProcessor(10)}, options)
+		if err != nil {
+			t.Fatalf("处理失败: %v", err)
+		}
+		if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+			t.Fatalf("输出无法解码: %v", err)
+		}
+		meta, err := ReadMetadata(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meta
+	}
+
+	t.Run("默认删除", func(t *testing.T) {
+		if meta := process(nil); !meta.empty() {
+			t.Fatalf("默认应删除所有元数据: %+v", meta)
+		}
+	})
+
+	t.Run("全部保留", func(t *testing.T) {
+		meta := process(&ProcessorOptions{Metadata: MetadataKeepAll})
+		info, err := meta.ExifInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 像素已经校正，方向重置为正常
+		if info.Orientation != OrientationNormal || info.GPS == nil || info.Make != "ACME" {
+			t.Fatalf("EXIF字段错误: %+v", info)
+		}
+		if !bytes.Equal(meta.ICC, src.ICC) || !bytes.Equal(meta.XMP, src.XMP) {
+			t.Fatal("ICC或XMP未保留")
+		}
+	})
+
+	t.Run("白名单", func(t *testing.T) {
+		meta := process(&ProcessorOptions{
+			Metadata:     MetadataAllowlist,
+			MetadataKeep: []MetadataField{MetadataICC, MetadataCopyright},
+		})
+		info, err := meta.ExifInfo()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Copyright != "(c) vimage" || info.Make != "" || info.GPS != nil || info.Orientation != OrientationUnknown {
+			t.Fatalf("EXIF字段错误: %+v", info)
+		}
+		if !bytes.Equal(meta.ICC, src.ICC) || meta.XMP != nil {
+			t.Fatal("ICC应保留，XMP应删除")
+		}
+	})
+
+	t.Run("白名单保留XMP", func(t *testing.T) {
+		meta := process(&ProcessorOptions{Metadata: MetadataAllowlist, MetadataKeep: []MetadataField{MetadataXMP}})
+		if !bytes.Equal(meta.XMP, src.XMP) {
+			t.Fatalf("不包含位置信息的XMP应原样保留: %s", meta.XMP)
+		}
+	})
+
+	t.Run("白名单保留EXIF", func(t *testing.T) {
+		meta := process(&ProcessorOptions{
+			Metadata:          MetadataAllowlist,
+			MetadataKeep:      []MetadataField{MetadataExif},
+			DisableAutoOrient: true,
+		})
+		tiff, err := parseTIFF(meta.EXIF)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := tiff.info()
+		// 像素未校正时保留原方向，位置信息和厂商私有数据始终删除
+		if info.Orientation != OrientationRotate90 || info.Make != "ACME" || info.DateTimeOriginal == "" || info.GPS != nil {
+			t.Fatalf("EXIF字段错误: %+v", info)
+		}
+		if findEntry(tiff.exif, exifTagMakerNote) != nil || findEntry(tiff.ifd0, exifTagGPSIFD) != nil {
+			t.Fatal("厂商私有数据和位置信息应删除")
+		}
+	})
+
+	t.Run("PNG输出", func(t *testing.T) {
+		out, err := ProcessImage(data, nil, &ProcessorOptions{Format: FormatPNG, Metadata: MetadataKeepAll})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+			t.Fatalf("PNG输出无法解码: %v", err)
+		}
+		meta, _ := ReadMetadata(bytes.NewReader(out))
+		if !bytes.Equal(meta.ICC, src.ICC) || !bytes.Equal(meta.XMP, src.XMP) || len(meta.EXIF) == 0 {
+			t.Fatal("PNG输出的元数据不完整")
+		}
+		if info, _ := meta.ExifInfo(); info.Copyright != "(c) vimage" {
+			t.Fatalf("PNG输出的EXIF错误: %+v", info)
+		}
+	})
+}
+
+func TestStripXMPLocation(t *testing.T) {
+	xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description xmlns:e="http://ns.adobe.com/exif/1.0/" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" e:GPSLatitude='31,30.0N' e:ExposureTime="1/100">` +
+		`<e:GPSLongitude>121,15.0W</e:GPSLongitude><e:GPSVersionID/><dc:rights>(c) vimage</dc:rights>` +
+		`<e:GPSDestBearing rdf:parseType="Resource"><e:GPSDestBearingRef>T</e:GPSDestBearingRef></e:GPSDestBearing>` +
+		`</rdf:Description></rdf:RDF></x:xmpmeta>`
+	want := `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description xmlns:e="http://ns.adobe.com/exif/1.0/" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" e:ExposureTime="1/100">` +
+		`<dc:rights>(c) vimage</dc:rights></rdf:Description></rdf:RDF></x:xmpmeta>`
+	if got := string(stripXMPLocation([]byte(xmp))); got != want {
+		t.Fatalf("GPS属性未删除:\n%s", got)
+	}
+
+	// 无法完整删除时删除整个 XMP
+	if got := stripXMPLocation([]byte(`<exif:GPSLatitude>31,30.0N`)); got != nil {
+		t.Fatalf("期望删除整个XMP，实际 %s", got)
+	}
+}
@@ -18,6 +18,7 @@
 package vimage
 
 import (
+	"context"
 	"image"
 	"image/color"
 )
@@ -34,10 +35,10 @@ const (
 
 // MosaicRegion 表示一个需要添加马赛克的区域
 type MosaicRegion struct {
-	FromX int // 区域左上角X坐标
-	FromY int // 区域左上角Y坐标
-	ToX   int // 区域右下角X坐标
-	ToY   int // 区域右下角Y坐标
+	FromX int `json:"from_x" yaml:"from_x"` // 区域左上角X坐标
+	FromY int `json:"from_y" yaml:"from_y"` // 区域左上角Y坐标
+	ToX   int `json:"to_x" yaml:"to_x"`     // 区域右下角X坐标
+	ToY   int `json:"to_y" yaml:"to_y"`     // 区域右下角Y坐标
 }
 
 // MosaicImageWithOptions 对图片指定区域添加马赛克效果，支持指定百分比和方向
@@ -148,6 +149,11 @@ type MosaicProcessor struct {
 
 // Process 实现Processor接口
 func (p *MosaicProcessor) Process(img image.Image) (image.Image, error) {
+	return p.ProcessWithContext(context.Background(), img)
+}
+
+// ProcessWithContext 实现 CancelableProcessor 接口
+func (p *MosaicProcessor) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
 	// 获取图片边界
 	bounds := img.Bounds()
 	width := bounds.Dx()
@@ -158,6 +164,9 @@ func (p *MosaicProcessor) Process(img image.Image) (image.Image, error) {
 
 	// 复制原图像到新图像
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if err := checkCanceled(ctx, y-bounds.Min.Y); err != nil {
+			return nil, err
+		}
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			dstImg.Set(x, y, img.At(x, y))
 		}
@@ -202,6 +211,10 @@ func (p *MosaicProcessor) Process(img image.Image) (image.Image, error) {
 		}
 
 		for y := actualFromY; y < actualToY; y += mosaicSize {
+			// 每行马赛克块包含 mosaicSize 行像素，逐行检查
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			for x := actualFromX; x < actualToX; x += mosaicSize {
 				// 计算当前块的边界
 				blockEndX := x + mosaicSize
@@ -264,6 +277,11 @@ func (p *MosaicProcessor) Process(img image.Image) (image.Image, error) {
 	return dstImg, nil
 }
 
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *MosaicProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
+
 // NewMosaicProcessor 创建新的马赛克处理器
 func NewMosaicProcessor(regions []*MosaicRegion, mosaicPercent float32, startDirection Direction) *MosaicProcessor {
 	// 验证参数
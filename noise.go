@@ -67,6 +67,16 @@ func (p *NoiseProcessor) Process(img image.Image) (image.Image, error) {
 	return dstImg, nil
 }
 
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *NoiseProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
+
+// CacheKey 实现 CacheKeyer 接口，干扰线和干扰点随机生成，处理结果不能缓存
+func (p *NoiseProcessor) CacheKey() (string, error) {
+	return "", ErrUncacheable
+}
+
 // NewNoiseProcessor 创建新的噪点处理器
 func NewNoiseProcessor(noiseLines, noiseDots int, lineColor, dotColor color.RGBA) *NoiseProcessor {
 	return &NoiseProcessor{
@@ -0,0 +1,182 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"image"
+	"runtime/metrics"
+	"time"
+)
+
+// StepEvent 处理步骤事件
+type StepEvent struct {
+	Index        int    // 步骤在处理器链中的序号，从0开始
+	Processor    string // 处理器类型名称，如 ZoomProcessor
+	InputWidth   int
+	InputHeight  int
+	OutputWidth  int           // 输出宽度，BeforeStep 中和处理出错时为 0
+	OutputHeight int           // 输出高度
+	Start        time.Time     // 步骤开始时间
+	Duration     time.Duration // 处理耗时，只在 AfterStep 中有效
+	Err          error         // 处理错误，只在 AfterStep 中有效
+
+	// 步骤期间分配的堆内存，只在 AfterStep 中且观察者实现 AllocObserver 并启用时有效
+	AllocBytes   uint64
+	AllocObjects uint64
+}
+
+// Observer 处理步骤观察者，用于统计耗时、记录日志和链路追踪
+// BeforeStep 返回的 ctx 会传给处理器和 AfterStep，追踪实现可以在其中创建子 span。
+// 同一个观察者可能被多个 goroutine 同时调用，实现需要并发安全。
+type Observer interface {
+	BeforeStep(ctx context.Context, event StepEvent) context.Context
+	AfterStep(ctx context.Context, event StepEvent)
+}
+
+// AllocObserver 需要步骤内存分配数据的观察者，ObserveAllocs 返回 true 时 StepEvent 包含 AllocBytes 和 AllocObjects
+// 分配数据是 runtime/metrics 中进程累计分配量的差值，并发处理时包含同一时间段内其他 goroutine 的分配，
+// 读取指标本身也有少量开销，因此默认不统计。
+type AllocObserver interface {
+	Observer
+	ObserveAllocs() bool
+}
+
+type observerKey struct{}
+
+// WithObserver 返回附加了观察者的 ctx
+// ProcessWithContext、ContextProcessWithContext、Pipeline 和 ProcessReaderWithContext 会通知 ctx 中的观察者，
+// Process 和 ContextProcess 不接收 ctx，不会通知观察者。
+func WithObserver(ctx context.Context, observer Observer) context.Context {
+	if observer == nil {
+		return ctx
+	}
+	if current := ObserverFromContext(ctx); current != nil {
+		observer = MultiObserver(current, observer)
+	}
+	return context.WithValue(ctx, observerKey{}, observer)
+}
+
+// ObserverFromContext 返回 ctx 中的观察者，没有时返回 nil
+func ObserverFromContext(ctx context.Context) Observer {
+	observer, _ := ctx.Value(observerKey{}).(Observer)
+	return observer
+}
+
+// MultiObserver 将事件依次通知多个观察者
+func MultiObserver(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) BeforeStep(ctx context.Context, event StepEvent) context.Context {
+	for _, o := range m {
+		ctx = o.BeforeStep(ctx, event)
+	}
+	return ctx
+}
+
+func (m multiObserver) AfterStep(ctx context.Context, event StepEvent) {
+	for _, o := range m {
+		o.AfterStep(ctx, event)
+	}
+}
+
+// ObserveAllocs 实现 AllocObserver 接口，任意一个观察者需要时统计内存分配
+func (m multiObserver) ObserveAllocs() bool {
+	for _, o := range m {
+		if observeAllocs(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// observeAllocs 观察者是否需要内存分配数据
+func observeAllocs(observer Observer) bool {
+	a, ok := observer.(AllocObserver)
+	return ok && a.ObserveAllocs()
+}
+
+// readAllocs 读取进程累计分配的堆内存字节数和对象数
+func readAllocs() (uint64, uint64) {
+	samples := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}, {Name: "/gc/heap/allocs:objects"}}
+	metrics.Read(samples)
+	var allocBytes, allocObjects uint64
+	if samples[0].Value.Kind() == metrics.KindUint64 {
+		allocBytes = samples[0].Value.Uint64()
+	}
+	if samples[1].Value.Kind() == metrics.KindUint64 {
+		allocObjects = samples[1].Value.Uint64()
+	}
+	return allocBytes, allocObjects
+}
+
+// stepDone 步骤完成时调用，通知观察者输出尺寸和错误
+type stepDone func(width, height int, err error)
+
+// noopStepDone 没有观察者时使用的 stepDone
+func noopStepDone(int, int, error) {}
+
+// observeStep 通知 ctx 中的观察者步骤开始，返回传给处理器的 ctx 和步骤完成时调用的函数
+func observeStep(ctx context.Context, index int, processor any, width, height int) (context.Context, stepDone) {
+	observer := ObserverFromContext(ctx)
+	if observer == nil {
+		return ctx, noopStepDone
+	}
+
+	event := StepEvent{
+		Index:       index,
+		Processor:   processorName(processor),
+		InputWidth:  width,
+		InputHeight: height,
+		Start:       time.Now(),
+	}
+	stepCtx := observer.BeforeStep(ctx, event)
+
+	trackAllocs := observeAllocs(observer)
+	var startBytes, startObjects uint64
+	if trackAllocs {
+		startBytes, startObjects = readAllocs()
+	}
+
+	return stepCtx, func(width, height int, err error) {
+		event.Duration = time.Since(event.Start)
+		event.Err = err
+		if trackAllocs {
+			allocBytes, allocObjects := readAllocs()
+			event.AllocBytes = allocBytes - startBytes
+			event.AllocObjects = allocObjects - startObjects
+		}
+		if err == nil {
+			event.OutputWidth = width
+			event.OutputHeight = height
+		}
+		observer.AfterStep(stepCtx, event)
+	}
+}
+
+// imageSize 返回图片尺寸，img 为 nil 时返回 0
+func imageSize(img image.Image) (int, int) {
+	if img == nil {
+		return 0, 0
+	}
+	bounds := img.Bounds()
+	return bounds.Dx(), bounds.Dy()
+}
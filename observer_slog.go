@@ -0,0 +1,88 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogObserver 使用 log/slog 记录处理步骤的观察者
+// 步骤开始以 Debug 级别记录，完成以 Level 级别记录，出错以 Error 级别记录。
+type LogObserver struct {
+	Logger *slog.Logger // 为空时使用 slog.Default()
+	Level  slog.Level   // 步骤完成的日志级别，默认 Info
+	Allocs bool         // 记录步骤期间的内存分配，见 AllocObserver
+}
+
+// NewLogObserver 创建日志观察者
+func NewLogObserver(logger *slog.Logger) *LogObserver {
+	return &LogObserver{Logger: logger, Level: slog.LevelInfo}
+}
+
+// BeforeStep 实现 Observer 接口
+func (o *LogObserver) BeforeStep(ctx context.Context, event StepEvent) context.Context {
+	o.logger().LogAttrs(ctx, slog.LevelDebug, "image step start",
+		slog.Int("step", event.Index),
+		slog.String("processor", event.Processor),
+		slog.Int("input_width", event.InputWidth),
+		slog.Int("input_height", event.InputHeight),
+	)
+	return ctx
+}
+
+// ObserveAllocs 实现 AllocObserver 接口
+func (o *LogObserver) ObserveAllocs() bool {
+	return o.Allocs
+}
+
+// AfterStep 实现 Observer 接口
+func (o *LogObserver) AfterStep(ctx context.Context, event StepEvent) {
+	attrs := []slog.Attr{
+		slog.Int("step", event.Index),
+		slog.String("processor", event.Processor),
+		slog.Int("input_width", event.InputWidth),
+		slog.Int("input_height", event.InputHeight),
+		slog.Duration("duration", event.Duration),
+	}
+	if o.Allocs {
+		attrs = append(attrs,
+			slog.Uint64("alloc_bytes", event.AllocBytes),
+			slog.Uint64("alloc_objects", event.AllocObjects),
+		)
+	}
+
+	if event.Err != nil {
+		attrs = append(attrs, slog.Any("error", event.Err))
+		o.logger().LogAttrs(ctx, slog.LevelError, "image step failed", attrs...)
+		return
+	}
+
+	attrs = append(attrs,
+		slog.Int("output_width", event.OutputWidth),
+		slog.Int("output_height", event.OutputHeight),
+	)
+	o.logger().LogAttrs(ctx, o.Level, "image step done", attrs...)
+}
+
+func (o *LogObserver) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.Default()
+	}
+	return o.Logger
+}
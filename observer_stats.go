@@ -0,0 +1,174 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultStatsSamples 每种处理器默认保留的耗时样本数
+const DefaultStatsSamples = 1024
+
+// ProcessorStats 一种处理器的耗时统计
+// 百分位数基于最近保留的样本计算，Count、Errors、Total、Min、Max 基于所有样本
+type ProcessorStats struct {
+	Processor string
+	Count     int64 // 处理次数，包括出错的次数
+	Errors    int64 // 出错次数
+	Total     time.Duration
+	Min       time.Duration
+	Max       time.Duration
+	P50       time.Duration
+	P90       time.Duration
+	P99       time.Duration
+}
+
+// Mean 返回平均耗时
+func (s ProcessorStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// StatsCollector 按处理器类型统计耗时的观察者，数据保存在内存中，可以被多个 goroutine 并发使用
+type StatsCollector struct {
+	maxSamples int
+
+	mu    sync.Mutex
+	stats map[string]*processorSamples
+}
+
+// processorSamples 一种处理器的累计数据和最近的耗时样本
+type processorSamples struct {
+	count   int64
+	errors  int64
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	samples []time.Duration // 环形缓冲区
+	next    int             // 下一个写入位置
+}
+
+// NewStatsCollector 创建耗时统计观察者，maxSamples 为每种处理器保留的样本数，小于等于 0 时使用 DefaultStatsSamples
+func NewStatsCollector(maxSamples int) *StatsCollector {
+	if maxSamples <= 0 {
+		maxSamples = DefaultStatsSamples
+	}
+	return &StatsCollector{
+		maxSamples: maxSamples,
+		stats:      make(map[string]*processorSamples),
+	}
+}
+
+// BeforeStep 实现 Observer 接口
+func (c *StatsCollector) BeforeStep(ctx context.Context, _ StepEvent) context.Context {
+	return ctx
+}
+
+// AfterStep 实现 Observer 接口
+func (c *StatsCollector) AfterStep(_ context.Context, event StepEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.stats[event.Processor]
+	if !ok {
+		s = &processorSamples{min: event.Duration, samples: make([]time.Duration, 0, c.maxSamples)}
+		c.stats[event.Processor] = s
+	}
+
+	s.count++
+	if event.Err != nil {
+		s.errors++
+	}
+	s.total += event.Duration
+	s.min = min(s.min, event.Duration)
+	s.max = max(s.max, event.Duration)
+
+	if len(s.samples) < c.maxSamples {
+		s.samples = append(s.samples, event.Duration)
+	} else {
+		s.samples[s.next] = event.Duration
+	}
+	s.next = (s.next + 1) % c.maxSamples
+}
+
+// Stats 返回所有处理器的统计，按处理器名称排序
+func (c *StatsCollector) Stats() []ProcessorStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]ProcessorStats, 0, len(c.stats))
+	for name, s := range c.stats {
+		sorted := slices.Clone(s.samples)
+		slices.Sort(sorted)
+
+		result = append(result, ProcessorStats{
+			Processor: name,
+			Count:     s.count,
+			Errors:    s.errors,
+			Total:     s.total,
+			Min:       s.min,
+			Max:       s.max,
+			P50:       percentile(sorted, 50),
+			P90:       percentile(sorted, 90),
+			P99:       percentile(sorted, 99),
+		})
+	}
+
+	slices.SortFunc(result, func(a, b ProcessorStats) int {
+		return cmp.Compare(a.Processor, b.Processor)
+	})
+	return result
+}
+
+// Percentile 返回指定处理器最近样本的百分位耗时，p 的范围为 0-100，没有样本时返回 0
+func (c *StatsCollector) Percentile(processor string, p float64) time.Duration {
+	c.mu.Lock()
+	s, ok := c.stats[processor]
+	var sorted []time.Duration
+	if ok {
+		sorted = slices.Clone(s.samples)
+	}
+	c.mu.Unlock()
+
+	slices.Sort(sorted)
+	return percentile(sorted, p)
+}
+
+// Reset 清空所有统计
+func (c *StatsCollector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats = make(map[string]*processorSamples)
+}
+
+// percentile 使用最近秩法计算已排序样本的百分位数
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
@@ -0,0 +1,184 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type spanKey struct{}
+
+// recordingObserver 记录所有事件，并在 BeforeStep 中向 ctx 写入步骤序号
+type recordingObserver struct {
+	mu     sync.Mutex
+	before []StepEvent
+	after  []StepEvent
+	spans  []any
+}
+
+func (o *recordingObserver) BeforeStep(ctx context.Context, event StepEvent) context.Context {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.before = append(o.before, event)
+	return context.WithValue(ctx, spanKey{}, event.Index)
+}
+
+func (o *recordingObserver) AfterStep(ctx context.Context, event StepEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.after = append(o.after, event)
+	o.spans = append(o.spans, ctx.Value(spanKey{}))
+}
+
+func TestProcessObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	stats := NewStatsCollector(0)
+	options := &ProcessorOptions{Observer: MultiObserver(observer, stats)}
+
+	processors := []Processor{NewZoomWidthProcessor(100), NewCutSquareProcessor("center")}
+	if _, err := ProcessImage(encodeTestPNG(t, 200, 100), processors, options); err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+
+	if len(observer.before) != 2 || len(observer.after) != 2 {
+		t.Fatalf("事件数量错误: %d %d", len(observer.before), len(observer.after))
+	}
+	zoom, cut := observer.after[0], observer.after[1]
+	if zoom.Processor != "ZoomProcessor" || zoom.InputWidth != 200 || zoom.OutputWidth != 100 || zoom.OutputHeight != 50 {
+		t.Fatalf("缩放事件错误: %+v", zoom)
+	}
+	if cut.Index != 1 || cut.InputWidth != 100 || cut.OutputWidth != 50 || cut.OutputHeight != 50 || cut.Err != nil {
+		t.Fatalf("切割事件错误: %+v", cut)
+	}
+	if observer.spans[0] != 0 || observer.spans[1] != 1 {
+		t.Fatalf("BeforeStep 返回的 ctx 应传给 AfterStep: %v", observer.spans)
+	}
+
+	if s := stats.Stats(); len(s) != 2 || s[0].Processor != "CutProcessor" || s[1].Count != 1 {
+		t.Fatalf("统计结果错误: %+v", s)
+	}
+
+	// 出错的步骤
+	failing := &recordingObserver{}
+	ctx := WithObserver(context.Background(), failing)
+	_, err := ContextProcessWithContext(ctx, image.NewRGBA(image.Rect(0, 0, 10, 10)), []ContextProcessor{&OverlayProcessor{}})
+	if err == nil || len(failing.after) != 1 || !errors.Is(failing.after[0].Err, err) || failing.after[0].OutputWidth != 0 {
+		t.Fatalf("错误事件错误: %+v, %v", failing.after, err)
+	}
+}
+
+func TestStatsCollector(t *testing.T) {
+	stats := NewStatsCollector(10)
+	for i := 1; i <= 20; i++ {
+		stats.AfterStep(context.Background(), StepEvent{Processor: "ZoomProcessor", Duration: time.Duration(i) * time.Millisecond})
+	}
+	stats.AfterStep(context.Background(), StepEvent{Processor: "CutProcessor", Err: errors.New("failed")})
+
+	// 只保留最近的 10 个样本: 11ms - 20ms
+	if p := stats.Percentile("ZoomProcessor", 50); p != 15*time.Millisecond {
+		t.Fatalf("P50 错误: %v", p)
+	}
+	if p := stats.Percentile("ZoomProcessor", 99); p != 20*time.Millisecond {
+		t.Fatalf("P99 错误: %v", p)
+	}
+
+	s := stats.Stats()
+	if len(s) != 2 || s[0].Errors != 1 || s[1].Count != 20 || s[1].Min != time.Millisecond || s[1].Mean() != 10500*time.Microsecond {
+		t.Fatalf("统计结果错误: %+v", s)
+	}
+
+	stats.Reset()
+	if len(stats.Stats()) != 0 || stats.Percentile("ZoomProcessor", 50) != 0 {
+		t.Fatal("重置后应没有统计")
+	}
+}
+
+func TestLogObserver(t *testing.T) {
+	buf := new(bytes.Buffer)
+	observer := NewLogObserver(slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	pipeline, _ := NewPipeline(NewZoomRatioProcessor(0.5), NewCutCircleProcessor())
+
+	ctx := WithObserver(context.Background(), observer)
+	if _, err := ProcessWithContext(ctx, image.NewRGBA(image.Rect(0, 0, 20, 10)), []Processor{pipeline}); err == nil {
+		t.Fatal("非正方形图片圆形裁剪应返回错误")
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"image step start",
+		"processor=Pipeline",
+		"processor=ZoomProcessor",
+		"output_width=10",
+		"level=ERROR msg=\"image step failed\" step=1 processor=CutCircleProcessor",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("日志中缺少 %q:\n%s", want, out)
+		}
+	}
+}
+
+// allocObserver 启用内存分配统计的记录观察者
+type allocObserver struct {
+	recordingObserver
+}
+
+func (o *allocObserver) ObserveAllocs() bool {
+	return true
+}
+
+func TestAllocObserver(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	processors := []Processor{NewZoomProcessor(1000, 1000)}
+
+	// 默认不统计内存分配
+	plain := &recordingObserver{}
+	if _, err := ProcessWithContext(WithObserver(context.Background(), plain), img, processors); err != nil {
+		t.Fatal(err)
+	}
+	if plain.after[0].AllocBytes != 0 {
+		t.Fatalf("未启用时不应统计内存分配: %d", plain.after[0].AllocBytes)
+	}
+
+	// 放大到 1000x1000 至少分配 4MB 的 RGBA 图像，MultiObserver 中任意一个启用即统计
+	observer := &allocObserver{}
+	ctx := WithObserver(context.Background(), MultiObserver(NewStatsCollector(0), observer))
+	if _, err := ProcessWithContext(ctx, img, processors); err != nil {
+		t.Fatal(err)
+	}
+	if event := observer.after[0]; event.AllocBytes < 4_000_000 || event.AllocObjects == 0 {
+		t.Fatalf("内存分配统计错误: %d 字节, %d 个对象", event.AllocBytes, event.AllocObjects)
+	}
+
+	buf := new(bytes.Buffer)
+	logObserver := NewLogObserver(slog.New(slog.NewTextHandler(buf, nil)))
+	logObserver.Allocs = true
+	if _, err := ProcessWithContext(WithObserver(context.Background(), logObserver), img, processors); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "alloc_bytes=") {
+		t.Fatalf("日志中缺少内存分配: %s", buf.String())
+	}
+}
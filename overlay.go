@@ -18,6 +18,7 @@
 package vimage
 
 import (
+	"context"
 	"errors"
 	"image"
 	"image/color"
@@ -25,6 +26,9 @@ import (
 	"github.com/fogleman/gg"
 )
 
+// errNoOverlayImage 未设置叠加图像
+var errNoOverlayImage = errors.New("未提供叠加图像")
+
 // OverlayProcessor 图层叠加处理器
 type OverlayProcessor struct {
 	OverlayImage image.Image // 叠加图像
@@ -37,24 +41,42 @@ type OverlayProcessor struct {
 
 // Process 实现Processor接口
 func (p *OverlayProcessor) Process(img image.Image) (image.Image, error) {
-	ctx := NewImageProcessContext(img)
+	return p.ProcessWithContext(context.Background(), img)
+}
+
+// ProcessWithContext 实现 CancelableProcessor 接口
+func (p *OverlayProcessor) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
+	pctx := NewImageProcessContext(img)
 
-	if err := p.ContextProcess(ctx); err != nil {
+	if err := p.ContextProcessWithContext(ctx, pctx); err != nil {
 		return nil, err
 	}
 
-	return ctx.dc.Image(), nil
+	return pctx.dc.Image(), nil
+}
+
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *OverlayProcessor) PlanSize(width, height int) (int, int, error) {
+	if p.OverlayImage == nil {
+		return 0, 0, errNoOverlayImage
+	}
+	return width, height, nil
 }
 
 // ContextProcess 实现 ContextProcessor 接口
 func (p *OverlayProcessor) ContextProcess(ctx *ImageProcessContext) error {
-	dc := ctx.DC()
-	width := ctx.Width
-	height := ctx.Height
+	return p.ContextProcessWithContext(context.Background(), ctx)
+}
+
+// ContextProcessWithContext 实现 CancelableContextProcessor 接口
+func (p *OverlayProcessor) ContextProcessWithContext(ctx context.Context, pctx *ImageProcessContext) error {
+	dc := pctx.DC()
+	width := pctx.Width
+	height := pctx.Height
 
 	// 使用叠加图像
 	if p.OverlayImage == nil {
-		return errors.New("未提供叠加图像")
+		return errNoOverlayImage
 	}
 
 	// 获取叠加图像
@@ -120,6 +142,9 @@ func (p *OverlayProcessor) ContextProcess(ctx *ImageProcessContext) error {
 
 		// 遍历每个像素，调整 alpha 值
 		for y := overlayBounds.Min.Y; y < overlayBounds.Max.Y; y++ {
+			if err := checkCanceled(ctx, y-overlayBounds.Min.Y); err != nil {
+				return err
+			}
 			for x := overlayBounds.Min.X; x < overlayBounds.Max.X; x++ {
 				// 获取原始颜色
 				c := overlayImg.At(x, y)
@@ -0,0 +1,185 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"slices"
+)
+
+// pipelineStep 处理流水线中的一个步骤，processor 和 contextProcessor 只有一个不为空
+type pipelineStep struct {
+	processor        Processor
+	contextProcessor ContextProcessor
+}
+
+// Pipeline 混合 Processor 和 ContextProcessor 的处理流水线
+// 连续的 ContextProcessor 步骤共享同一个 ImageProcessContext，只在 Processor 步骤需要图片时才生成 image.Image，
+// 避免每个文本、水印、绘制步骤都复制一次整张图片。
+// 同时实现了 ContextProcessor 的处理器（如 TextProcessor、OverlayProcessor）按 ContextProcessor 处理。
+// Pipeline 实现了 Processor 接口，可以直接用于 ProcessImage 和 Batch，并且可以被多个 goroutine 并发使用。
+// ProcessWithContext 会为流水线中的每个步骤通知 ctx 中的观察者，事件的 Index 为步骤在流水线中的序号。
+type Pipeline struct {
+	steps []pipelineStep
+}
+
+// NewPipeline 创建处理流水线，steps 中的元素必须实现 Processor 或 ContextProcessor
+func NewPipeline(steps ...any) (*Pipeline, error) {
+	p := &Pipeline{}
+	for i, step := range steps {
+		switch s := step.(type) {
+		case ContextProcessor:
+			p.AddContext(s)
+		case Processor:
+			p.Add(s)
+		default:
+			return nil, fmt.Errorf("步骤 %d: %T 未实现 Processor 或 ContextProcessor", i, step)
+		}
+	}
+	return p, nil
+}
+
+// Add 添加 Processor 步骤，同时实现了 ContextProcessor 的处理器按 ContextProcessor 处理
+func (p *Pipeline) Add(processor Processor) *Pipeline {
+	if cp, ok := processor.(ContextProcessor); ok {
+		return p.AddContext(cp)
+	}
+	p.steps = append(p.steps, pipelineStep{processor: processor})
+	return p
+}
+
+// AddContext 添加 ContextProcessor 步骤
+func (p *Pipeline) AddContext(processor ContextProcessor) *Pipeline {
+	p.steps = append(p.steps, pipelineStep{contextProcessor: processor})
+	return p
+}
+
+// Len 返回步骤数量
+func (p *Pipeline) Len() int {
+	return len(p.steps)
+}
+
+// PlanSize 实现 Planner 接口
+// ContextProcessor 步骤在同一个画布上绘制，尺寸不变；Processor 步骤未实现 Planner 时返回 ErrUnplannable
+func (p *Pipeline) PlanSize(width, height int) (int, int, error) {
+	for i, step := range p.steps {
+		var s any = step.processor
+		if step.contextProcessor != nil {
+			s = step.contextProcessor
+		}
+
+		planner, ok := s.(Planner)
+		if !ok {
+			if step.contextProcessor != nil {
+				continue
+			}
+			return 0, 0, ErrUnplannable
+		}
+
+		var err error
+		width, height, err = planner.PlanSize(width, height)
+		if err != nil {
+			return 0, 0, fmt.Errorf("步骤 %d: %w", i, err)
+		}
+	}
+	return width, height, nil
+}
+
+// contentAware 实现 geometryResolver 接口，任一 Processor 步骤根据图片内容选择几何变换时返回 true
+func (p *Pipeline) contentAware() bool {
+	for _, step := range p.steps {
+		if r, ok := step.processor.(geometryResolver); ok && r.contentAware() {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveGeometry 实现 geometryResolver 接口，在每个根据内容选择几何变换的步骤之前的处理结果上确定几何变换
+func (p *Pipeline) resolveGeometry(img image.Image) (Processor, error) {
+	resolved := &Pipeline{steps: slices.Clone(p.steps)}
+	for i, step := range resolved.steps {
+		r, ok := step.processor.(geometryResolver)
+		if !ok || !r.contentAware() {
+			continue
+		}
+		current, err := (&Pipeline{steps: resolved.steps[:i]}).Process(img)
+		if err != nil {
+			return nil, fmt.Errorf("步骤 %d: %w", i, err)
+		}
+		fixed, err := r.resolveGeometry(current)
+		if err != nil {
+			return nil, fmt.Errorf("步骤 %d: %w", i, err)
+		}
+		resolved.steps[i] = pipelineStep{processor: fixed}
+	}
+	return resolved, nil
+}
+
+// Process 实现Processor接口
+func (p *Pipeline) Process(img image.Image) (image.Image, error) {
+	return p.ProcessWithContext(context.Background(), img)
+}
+
+// ProcessWithContext 实现 CancelableProcessor 接口
+func (p *Pipeline) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
+	var pctx *ImageProcessContext
+	current := img
+
+	for i, step := range p.steps {
+		if step.contextProcessor != nil {
+			if pctx == nil {
+				pctx = NewImageProcessContext(current)
+			}
+
+			// 每个步骤的绘制状态（颜色、字体、变换矩阵等）互不影响，与单独处理时一致
+			stepCtx, done := observeStep(ctx, i, step.contextProcessor, pctx.Width, pctx.Height)
+			pctx.dc.Push()
+			err := AsCancelableContext(step.contextProcessor).ContextProcessWithContext(stepCtx, pctx)
+			pctx.dc.Pop()
+			done(pctx.Width, pctx.Height, err)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// Processor 步骤需要图片，生成当前上下文的图片
+		if pctx != nil {
+			current = pctx.dc.Image()
+			pctx = nil
+		}
+
+		width, height := imageSize(current)
+		stepCtx, done := observeStep(ctx, i, step.processor, width, height)
+		var err error
+		current, err = AsCancelable(step.processor).ProcessWithContext(stepCtx, current)
+		width, height = imageSize(current)
+		done(width, height, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if pctx != nil {
+		current = pctx.dc.Image()
+	}
+	return current, nil
+}
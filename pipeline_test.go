@@ -0,0 +1,117 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// recordingContextProcessor 记录每次处理使用的上下文
+type recordingContextProcessor struct {
+	contexts *[]*ImageProcessContext
+}
+
+func (p *recordingContextProcessor) ContextProcess(ctx *ImageProcessContext) error {
+	*p.contexts = append(*p.contexts, ctx)
+	return nil
+}
+
+func TestPipelineSharesContext(t *testing.T) {
+	var contexts []*ImageProcessContext
+	record := &recordingContextProcessor{contexts: &contexts}
+
+	pipeline, err := NewPipeline(record, record, NewZoomWidthProcessor(20), record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := pipeline.Process(image.NewRGBA(image.Rect(0, 0, 40, 20)))
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if out.Bounds().Dx() != 20 || out.Bounds().Dy() != 10 {
+		t.Fatalf("期望尺寸 20x10，实际 %v", out.Bounds())
+	}
+
+	// 连续的上下文步骤共享上下文，Processor 步骤之后重新创建
+	if len(contexts) != 3 || contexts[0] != contexts[1] || contexts[1] == contexts[2] {
+		t.Fatalf("上下文共享错误: %v", contexts)
+	}
+	if contexts[2].Width != 20 {
+		t.Fatalf("缩放后的上下文宽度错误: %d", contexts[2].Width)
+	}
+
+	if _, err := NewPipeline(record, "zoom"); err == nil {
+		t.Fatal("期望无效步骤返回错误")
+	}
+}
+
+func TestPipelineMatchesSequential(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 2), G: uint8(y * 3), B: 100, A: 255})
+		}
+	}
+
+	processors := []Processor{
+		NewTextProcessor(TextOptions{Text: "hello", Position: image.Pt(5, 20)}),
+		NewWatermarkProcessor("wm", 12, color.RGBA{B: 255, A: 255}, 0.5, "center", 30),
+		NewDrawRectProcessor(image.Rect(10, 10, 50, 40), color.RGBA{G: 255, A: 255}, false),
+		NewMosaicProcessor([]*MosaicRegion{{FromX: 60, FromY: 0, ToX: 120, ToY: 40}}, 1, DirectionLeft),
+		NewOverlayProcessor(image.NewRGBA(image.Rect(0, 0, 30, 30)), 0, 0, 0.3, 1),
+	}
+
+	expected, err := Process(img, processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pipeline := &Pipeline{}
+	for _, p := range processors {
+		pipeline.Add(p)
+	}
+	actual, err := pipeline.Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Bounds() != expected.Bounds() {
+		t.Fatalf("尺寸不一致: %v, %v", actual.Bounds(), expected.Bounds())
+	}
+	if !bytes.Equal(toRGBA(actual).Pix, toRGBA(expected).Pix) {
+		t.Fatal("流水线处理结果与逐个处理不一致")
+	}
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			rgba.Set(x, y, img.At(x, y))
+		}
+	}
+	return rgba
+}
@@ -0,0 +1,133 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnplannable 处理器未实现 Planner，无法预测输出尺寸
+var ErrUnplannable = errors.New("处理器无法预测输出尺寸")
+
+// Planner 可以在处理前根据输入尺寸计算输出尺寸的处理器
+// 参数对输入尺寸无效时返回与 Process 相同的错误，例如切割尺寸超过原始尺寸
+type Planner interface {
+	PlanSize(width, height int) (int, int, error)
+}
+
+// PlanStep 处理器链中单个步骤的尺寸
+type PlanStep struct {
+	Index        int    // 步骤序号，从0开始
+	Processor    string // 处理器类型名称，如 ZoomProcessor
+	InputWidth   int
+	InputHeight  int
+	OutputWidth  int
+	OutputHeight int
+}
+
+// PlanResult 处理器链的规划结果
+type PlanResult struct {
+	Steps  []PlanStep // 已规划的步骤
+	Width  int        // 输出宽度，Partial 为 true 时为最后一个已规划步骤的输出宽度
+	Height int        // 输出高度
+	// 遇到未实现 Planner 的处理器或者出错，之后步骤的尺寸未知
+	Partial bool
+}
+
+// PlanError 处理器链规划错误
+type PlanError struct {
+	Index     int    // 步骤序号，从0开始
+	Processor string // 处理器类型名称
+	Err       error
+}
+
+func (e *PlanError) Error() string {
+	return fmt.Sprintf("步骤 %d (%s): %v", e.Index, e.Processor, e.Err)
+}
+
+func (e *PlanError) Unwrap() error {
+	return e.Err
+}
+
+// Plan 根据输入尺寸规划处理器链，不需要图片数据
+// 步骤参数无效时返回 *PlanError 以及出错之前已规划的步骤；
+// 遇到未实现 Planner 的处理器或者出错时停止规划，返回的结果 Partial 为 true。
+func Plan(width, height int, processors []Processor) (*PlanResult, error) {
+	result := &PlanResult{Width: width, Height: height}
+	if width <= 0 || height <= 0 {
+		result.Partial = true
+		return result, fmt.Errorf("无效的输入尺寸: %dx%d", width, height)
+	}
+
+	for i, p := range processors {
+		name := processorName(p)
+		planner, ok := p.(Planner)
+		if !ok {
+			result.Partial = true
+			return result, nil
+		}
+
+		w, h, err := planner.PlanSize(result.Width, result.Height)
+		if errors.Is(err, ErrUnplannable) {
+			result.Partial = true
+			return result, nil
+		}
+		if err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				limitErr.Step = i
+			}
+			result.Partial = true
+			return result, &PlanError{Index: i, Processor: name, Err: err}
+		}
+
+		if w <= 0 || h <= 0 {
+			result.Partial = true
+			return result, &PlanError{Index: i, Processor: name, Err: fmt.Errorf("无效的输出尺寸: %dx%d", w, h)}
+		}
+
+		result.Steps = append(result.Steps, PlanStep{
+			Index:        i,
+			Processor:    name,
+			InputWidth:   result.Width,
+			InputHeight:  result.Height,
+			OutputWidth:  w,
+			OutputHeight: h,
+		})
+		result.Width, result.Height = w, h
+	}
+
+	return result, nil
+}
+
+// processorName 返回处理器的类型名称，不包含包名和指针
+func processorName(p any) string {
+	t := reflect.TypeOf(p)
+	if t == nil {
+		return "nil"
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Name() == "" {
+		return t.String()
+	}
+	return t.Name()
+}
@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	plan, err := Plan(400, 300, []Processor{
+		NewCutSquareProcessor("center"),
+		NewZoomProcessor(128, 128),
+		NewCutCircleProcessor(),
+		NewRotateProcessor(90),
+	})
+	if err != nil {
+		t.Fatalf("规划失败: %v", err)
+	}
+	if plan.Partial || len(plan.Steps) != 4 || plan.Width != 128 || plan.Height != 128 {
+		t.Fatalf("规划结果错误: %+v", plan)
+	}
+	if step := plan.Steps[0]; step.Processor != "CutProcessor" || step.InputWidth != 400 || step.OutputWidth != 300 {
+		t.Fatalf("第一步规划错误: %+v", step)
+	}
+
+	// 未实现 Planner 的处理器之后的尺寸未知
+	plan, err = Plan(400, 300, []Processor{NewZoomWidthProcessor(200), &countingProcessor{size: 10}, NewZoomRatioProcessor(2)})
+	if err != nil || !plan.Partial || len(plan.Steps) != 1 || plan.Width != 200 || plan.Height != 150 {
+		t.Fatalf("部分规划结果错误: %+v, %v", plan, err)
+	}
+
+	// 流水线中的 ContextProcessor 步骤不改变尺寸
+	pipeline, _ := NewPipeline(NewZoomWidthProcessor(100), NewTextProcessor(DefaultTextOptions), NewCutSquareProcessor("top"))
+	plan, err = Plan(400, 300, []Processor{pipeline})
+	if err != nil || plan.Partial || plan.Width != 75 || plan.Height != 75 {
+		t.Fatalf("流水线规划结果错误: %+v, %v", plan, err)
+	}
+}
+
+func TestPlanInvalidRecipe(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors []Processor
+		index      int
+	}{
+		{"切割超过原始尺寸", []Processor{NewZoomWidthProcessor(100), NewCutProcessor(200, 50, CutPositionCenter)}, 1},
+		{"非正方形圆形裁剪", []Processor{NewZoomProcessor(100, 50), NewCutCircleProcessor()}, 1},
+		{"无效的缩放尺寸", []Processor{NewZoomRatioProcessor(0)}, 0},
+		{"自定义区域越界", []Processor{NewCutProcessorWithRegion(100, 100, 350, 0)}, 0},
+		{"旋转角度无效", []Processor{NewRotateProcessor(math.NaN())}, 0},
+		{"输出尺寸无效", []Processor{NewZoomWidthProcessor(100), emptyPlanner{}}, 1},
+	}
+
+	data := encodeTestPNG(t, 400, 300)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Plan(400, 300, tc.processors)
+			var planErr *PlanError
+			if !errors.As(err, &planErr) || planErr.Index != tc.index {
+				t.Fatalf("期望步骤 %d 的规划错误，实际 %v", tc.index, err)
+			}
+
+			// 解码前拒绝无效的处理器链，之前的处理器不会被调用
+			counter := &countingProcessor{size: 400}
+			planner := &plannedCounter{countingProcessor: counter}
+			if _, err := ProcessImage(data, append([]Processor{planner}, tc.processors...), nil); !errors.As(err, &planErr) {
+				t.Fatalf("期望规划错误，实际 %v", err)
+			}
+			if counter.called {
+				t.Fatal("无效的处理器链不应调用处理器")
+			}
+		})
+	}
+}
+
+// emptyPlanner 规划输出尺寸为 0x0 的处理器
+type emptyPlanner struct{}
+
+func (emptyPlanner) Process(image.Image) (image.Image, error) {
+	return image.NewRGBA(image.Rectangle{}), nil
+}
+
+func (emptyPlanner) PlanSize(int, int) (int, int, error) {
+	return 0, 0, nil
+}
+
+// plannedCounter 尺寸不变的 countingProcessor
+type plannedCounter struct {
+	*countingProcessor
+}
+
+func (p *plannedCounter) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
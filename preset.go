@@ -0,0 +1,169 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// presetFile 预设配置文件格式
+//
+// 示例(YAML):
+//
+//	presets:
+//	  avatar-128:
+//	    steps:
+//	      - type: cut
+//	        square: true
+//	      - type: zoom
+//	        width: 128
+//	        height: 128
+//	      - type: circle
+type presetFile struct {
+	Presets map[string]*PipelineSpec `json:"presets" yaml:"presets"`
+}
+
+// PresetError 预设配置错误
+type PresetError struct {
+	Name string // 预设名称
+	Err  error  // 配置错误，通常为 *SpecError
+}
+
+func (e *PresetError) Error() string {
+	return fmt.Sprintf("预设 %s: %v", e.Name, e.Err)
+}
+
+func (e *PresetError) Unwrap() error {
+	return e.Err
+}
+
+// PresetStore 命名的处理器链配置，可以被多个 goroutine 并发使用
+type PresetStore struct {
+	mu      sync.RWMutex
+	presets map[string]*PipelineSpec
+}
+
+// NewPresetStore 创建空的预设存储
+func NewPresetStore() *PresetStore {
+	return &PresetStore{presets: make(map[string]*PipelineSpec)}
+}
+
+// ParsePresets 解析预设配置，以 '{' 开头的内容按JSON解析，否则按YAML解析
+func ParsePresets(data []byte) (*PresetStore, error) {
+	file := &presetFile{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(file); err != nil {
+			return nil, fmt.Errorf("解析JSON预设失败: %w", err)
+		}
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(trimmed))
+		dec.KnownFields(true)
+		if err := dec.Decode(file); err != nil {
+			return nil, fmt.Errorf("解析YAML预设失败: %w", err)
+		}
+	}
+
+	store := NewPresetStore()
+	for name, spec := range file.Presets {
+		if err := store.Add(name, spec); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// LoadPresetFile 从文件加载预设配置
+func LoadPresetFile(path string) (*PresetStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := ParsePresets(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	return store, nil
+}
+
+// Add 添加或替换预设，配置无效时返回 *PresetError
+func (s *PresetStore) Add(name string, spec *PipelineSpec) error {
+	if name == "" || strings.ContainsAny(name, "/:") {
+		return &PresetError{Name: name, Err: errors.New("无效的预设名称")}
+	}
+	if spec == nil {
+		spec = &PipelineSpec{}
+	}
+	if err := spec.Validate(); err != nil {
+		return &PresetError{Name: name, Err: err}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.presets[name] = spec
+	return nil
+}
+
+// Get 返回预设的处理器链配置
+func (s *PresetStore) Get(name string) (*PipelineSpec, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	spec, ok := s.presets[name]
+	return spec, ok
+}
+
+// Processors 构建预设的处理器链，可以直接用于 ProcessImage、Pipeline 和 Batch
+func (s *PresetStore) Processors(name string) ([]Processor, error) {
+	spec, ok := s.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("未知的预设: %s", name)
+	}
+
+	processors, err := spec.Processors()
+	if err != nil {
+		return nil, &PresetError{Name: name, Err: err}
+	}
+	return processors, nil
+}
+
+// Names 返回所有预设名称，按名称排序
+func (s *PresetStore) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	names := make([]string, 0, len(s.presets))
+	for name := range s.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
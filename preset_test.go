@@ -0,0 +1,89 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadPresetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.yaml")
+	content := `
+presets:
+  avatar-128:
+    steps:
+      - type: cut
+        square: true
+      - type: zoom
+        width: 128
+        height: 128
+      - type: circle
+  listing-thumb:
+    steps:
+      - type: zoom
+        mode: max
+        size: 300
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := LoadPresetFile(path)
+	if err != nil {
+		t.Fatalf("加载预设失败: %v", err)
+	}
+	if names := store.Names(); !slices.Equal(names, []string{"avatar-128", "listing-thumb"}) {
+		t.Fatalf("预设名称错误: %v", names)
+	}
+
+	processors, err := store.Processors("avatar-128")
+	if err != nil {
+		t.Fatalf("构建预设失败: %v", err)
+	}
+	out, err := ProcessImage(encodeTestPNG(t, 400, 300), processors, nil)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(out)); err != nil || cfg.Width != 128 || cfg.Height != 128 {
+		t.Fatalf("期望尺寸 128x128，实际 %dx%d, %v", cfg.Width, cfg.Height, err)
+	}
+
+	if _, err := store.Processors("banner"); err == nil {
+		t.Fatal("未知预设应返回错误")
+	}
+}
+
+func TestParsePresetsErrors(t *testing.T) {
+	_, err := ParsePresets([]byte(`{"presets":{"bad":{"steps":[{"type":"zoom","mode":"width"}]}}}`))
+
+	var presetErr *PresetError
+	var specErr *SpecError
+	if !errors.As(err, &presetErr) || presetErr.Name != "bad" || !errors.As(err, &specErr) || specErr.Field != "width" {
+		t.Fatalf("期望预设 bad 的 steps[0].width 错误，实际 %v", err)
+	}
+
+	if err := NewPresetStore().Add("a/b", &PipelineSpec{}); err == nil {
+		t.Fatal("无效的预设名称应返回错误")
+	}
+}
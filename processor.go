@@ -19,14 +19,19 @@ package vimage
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"image"
-	"image/jpeg"
+	"image/gif"
 	"image/png"
+	"io"
 
 	"github.com/fogleman/gg"
 )
 
 // Processor 定义新的处理器接口，支持选项参数
+// 处理器在处理时不修改自身状态，内置处理器都可以被多个 goroutine 并发使用，
+// 自定义处理器需要满足同样的要求才能在 Batch 中共享。
 type Processor interface {
 	Process(img image.Image) (image.Image, error)
 }
@@ -62,7 +67,41 @@ type ContextProcessor interface {
 // ProcessorOptions 处理器选项
 type ProcessorOptions struct {
 	// 可以添加通用选项
-	Quality int // JPEG压缩质量 (1-100)
+	Quality int // JPEG压缩质量 (1-100)，0 表示使用默认值
+
+	// JPEG 输出的最大字节数，0 表示不限制。设置后在 MinQuality 和 Quality 之间查找满足大小的最高质量，
+	// DownscaleToFit 为 true 时最低质量仍然超出则缩小图片，否则返回 *LimitError
+	MaxJPEGBytes   int64
+	MinQuality     int  // 按大小查找质量时的最低质量，0 表示 1
+	DownscaleToFit bool // 最低质量仍然超出 MaxJPEGBytes 时缩小图片
+
+	Format         ImageFormat          // 输出格式，为空时与输入格式一致
+	JPEGChroma     JPEGChroma           // JPEG色度采样方式
+	JPEGGray       bool                 // JPEG只保留亮度，输出灰度图
+	PNGCompression png.CompressionLevel // PNG压缩级别
+	GIFColors      int                  // GIF调色板颜色数 (1-256)，0 表示 256
+
+	DisableAutoOrient bool // 不根据 EXIF 方向自动旋转图片
+	DisableAnimation  bool // GIF 动画只处理第一帧，默认输出为 GIF 时处理所有帧并保留动画
+
+	// 不使用 JPEG 缩小解码。默认第一个处理器是缩小到原图 1/8 以下的 ZoomProcessor 时，
+	// 只解码每个 8x8 块的直流分量，得到 1/8 尺寸的图片后再缩放
+	DisableReducedDecode bool
+
+	Metadata     MetadataPolicy  // 元数据处理策略，默认删除所有元数据，只支持 JPEG 和 PNG 输出
+	MetadataKeep []MetadataField // MetadataAllowlist 策略下保留的元数据
+
+	// 资源限制，0 表示不限制，超出限制时返回 *LimitError
+	MaxInputBytes   int64 // 输入数据最大字节数
+	MaxPixels       int64 // 输入图片以及每个处理步骤输出的最大像素数
+	MaxOutputWidth  int   // 输出图片最大宽度
+	MaxOutputHeight int   // 输出图片最大高度
+	MaxFrames       int   // GIF 动画最大帧数
+	// GIF 动画合成后所有帧的最大像素总数，即帧数乘以画布像素数
+	MaxAnimationPixels int64
+
+	// 处理步骤观察者，与 ctx 中通过 WithObserver 附加的观察者一起通知
+	Observer Observer
 }
 
 // DefaultProcessorOptions 默认处理器选项
@@ -70,46 +109,306 @@ var DefaultProcessorOptions = ProcessorOptions{
 	Quality: 90,
 }
 
+// ProcessResult 图片处理结果
+type ProcessResult struct {
+	InputFormat  ImageFormat // 输入图片格式
+	OutputFormat ImageFormat // 输出图片格式
+	Width        int         // 输出图片宽度
+	Height       int         // 输出图片高度
+	Orientation  Orientation // 输入图片的 EXIF 方向，没有方向信息时为 OrientationUnknown
+	Frames       int         // 输出的 GIF 动画帧数，静态图片为 0
+	Quality      int         // JPEG 输出使用的质量，其他格式为 0
+	Attempts     int         // JPEG 输出的编码次数，设置 MaxJPEGBytes 时可能多于 1 次
+}
+
 // ProcessImage 使用处理器链处理图片
 // imgData: 原始图片字节数据
 // processors: 处理器链
-// options: 处理选项
-// 返回: 处理后的图片字节数据和错误信息
+// options: 处理选项，options.Format 为空时按输入格式编码，输入格式不支持编码(如 WebP)时需要指定输出格式
+// 返回: 处理后的图片字节数据和错误信息，无法识别或不支持的格式返回 *UnsupportedFormatError
 func ProcessImage(imgData []byte, processors []Processor, options *ProcessorOptions) ([]byte, error) {
+	return ProcessImageWithContext(context.Background(), imgData, processors, options)
+}
+
+// ProcessImageWithContext 与 ProcessImage 相同，ctx 取消或超时后停止处理并返回 ctx.Err()
+func ProcessImageWithContext(ctx context.Context, imgData []byte, processors []Processor,
+	options *ProcessorOptions,
+) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := ProcessReaderWithContext(ctx, bytes.NewReader(imgData), buf, processors, options); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ProcessReader 从 r 解码图片，使用处理器链处理后直接编码写入 w
+// 与 ProcessImage 不同，输入和输出都不需要完整保存在内存中，适合直接处理HTTP请求体并写入响应。
+// 格式错误在写入 w 之前返回；编码过程中出错时 w 可能已写入部分数据。
+// 写入 http.ResponseWriter 时，如未设置 Content-Type，会根据写入的数据自动识别。
+func ProcessReader(r io.Reader, w io.Writer, processors []Processor, options *ProcessorOptions) (*ProcessResult, error) {
+	return ProcessReaderWithContext(context.Background(), r, w, processors, options)
+}
+
+// ProcessReaderWithContext 与 ProcessReader 相同，ctx 取消或超时后停止处理并返回 ctx.Err()
+// 在解码后、每个处理器前后以及编码前检查 ctx，实现了 CancelableProcessor 的处理器在处理过程中也会检查
+func ProcessReaderWithContext(ctx context.Context, r io.Reader, w io.Writer, processors []Processor,
+	options *ProcessorOptions,
+) (*ProcessResult, error) {
 	// 使用默认选项
 	if options == nil {
 		options = &DefaultProcessorOptions
 	}
+	ctx = WithObserver(ctx, options.Observer)
+
+	// 指定的输出格式不支持时，无需解码
+	if options.Format != FormatAuto && !options.Format.CanEncode() {
+		return nil, &UnsupportedFormatError{Format: string(options.Format), Encode: true}
+	}
 
-	// 解码图片
-	srcImg, format, err := image.Decode(bytes.NewReader(imgData))
+	src, err := openSource(r, options)
 	if err != nil {
 		return nil, err
 	}
 
+	// 完整解码之前根据图片头部的尺寸规划处理器链并检查限制，无效的参数无需解码即可返回错误
+	if err := options.checkSource(src, processors); err != nil {
+		return nil, err
+	}
+
+	// 第一步大比例缩小 JPEG 时以 1/8 分辨率解码
+	processors = src.reduceDecode(options, processors)
+
+	result := &ProcessResult{
+		InputFormat:  src.format,
+		OutputFormat: options.Format,
+		Orientation:  src.orientation,
+	}
+
+	// 输出为 GIF 时解码 GIF 动画的所有帧
+	if options.keepAnimation(src.format) {
+		data, err := io.ReadAll(src.r)
+		if err != nil {
+			return nil, src.decodeErr(err)
+		}
+		// 解码像素之前按块结构统计帧数，解码和合成占用的内存都与帧数成正比
+		if frames, canvas := scanGIF(data); frames > 1 {
+			if err := options.checkAnimation(frames, canvas); err != nil {
+				return nil, err
+			}
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, src.decodeErr(err)
+		}
+		if len(anim.Image) > 1 {
+			if err := options.checkAnimation(len(anim.Image), animationCanvas(anim)); err != nil {
+				return nil, err
+			}
+			return processAnimation(ctx, anim, w, processors, options, result)
+		}
+		src.img = anim.Image[0]
+	}
+
+	// 在处理器链之前校正方向，使裁剪位置和缩放模式基于正确的宽高
+	srcImg, err := src.decode(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// 未指定输出格式时与输入格式一致
+	if result.OutputFormat, err = options.outputFormat(src.format); err != nil {
+		return nil, err
+	}
+
 	// 应用处理器链
-	currentImg, err := Process(srcImg, processors)
+	currentImg, err := ProcessWithContext(ctx, srcImg, processors)
 	if err != nil {
 		return nil, err
 	}
 
-	// 编码图片
-	buf := new(bytes.Buffer)
-	switch format {
-	case "jpeg":
-		err = jpeg.Encode(buf, currentImg, &jpeg.Options{Quality: options.Quality})
-	case "png":
-		err = png.Encode(buf, currentImg)
-	default:
-		// 默认使用PNG格式
-		err = png.Encode(buf, currentImg)
+	return encodeResult(ctx, w, currentImg, src.meta, options, result)
+}
+
+// source 已读取头部的输入图片
+type source struct {
+	r           io.Reader      // 拼接了已读取头部的完整数据流
+	limited     *limitedReader // 输入数据大小限制，未限制时为空
+	meta        *Metadata      // 头部的元数据
+	config      image.Config   // 图片头部信息
+	format      ImageFormat    // 输入格式
+	orientation Orientation    // EXIF 方向
+	header      []byte         // 读取图片头部时读取的数据
+	reduced     bool           // 以 1/8 分辨率解码 JPEG
+	img         image.Image    // 已解码的图片，为空时由 decode 解码
+}
+
+// openSource 读取输入的元数据和图片头部，不解码像素
+func openSource(r io.Reader, options *ProcessorOptions) (*source, error) {
+	src := &source{}
+
+	// 限制输入数据大小，解码器可能包装读取错误，因此以 limited.err 为准
+	if options.MaxInputBytes > 0 {
+		src.limited = newLimitedReader(r, options.MaxInputBytes)
+		r = src.limited
+	}
+
+	// 读取头部的元数据，已读取的数据与剩余数据拼接后解码
+	meta, consumed, err := readMetadata(r)
+	if err != nil {
+		return nil, src.decodeErr(err)
+	}
+	src.meta = meta
+	src.orientation = parseExifOrientation(meta.EXIF)
+	r = io.MultiReader(bytes.NewReader(consumed), r)
+
+	// 已读取的头部数据与剩余数据拼接后解码
+	header := new(bytes.Buffer)
+	config, format, err := image.DecodeConfig(io.TeeReader(r, header))
+	if err != nil {
+		return nil, src.decodeErr(err)
+	}
+	src.config = config
+	src.format = ImageFormat(format)
+	src.header = header.Bytes()
+	src.r = io.MultiReader(header, r)
+
+	return src, nil
+}
+
+// decodeErr 转换解码错误，超出输入大小限制时返回 *LimitError
+func (s *source) decodeErr(err error) error {
+	if s.limited != nil && s.limited.err != nil {
+		return s.limited.err
+	}
+	if errors.Is(err, image.ErrFormat) {
+		return &UnsupportedFormatError{}
+	}
+	return err
+}
+
+// size 返回校正方向后的图片尺寸
+func (s *source) size(options *ProcessorOptions) (int, int) {
+	if !options.DisableAutoOrient && s.orientation.swapsSize() {
+		return s.config.Height, s.config.Width
+	}
+	return s.config.Width, s.config.Height
+}
+
+// decode 解码图片并按 EXIF 方向校正
+func (s *source) decode(options *ProcessorOptions) (image.Image, error) {
+	img := s.img
+	if img == nil {
+		var err error
+		if s.reduced {
+			img, err = decodeJPEGReduced(s.r)
+		} else {
+			img, _, err = image.Decode(s.r)
+		}
+		if err != nil {
+			return nil, s.decodeErr(err)
+		}
+	}
+	if !options.DisableAutoOrient {
+		img = ApplyOrientation(img, s.orientation)
+	}
+	return img, nil
+}
+
+// checkSource 根据图片头部的尺寸规划处理器链并检查限制
+func (o *ProcessorOptions) checkSource(src *source, processors []Processor) error {
+	if len(processors) == 0 && !o.hasSizeLimits() {
+		return nil
+	}
+
+	width, height := src.size(o)
+	plan, planErr := Plan(width, height, processors)
+	if o.hasSizeLimits() {
+		if err := o.checkPlan(width, height, plan); err != nil {
+			return err
+		}
+	}
+	return planErr
+}
+
+// outputFormat 返回输出格式，未指定时与输入格式一致
+func (o *ProcessorOptions) outputFormat(input ImageFormat) (ImageFormat, error) {
+	if o.Format != FormatAuto {
+		return o.Format, nil
+	}
+	if !input.CanEncode() {
+		return "", &UnsupportedFormatError{Format: string(input), Encode: true}
+	}
+	return input, nil
+}
+
+// encodeResult 检查输出尺寸并编码处理后的图片，按策略保留的元数据插入到输出中
+func encodeResult(ctx context.Context, w io.Writer, img image.Image, meta *Metadata, options *ProcessorOptions,
+	result *ProcessResult,
+) (*ProcessResult, error) {
+	bounds := img.Bounds()
+	result.Width = bounds.Dx()
+	result.Height = bounds.Dy()
+
+	// 无法预测尺寸的处理器链在编码前检查实际输出尺寸
+	if err := options.checkOutputSize(result.Width, result.Height); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var kept *Metadata
+	if options.Metadata != MetadataStrip {
+		kept = meta.filter(options.Metadata, options.MetadataKeep, !options.DisableAutoOrient)
+		w = newMetadataWriter(w, result.OutputFormat, kept)
+	}
+
+	if result.OutputFormat == FormatJPEG && options.MaxJPEGBytes > 0 {
+		return encodeJPEGResult(w, img, kept, options, result)
+	}
+
+	if err := EncodeImage(w, img, result.OutputFormat, options); err != nil {
+		return nil, err
+	}
+	if result.OutputFormat == FormatJPEG {
+		result.Quality = options.Quality
+		if result.Quality <= 0 {
+			result.Quality = DefaultProcessorOptions.Quality
+		}
+		result.Attempts = 1
 	}
 
+	return result, nil
+}
+
+// encodeJPEGResult 按 MaxJPEGBytes 编码 JPEG，插入的元数据计入大小
+func encodeJPEGResult(w io.Writer, img image.Image, kept *Metadata, options *ProcessorOptions,
+	result *ProcessResult,
+) (*ProcessResult, error) {
+	maxBytes := options.MaxJPEGBytes
+	if kept != nil {
+		_, segments := kept.segments(FormatJPEG)
+		maxBytes -= int64(len(segments))
+		// 保留的元数据已经超出大小限制，降低质量或缩小图片都无法满足
+		if maxBytes <= 0 {
+			return nil, &LimitError{Kind: LimitOutputBytes, Step: -1, Value: int64(len(segments)), Max: options.MaxJPEGBytes}
+		}
+	}
+
+	data, sizeResult, err := encodeJPEGToSize(img, maxBytes, options)
 	if err != nil {
 		return nil, err
 	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
 
-	return buf.Bytes(), nil
+	result.Quality = sizeResult.Quality
+	result.Attempts = sizeResult.Attempts
+	result.Width = sizeResult.Width
+	result.Height = sizeResult.Height
+	return result, nil
 }
 
 // Process 循环处理图片
@@ -126,6 +425,34 @@ func Process(img image.Image, processors []Processor) (image.Image, error) {
 	return currentImg, nil
 }
 
+// ProcessWithContext 循环处理图片，ctx 取消或超时后返回 ctx.Err()
+// 未实现 CancelableProcessor 的处理器通过 AsCancelable 适配，每个步骤前后通知 ctx 中的观察者
+func ProcessWithContext(ctx context.Context, img image.Image, processors []Processor) (image.Image, error) {
+	return processSteps(ctx, img, processors, 0)
+}
+
+// processSteps 从序号 first 开始处理剩余的步骤，观察者收到的序号为步骤在完整处理器链中的序号
+func processSteps(ctx context.Context, img image.Image, processors []Processor, first int) (image.Image, error) {
+	var err error
+	currentImg := img
+	for i, processor := range processors {
+		width, height := imageSize(currentImg)
+		stepCtx, done := observeStep(ctx, first+i, processor, width, height)
+		currentImg, err = AsCancelable(processor).ProcessWithContext(stepCtx, currentImg)
+		width, height = imageSize(currentImg)
+		done(width, height, err)
+		if err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				limitErr.Step = first + i
+			}
+			return nil, err
+		}
+	}
+
+	return currentImg, nil
+}
+
 // ContextProcess 上下文处理
 func ContextProcess(img image.Image, processors []ContextProcessor) (image.Image, error) {
 	ctx := NewImageProcessContext(img)
@@ -139,8 +466,33 @@ func ContextProcess(img image.Image, processors []ContextProcessor) (image.Image
 	return ctx.dc.Image(), nil
 }
 
+// ContextProcessWithContext 上下文处理，ctx 取消或超时后返回 ctx.Err()
+// 未实现 CancelableContextProcessor 的处理器通过 AsCancelableContext 适配，每个步骤前后通知 ctx 中的观察者
+func ContextProcessWithContext(ctx context.Context, img image.Image, processors []ContextProcessor) (image.Image, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pctx := NewImageProcessContext(img)
+
+	for i, processor := range processors {
+		stepCtx, done := observeStep(ctx, i, processor, pctx.Width, pctx.Height)
+		err := AsCancelableContext(processor).ContextProcessWithContext(stepCtx, pctx)
+		done(pctx.Width, pctx.Height, err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pctx.dc.Image(), nil
+}
+
 type EmptyProcessor struct{}
 
 func (p *EmptyProcessor) Process(img image.Image) (image.Image, error) {
 	return img, nil
 }
+
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *EmptyProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
@@ -86,6 +86,42 @@ func TestProcessImage(t *testing.T) {
 	}
 }
 
+// TestProcessReader 测试流式处理
+func TestProcessReader(t *testing.T) {
+	testImg := createTestImageForProcessor(200, 100)
+
+	out := new(bytes.Buffer)
+	result, err := ProcessReader(bytes.NewReader(testImg), out, []Processor{NewZoomWidthProcessor(50)},
+		&ProcessorOptions{Format: FormatJPEG, Quality: 80})
+	if err != nil {
+		t.Fatalf("流式处理失败: %v", err)
+	}
+
+	if result.InputFormat != FormatPNG || result.OutputFormat != FormatJPEG {
+		t.Fatalf("格式错误: 输入 %s，输出 %s", result.InputFormat, result.OutputFormat)
+	}
+	if result.Width != 50 || result.Height != 25 {
+		t.Fatalf("期望尺寸 50x25，实际 %dx%d", result.Width, result.Height)
+	}
+
+	img, format, err := image.Decode(out)
+	if err != nil {
+		t.Fatalf("输出无法解码: %v", err)
+	}
+	if format != "jpeg" || img.Bounds().Dx() != 50 {
+		t.Fatalf("输出错误: %s %dx%d", format, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	// 不支持的输出格式在写入前返回错误
+	out.Reset()
+	if _, err := ProcessReader(bytes.NewReader(testImg), out, nil, &ProcessorOptions{Format: FormatWebP}); err == nil {
+		t.Fatal("不支持的输出格式应返回错误")
+	}
+	if out.Len() != 0 {
+		t.Fatal("出错时不应写入数据")
+	}
+}
+
 // TestMultipleProcessors 测试多个处理器链式处理
 func TestMultipleProcessors(t *testing.T) {
 	// 创建测试图片
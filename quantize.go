@@ -0,0 +1,167 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"cmp"
+	"image"
+	"image/color"
+	"slices"
+)
+
+// quantizeMaxSamples 生成调色板时最多采样的像素数
+const quantizeMaxSamples = 1 << 18
+
+// MedianCutQuantizer 中位切分调色板生成器，实现 draw.Quantizer 接口
+// 只统计不透明度不低于一半的像素，颜色数不超过调色板容量时使用图片中的原始颜色。
+type MedianCutQuantizer struct{}
+
+// quantColor 去除预乘的颜色及其出现次数
+type quantColor struct {
+	rgb   [3]uint8
+	count int
+}
+
+// Quantize 实现 draw.Quantizer 接口，向 p 追加最多 cap(p)-len(p) 个颜色
+func (q MedianCutQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	n := cap(p) - len(p)
+	if n <= 0 {
+		return p
+	}
+
+	colors := sampleColors(m)
+	if len(colors) <= n {
+		for _, c := range colors {
+			p = append(p, color.RGBA{R: c.rgb[0], G: c.rgb[1], B: c.rgb[2], A: 0xff})
+		}
+		return p
+	}
+
+	for _, box := range medianCut(colors, n) {
+		p = append(p, box.average())
+	}
+	return p
+}
+
+// sampleColors 统计图片中的颜色，像素较多时按间隔采样，结果按颜色排序
+func sampleColors(m image.Image) []quantColor {
+	bounds := m.Bounds()
+	step := 1
+	for bounds.Dx()*bounds.Dy()/(step*step) > quantizeMaxSamples {
+		step++
+	}
+
+	counts := make(map[[3]uint8]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 0x80 {
+				continue
+			}
+			counts[[3]uint8{c.R, c.G, c.B}]++
+		}
+	}
+
+	colors := make([]quantColor, 0, len(counts))
+	for rgb, count := range counts {
+		colors = append(colors, quantColor{rgb: rgb, count: count})
+	}
+	// 排序使结果与 map 遍历顺序无关
+	slices.SortFunc(colors, func(a, b quantColor) int {
+		return cmp.Or(cmp.Compare(a.rgb[0], b.rgb[0]), cmp.Compare(a.rgb[1], b.rgb[1]), cmp.Compare(a.rgb[2], b.rgb[2]))
+	})
+	return colors
+}
+
+// colorBox 中位切分中的一组颜色
+type colorBox []quantColor
+
+// widest 返回范围最大的通道及其范围
+func (b colorBox) widest() (int, int) {
+	channel, width := 0, -1
+	for ch := 0; ch < 3; ch++ {
+		lo, hi := 255, 0
+		for _, c := range b {
+			lo = min(lo, int(c.rgb[ch]))
+			hi = max(hi, int(c.rgb[ch]))
+		}
+		if hi-lo > width {
+			channel, width = ch, hi-lo
+		}
+	}
+	return channel, width
+}
+
+// average 返回按出现次数加权的平均颜色
+func (b colorBox) average() color.RGBA {
+	var sum [3]int
+	total := 0
+	for _, c := range b {
+		for ch := 0; ch < 3; ch++ {
+			sum[ch] += int(c.rgb[ch]) * c.count
+		}
+		total += c.count
+	}
+	return color.RGBA{
+		R: uint8((sum[0] + total/2) / total),
+		G: uint8((sum[1] + total/2) / total),
+		B: uint8((sum[2] + total/2) / total),
+		A: 0xff,
+	}
+}
+
+// medianCut 将颜色切分为最多 n 组，每次切分范围最大的一组，在加权中位数处切开
+func medianCut(colors []quantColor, n int) []colorBox {
+	boxes := []colorBox{colors}
+	for len(boxes) < n {
+		index, channel, width := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, w := box.widest(); w > width {
+				index, channel, width = i, ch, w
+			}
+		}
+		if index < 0 {
+			break
+		}
+
+		box := boxes[index]
+		slices.SortStableFunc(box, func(a, b quantColor) int {
+			return cmp.Compare(a.rgb[channel], b.rgb[channel])
+		})
+
+		total := 0
+		for _, c := range box {
+			total += c.count
+		}
+		split, acc := 1, 0
+		for i, c := range box[:len(box)-1] {
+			acc += c.count
+			split = i + 1
+			if acc*2 >= total {
+				break
+			}
+		}
+
+		boxes[index] = box[:split]
+		boxes = append(boxes, box[split:])
+	}
+	return boxes
+}
@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ProcessorFactory 根据参数创建处理器
+// params 通常来自JSON或YAML配置，数值类型可能是 int 或 float64
+type ProcessorFactory func(params map[string]any) (Processor, error)
+
+// ProcessorRegistry 按名称注册处理器工厂，可以被多个 goroutine 并发使用
+type ProcessorRegistry struct {
+	mu        sync.RWMutex
+	factories map[string]ProcessorFactory
+}
+
+// DefaultRegistry 默认注册表，包含所有内置处理器，名称与 StepSpec.Type 一致
+// 注册到默认注册表的处理器可以在处理器链配置中使用，参数通过 StepSpec.Params 传入
+var DefaultRegistry = NewProcessorRegistry()
+
+// builtinSteps 内置处理器类型
+var builtinSteps = []string{
+	StepZoom, StepCut, StepCircle, StepRotate, StepRoundedCorner, StepMosaic, StepWatermark,
+	StepOverlay, StepNoise, StepText, StepDrawCircle, StepDrawRect, StepEmpty, StepSharpen,
+	StepSeamCarve,
+}
+
+func init() {
+	for _, name := range builtinSteps {
+		if err := DefaultRegistry.Register(name, builtinFactory(name)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// NewProcessorRegistry 创建空的注册表
+func NewProcessorRegistry() *ProcessorRegistry {
+	return &ProcessorRegistry{factories: make(map[string]ProcessorFactory)}
+}
+
+// Register 注册处理器工厂，名称已存在时返回错误
+func (r *ProcessorRegistry) Register(name string, factory ProcessorFactory) error {
+	if name == "" || factory == nil {
+		return errors.New("处理器名称和工厂不能为空")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.factories[name]; ok {
+		return fmt.Errorf("处理器 %s 已注册", name)
+	}
+	r.factories[name] = factory
+	return nil
+}
+
+// Lookup 查找处理器工厂
+func (r *ProcessorRegistry) Lookup(name string) (ProcessorFactory, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+// Build 根据名称和参数创建处理器
+func (r *ProcessorRegistry) Build(name string, params map[string]any) (Processor, error) {
+	factory, ok := r.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("未注册的处理器: %s", name)
+	}
+	return factory(params)
+}
+
+// Names 返回所有已注册的处理器名称，按名称排序
+func (r *ProcessorRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterProcessor 向默认注册表注册处理器工厂
+func RegisterProcessor(name string, factory ProcessorFactory) error {
+	return DefaultRegistry.Register(name, factory)
+}
+
+// BuildProcessor 使用默认注册表创建处理器
+func BuildProcessor(name string, params map[string]any) (Processor, error) {
+	return DefaultRegistry.Build(name, params)
+}
+
+// builtinFactory 内置处理器工厂，参数名称与 StepSpec 的JSON字段一致
+func builtinFactory(stepType string) ProcessorFactory {
+	return func(params map[string]any) (Processor, error) {
+		step, err := decodeStepParams(params)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stepType, err)
+		}
+		step.Type = stepType
+
+		p, err := step.build(0)
+		var specErr *SpecError
+		if errors.As(err, &specErr) {
+			return nil, fmt.Errorf("%s.%s: %s", stepType, specErr.Field, specErr.Msg)
+		}
+		return p, err
+	}
+}
+
+// decodeStepParams 将参数转换为 StepSpec，未知参数返回错误
+func decodeStepParams(params map[string]any) (*StepSpec, error) {
+	step := &StepSpec{}
+	if len(params) == 0 {
+		return step, nil
+	}
+	for _, key := range []string{"type", "params"} {
+		if _, ok := params[key]; ok {
+			return nil, fmt.Errorf("参数中不能包含 %s", key)
+		}
+	}
+
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("无效的参数: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(step); err != nil {
+		return nil, fmt.Errorf("无效的参数: %w", err)
+	}
+	return step, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"testing"
+)
+
+// fillProcessor 测试用的第三方处理器，使用指定颜色填充图片
+type fillProcessor struct {
+	color color.Color
+}
+
+func (p *fillProcessor) Process(img image.Image) (image.Image, error) {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(p.color), image.Point{}, draw.Src)
+	return dst, nil
+}
+
+func init() {
+	if err := RegisterProcessor("test_fill", func(params map[string]any) (Processor, error) {
+		c, err := ParseHexColor(fmt.Sprint(params["color"]))
+		if err != nil {
+			return nil, err
+		}
+		return &fillProcessor{color: c}, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestBuildProcessor(t *testing.T) {
+	p, err := BuildProcessor(StepZoom, map[string]any{"mode": "width", "width": 100})
+	if err != nil {
+		t.Fatalf("创建处理器失败: %v", err)
+	}
+	if zoom, ok := p.(*ZoomProcessor); !ok || zoom.Mode != ZoomModeWidth || zoom.Width != 100 {
+		t.Fatalf("缩放处理器配置错误: %#v", p)
+	}
+
+	// YAML 解析得到的数值可能是 float64
+	if _, err := BuildProcessor(StepRoundedCorner, map[string]any{"radius": 8.0}); err != nil {
+		t.Fatalf("创建处理器失败: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		params map[string]any
+	}{
+		{StepZoom, map[string]any{"mode": "width"}},
+		{StepZoom, map[string]any{"widht": 10}},
+		{StepZoom, map[string]any{"type": "cut"}},
+		{"blur", nil},
+	} {
+		if _, err := BuildProcessor(tc.name, tc.params); err == nil {
+			t.Fatalf("%s %v: 期望返回错误", tc.name, tc.params)
+		}
+	}
+
+	if err := RegisterProcessor(StepZoom, func(map[string]any) (Processor, error) { return nil, nil }); err == nil {
+		t.Fatal("重复注册应返回错误")
+	}
+	if names := DefaultRegistry.Names(); !slices.Contains(names, StepCircle) || !slices.Contains(names, "test_fill") {
+		t.Fatalf("注册表名称错误: %v", names)
+	}
+}
+
+func TestPipelineSpecRegisteredProcessor(t *testing.T) {
+	processors, err := CompilePipeline([]byte(`
+steps:
+  - type: zoom
+    mode: width
+    width: 10
+  - type: test_fill
+    params:
+      color: "#ff0000"
+`))
+	if err != nil {
+		t.Fatalf("构建处理器链失败: %v", err)
+	}
+
+	result, err := Process(image.NewRGBA(image.Rect(0, 0, 20, 20)), processors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.RGBAModel.Convert(result.At(5, 5)).(color.RGBA); c.R != 255 || result.Bounds().Dx() != 10 {
+		t.Fatalf("处理结果错误: %v %v", c, result.Bounds())
+	}
+
+	// 参数错误和内置处理器使用 params
+	for _, data := range []string{
+		`{"steps":[{"type":"test_fill","params":{"color":"red"}}]}`,
+		`{"steps":[{"type":"zoom","params":{"width":10}}]}`,
+	} {
+		var specErr *SpecError
+		if _, err := ParsePipeline([]byte(data)); !errors.As(err, &specErr) || specErr.Field != "params" {
+			t.Fatalf("期望 steps[0].params 错误，实际 %v", err)
+		}
+	}
+}
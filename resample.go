@@ -0,0 +1,292 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+	"math"
+	"sync"
+
+	"golang.org/x/image/draw"
+)
+
+// 缩放算法，可以通过 ZoomProcessor.WithScaler 使用
+// draw.Kernel 缩小时按缩放比例扩大采样范围，可以直接用于大比例缩小。
+var (
+	// Lanczos2 两瓣 Lanczos 插值，比 Lanczos3 柔和，振铃较少
+	Lanczos2 = &draw.Kernel{Support: 2, At: lanczos2}
+	// Lanczos3 三瓣 Lanczos 插值，锐利，适合照片缩小
+	Lanczos3 = &draw.Kernel{Support: 3, At: lanczos3}
+	// MitchellNetravali Mitchell-Netravali 三次插值 (B=C=1/3)，在锐度和振铃之间折中
+	MitchellNetravali = &draw.Kernel{Support: 2, At: mitchellNetravali}
+	// Box 盒式滤波，缩小时对覆盖范围内的像素求平均
+	Box = &draw.Kernel{Support: 0.5, At: box}
+	// AreaAverage 按像素覆盖面积精确求平均，适合大比例缩小，放大时使用 draw.BiLinear
+	AreaAverage draw.Scaler = areaAverage{}
+)
+
+// sinc 归一化的 sinc 函数
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+func lanczos2(t float64) float64 {
+	if t < 0 {
+		t = -t
+	}
+	if t < 2 {
+		return sinc(t) * sinc(t/2)
+	}
+	return 0
+}
+
+func lanczos3(t float64) float64 {
+	if t < 0 {
+		t = -t
+	}
+	if t < 3 {
+		return sinc(t) * sinc(t/3)
+	}
+	return 0
+}
+
+func mitchellNetravali(t float64) float64 {
+	if t < 0 {
+		t = -t
+	}
+	switch {
+	case t < 1:
+		return (7*t*t*t - 12*t*t + 16.0/3) / 6
+	case t < 2:
+		return (-7.0/3*t*t*t + 12*t*t - 20*t + 32.0/3) / 6
+	}
+	return 0
+}
+
+func box(t float64) float64 {
+	if t >= -0.5 && t < 0.5 {
+		return 1
+	}
+	return 0
+}
+
+// areaAverage 按覆盖面积求平均的缩放算法
+type areaAverage struct{}
+
+// Scale 实现 draw.Scaler 接口，不支持 opts 中的遮罩
+func (areaAverage) Scale(dst draw.Image, dr image.Rectangle, src image.Image, sr image.Rectangle, op draw.Op,
+	opts *draw.Options,
+) {
+	dw, dh, sw, sh := dr.Dx(), dr.Dy(), sr.Dx(), sr.Dy()
+	if dw <= 0 || dh <= 0 || sw <= 0 || sh <= 0 {
+		return
+	}
+	if dw > sw || dh > sh {
+		draw.BiLinear.Scale(dst, dr, src, sr, op, opts)
+		return
+	}
+
+	in := rgbaRegion(src, sr)
+	xw, yw := areaWeights(sw, dw), areaWeights(sh, dh)
+
+	// 先水平后垂直，中间结果使用浮点数避免精度损失
+	tmp := make([]float64, dw*sh*4)
+	for y := 0; y < sh; y++ {
+		row := in.Pix[y*in.Stride:]
+		for x, ws := range xw {
+			var sum [4]float64
+			for _, w := range ws {
+				p := row[w.index*4 : w.index*4+4]
+				for c := 0; c < 4; c++ {
+					sum[c] += float64(p[c]) * w.weight
+				}
+			}
+			copy(tmp[(y*dw+x)*4:], sum[:])
+		}
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y, ws := range yw {
+		for x := 0; x < dw; x++ {
+			var sum [4]float64
+			for _, w := range ws {
+				p := tmp[(w.index*dw+x)*4:]
+				for c := 0; c < 4; c++ {
+					sum[c] += p[c] * w.weight
+				}
+			}
+			o := out.Pix[out.PixOffset(x, y):]
+			for c := 0; c < 4; c++ {
+				o[c] = uint8(min(math.Round(sum[c]), 255))
+			}
+		}
+	}
+
+	draw.Draw(dst, dr, out, image.Point{}, op)
+}
+
+// areaWeight 源像素及其覆盖面积占比
+type areaWeight struct {
+	index  int
+	weight float64
+}
+
+// areaWeights 计算缩小时每个目标像素覆盖的源像素及权重，权重之和为 1
+func areaWeights(srcSize, dstSize int) [][]areaWeight {
+	scale := float64(srcSize) / float64(dstSize)
+	weights := make([][]areaWeight, dstSize)
+	for d := range weights {
+		lo, hi := float64(d)*scale, float64(d+1)*scale
+		for s := int(lo); s < srcSize && float64(s) < hi; s++ {
+			overlap := min(float64(s+1), hi) - max(float64(s), lo)
+			if overlap > 0 {
+				weights[d] = append(weights[d], areaWeight{index: s, weight: overlap / scale})
+			}
+		}
+	}
+	return weights
+}
+
+// rgbaRegion 将 src 中的 sr 区域复制为原点为 (0,0)、行间没有间隔的 *image.RGBA，已经是该格式时直接返回
+// 原点为 (0,0) 的子图片与父图片共用 Stride，需要复制后才能按连续的 Pix 处理。
+func rgbaRegion(src image.Image, sr image.Rectangle) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Rect == sr && sr.Min == (image.Point{}) && rgba.Stride == 4*sr.Dx() {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, sr.Dx(), sr.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, sr.Min, draw.Src)
+	return rgba
+}
+
+// LinearScaler 在线性光空间中缩放，避免在 sRGB 空间中平均导致的边缘变暗和细节丢失
+// Scaler 为空时使用 draw.BiLinear。缩放前后各转换一次颜色空间，耗时和内存约为直接缩放的数倍。
+type LinearScaler struct {
+	Scaler draw.Scaler
+}
+
+// NewLinearScaler 创建在线性光空间中缩放的缩放算法
+func NewLinearScaler(scaler draw.Scaler) LinearScaler {
+	return LinearScaler{Scaler: scaler}
+}
+
+// Scale 实现 draw.Scaler 接口，不支持 opts 中的遮罩
+func (s LinearScaler) Scale(dst draw.Image, dr image.Rectangle, src image.Image, sr image.Rectangle, op draw.Op,
+	opts *draw.Options,
+) {
+	if dr.Empty() || sr.Empty() {
+		return
+	}
+	scaler := s.Scaler
+	if scaler == nil {
+		scaler = draw.BiLinear
+	}
+
+	linear := toLinear(rgbaRegion(src, sr))
+	scaled := image.NewRGBA64(image.Rect(0, 0, dr.Dx(), dr.Dy()))
+	scaler.Scale(scaled, scaled.Bounds(), linear, linear.Bounds(), draw.Src, nil)
+	draw.Draw(dst, dr, fromLinear(scaled), image.Point{}, op)
+}
+
+var (
+	linearOnce sync.Once
+	// srgbToLinear 8位 sRGB 值对应的16位线性值
+	srgbToLinear [256]uint16
+	// linearToSRGB 16位线性值对应的8位 sRGB 值
+	linearToSRGB []uint8
+)
+
+// initLinearTables 初始化 sRGB 与线性值的转换表
+func initLinearTables() {
+	for i := range srgbToLinear {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		srgbToLinear[i] = uint16(math.Round(v * 0xffff))
+	}
+
+	linearToSRGB = make([]uint8, 0x10000)
+	for i := range linearToSRGB {
+		v := float64(i) / 0xffff
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		linearToSRGB[i] = uint8(math.Round(v * 255))
+	}
+}
+
+// toLinear 将预乘的 sRGB 图像转换为预乘的16位线性图像
+func toLinear(img *image.RGBA) *image.RGBA64 {
+	linearOnce.Do(initLinearTables)
+
+	size := img.Rect.Size()
+	out := image.NewRGBA64(image.Rect(0, 0, size.X, size.Y))
+	for y := 0; y < size.Y; y++ {
+		in := img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y):]
+		o := out.Pix[out.PixOffset(0, y):]
+		for x := 0; x < size.X; x++ {
+			p := in[x*4 : x*4+4]
+			a := uint32(p[3])
+			if a == 0 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				// 去除预乘后转换为线性值，再按不透明度预乘
+				v := uint32(srgbToLinear[min((uint32(p[c])*255+a/2)/a, 255)]) * a / 255
+				o[x*8+c*2] = uint8(v >> 8)
+				o[x*8+c*2+1] = uint8(v)
+			}
+			o[x*8+6] = p[3]
+			o[x*8+7] = p[3]
+		}
+	}
+	return out
+}
+
+// fromLinear 将预乘的16位线性图像转换为预乘的 sRGB 图像
+func fromLinear(img *image.RGBA64) *image.RGBA {
+	size := img.Rect.Size()
+	out := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+	for y := 0; y < size.Y; y++ {
+		in := img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y):]
+		o := out.Pix[out.PixOffset(0, y):]
+		for x := 0; x < size.X; x++ {
+			p := in[x*8 : x*8+8]
+			a := uint32(p[6])<<8 | uint32(p[7])
+			if a == 0 {
+				continue
+			}
+			a8 := (a*255 + 0x7fff) / 0xffff
+			for c := 0; c < 3; c++ {
+				v := uint32(p[c*2])<<8 | uint32(p[c*2+1])
+				s := uint32(linearToSRGB[min((v*0xffff+a/2)/a, 0xffff)])
+				o[x*4+c] = uint8((s*a8 + 127) / 255)
+			}
+			o[x*4+3] = uint8(a8)
+		}
+	}
+	return out
+}
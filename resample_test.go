@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+func TestScalersKeepSolidColor(t *testing.T) {
+	solid := color.RGBA{R: 200, G: 100, B: 50, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	draw.Draw(img, img.Bounds(), image.NewUniform(solid), image.Point{}, draw.Src)
+
+	scalers := map[string]xdraw.Scaler{
+		"lanczos2":        Lanczos2,
+		"lanczos3":        Lanczos3,
+		"mitchell":        MitchellNetravali,
+		"box":             Box,
+		"area":            AreaAverage,
+		"area放大":          AreaAverage,
+		"linear-lanczos3": NewLinearScaler(Lanczos3),
+	}
+	for name, scaler := range scalers {
+		t.Run(name, func(t *testing.T) {
+			w, h := 20, 15
+			if name == "area放大" {
+				w, h = 100, 75
+			}
+			out, err := NewZoomProcessor(w, h).WithScaler(scaler).Process(img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					c := color.RGBAModel.Convert(out.At(x, y)).(color.RGBA)
+					if absDiff(int(c.R)-200) > 1 || absDiff(int(c.G)-100) > 1 || absDiff(int(c.B)-50) > 1 || c.A != 255 {
+						t.Fatalf("(%d,%d) 期望 %v，实际 %v", x, y, solid, c)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestAreaAverage(t *testing.T) {
+	weights := areaWeights(3, 2)
+	if len(weights[0]) != 2 || weights[0][0].weight < 0.66 || weights[0][0].weight > 0.67 || weights[1][1].index != 2 {
+		t.Fatalf("权重错误: %v", weights)
+	}
+
+	// 黑白相间的像素缩小一半后为灰色
+	img := image.NewGray(image.Rect(0, 0, 4, 2))
+	for i := range img.Pix {
+		if i%2 == 1 {
+			img.Pix[i] = 255
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	AreaAverage.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	if dst.Pix[0] != 128 || dst.Pix[4] != 128 || dst.Pix[3] != 255 {
+		t.Fatalf("期望灰色 128，实际 %v", dst.Pix)
+	}
+}
+
+func TestLinearScaler(t *testing.T) {
+	// 线性光空间中黑白平均后约为 sRGB 188，sRGB 空间中平均为 128
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.Pix[0], img.Pix[3] = 255, 255
+
+	dst := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	NewLinearScaler(AreaAverage).Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	if absDiff(int(dst.Pix[0])-188) > 1 || dst.Pix[3] != 255 {
+		t.Fatalf("期望约 188，实际 %v", dst.Pix)
+	}
+
+	// 透明像素保持透明，半透明像素保持颜色
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	draw.Draw(nrgba, image.Rect(0, 0, 4, 2), image.NewUniform(color.NRGBA{R: 255, A: 128}), image.Point{}, draw.Src)
+	dst = image.NewRGBA(image.Rect(0, 0, 2, 2))
+	NewLinearScaler(AreaAverage).Scale(dst, dst.Bounds(), nrgba, nrgba.Bounds(), xdraw.Src, nil)
+	if c := color.NRGBAModel.Convert(dst.At(0, 0)).(color.NRGBA); c.R < 250 || absDiff(int(c.A)-128) > 1 {
+		t.Fatalf("半透明像素颜色错误: %v", c)
+	}
+	if c := dst.RGBAAt(1, 1); c.A != 0 {
+		t.Fatalf("期望透明，实际 %v", c)
+	}
+}
+
+func TestSharpenProcessor(t *testing.T) {
+	// 左侧灰度 100，右侧灰度 150
+	img := image.NewRGBA(image.Rect(0, 0, 20, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 20; x++ {
+			v := uint8(100)
+			if x >= 10 {
+				v = 150
+			}
+			img.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	out, err := NewSharpenProcessor(1, 1).Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharpened := out.(*image.RGBA)
+	if sharpened.RGBAAt(9, 0).R >= 100 || sharpened.RGBAAt(10, 0).R <= 150 {
+		t.Fatalf("边缘未增强: %v %v", sharpened.RGBAAt(9, 0), sharpened.RGBAAt(10, 0))
+	}
+	if sharpened.RGBAAt(0, 0).R != 100 || sharpened.RGBAAt(19, 0).A != 255 {
+		t.Fatalf("平坦区域不应改变: %v", sharpened.RGBAAt(0, 0))
+	}
+
+	// 差异不超过阈值时不锐化
+	out, _ = NewSharpenProcessor(1, 1).WithThreshold(60).Process(img)
+	if out.(*image.RGBA).RGBAAt(9, 0).R != 100 {
+		t.Fatal("阈值以内不应锐化")
+	}
+
+	// 原点为 (0,0) 的子图片与父图片共用 Stride，左上角红色区域锐化后仍为红色
+	split := image.NewRGBA(image.Rect(0, 0, 80, 40))
+	draw.Draw(split, image.Rect(0, 0, 40, 40), image.NewUniform(testRed), image.Point{}, draw.Src)
+	draw.Draw(split, image.Rect(40, 0, 80, 40), image.NewUniform(testBlue), image.Point{}, draw.Src)
+	cut, err := NewCutProcessorWithRegion(40, 40, 0, 0).Process(split)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err = NewSharpenProcessor(1, 1).Process(cut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if c := out.(*image.RGBA).RGBAAt(x, y); c != testRed {
+				t.Fatalf("子图片锐化错误，(%d,%d) 为 %v", x, y, c)
+			}
+		}
+	}
+
+	if _, err := NewSharpenProcessor(-1, 1).Process(img); err == nil {
+		t.Fatal("期望无效参数错误")
+	}
+
+	// 缩放时只在缩小后锐化
+	zoom := NewZoomProcessor(40, 8).WithSharpen(NewSharpenProcessor(1, 1))
+	upscaled, _ := zoom.Process(img)
+	plain, _ := NewZoomProcessor(40, 8).Process(img)
+	if upscaled.(*image.RGBA).RGBAAt(19, 0) != plain.(*image.RGBA).RGBAAt(19, 0) {
+		t.Fatal("放大时不应锐化")
+	}
+}
+
+func TestPipelineSpecSharpen(t *testing.T) {
+	spec, err := ParsePipelineJSON([]byte(`{"steps":[
+		{"type":"zoom","mode":"width","width":10,"scaler":"linear-lanczos3","amount":0.5},
+		{"type":"sharpen","amount":0.8,"sigma":1.5,"threshold":4}]}`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	processors, err := spec.Processors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zoom := processors[0].(*ZoomProcessor)
+	if zoom.Scaler != NewLinearScaler(Lanczos3) || zoom.Sharpen == nil || zoom.Sharpen.Amount != 0.5 {
+		t.Fatalf("缩放处理器错误: %+v", zoom)
+	}
+	if sharpen := processors[1].(*SharpenProcessor); *sharpen != (SharpenProcessor{Amount: 0.8, Sigma: 1.5, Threshold: 4}) {
+		t.Fatalf("锐化处理器错误: %+v", sharpen)
+	}
+
+	if _, err := CacheKey([]byte("data"), processors, nil); err != nil {
+		t.Fatalf("期望可以缓存: %v", err)
+	}
+
+	if _, err := ParsePipelineJSON([]byte(`{"steps":[{"type":"zoom","mode":"width","width":10,"scaler":"linear-foo"}]}`)); err == nil {
+		t.Fatal("期望未知缩放算法错误")
+	}
+	for _, data := range []string{
+		"steps:\n  - type: sharpen\n    amount: .nan\n",
+		"steps:\n  - type: sharpen\n    amount: 1\n    sigma: .inf\n",
+	} {
+		if _, err := ParsePipelineYAML([]byte(data)); err == nil {
+			t.Fatalf("期望无效数值错误: %q", data)
+		}
+	}
+}
@@ -18,6 +18,7 @@
 package vimage
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	"math"
@@ -47,17 +48,9 @@ func (p *RotateProcessor) Process(img image.Image) (image.Image, error) {
 	angle := p.Angle * math.Pi / 180.0
 
 	// 计算旋转后的图像尺寸
-	var width, height int
-	if p.KeepSize {
-		// 保持原始尺寸
-		width = origWidth
-		height = origHeight
-	} else {
-		// 计算旋转后的尺寸
-		absCos := math.Abs(math.Cos(angle))
-		absSin := math.Abs(math.Sin(angle))
-		width = int(math.Ceil(float64(origWidth)*absCos + float64(origHeight)*absSin))
-		height = int(math.Ceil(float64(origWidth)*absSin + float64(origHeight)*absCos))
+	width, height, err := p.PlanSize(origWidth, origHeight)
+	if err != nil {
+		return nil, err
 	}
 
 	// 创建gg上下文
@@ -100,6 +93,25 @@ func (p *RotateProcessor) Process(img image.Image) (image.Image, error) {
 	return dc.Image(), nil
 }
 
+// PlanSize 实现 Planner 接口，计算旋转后的图像尺寸
+func (p *RotateProcessor) PlanSize(origWidth, origHeight int) (int, int, error) {
+	if math.IsNaN(p.Angle) || math.IsInf(p.Angle, 0) {
+		return 0, 0, fmt.Errorf("无效的旋转角度: %v", p.Angle)
+	}
+	if p.KeepSize {
+		// 保持原始尺寸
+		return origWidth, origHeight, nil
+	}
+
+	// 计算旋转后的尺寸
+	angle := p.Angle * math.Pi / 180.0
+	absCos := math.Abs(math.Cos(angle))
+	absSin := math.Abs(math.Sin(angle))
+	width := int(math.Ceil(float64(origWidth)*absCos + float64(origHeight)*absSin))
+	height := int(math.Ceil(float64(origWidth)*absSin + float64(origHeight)*absCos))
+	return width, height, nil
+}
+
 // gg库内部已经实现了高质量的图像旋转和插值算法，不再需要自定义实现
 
 // NewRotateProcessor 创建新的旋转处理器
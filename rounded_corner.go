@@ -18,6 +18,7 @@
 package vimage
 
 import (
+	"context"
 	"image"
 	"image/color"
 	"math"
@@ -46,6 +47,11 @@ func NewRoundedCornerProcessor(radius int) *RoundedCornerProcessor {
 // Process 实现Processor接口
 // 将图片的四个角切割成圆角，角外部分变为透明
 func (p *RoundedCornerProcessor) Process(img image.Image) (image.Image, error) {
+	return p.ProcessWithContext(context.Background(), img)
+}
+
+// ProcessWithContext 实现 CancelableProcessor 接口
+func (p *RoundedCornerProcessor) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
 	// 获取图片边界
 	bounds := img.Bounds()
 	width := bounds.Dx()
@@ -57,6 +63,9 @@ func (p *RoundedCornerProcessor) Process(img image.Image) (image.Image, error) {
 	// 如果半径为0，直接返回原图
 	if p.Radius <= 0 {
 		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			if err := checkCanceled(ctx, y-bounds.Min.Y); err != nil {
+				return nil, err
+			}
 			for x := bounds.Min.X; x < bounds.Max.X; x++ {
 				dst.Set(x, y, img.At(x, y))
 			}
@@ -75,6 +84,9 @@ func (p *RoundedCornerProcessor) Process(img image.Image) (image.Image, error) {
 
 	// 处理每个像素
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if err := checkCanceled(ctx, y-bounds.Min.Y); err != nil {
+			return nil, err
+		}
 		for x := bounds.Min.X; x < bounds.Max.X; x++ {
 			// 计算alpha
 			alpha := getCornerAlpha(x, y, bounds, float64(radius), 1.5)
@@ -93,6 +105,11 @@ func (p *RoundedCornerProcessor) Process(img image.Image) (image.Image, error) {
 	return dst, nil
 }
 
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *RoundedCornerProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
+
 // getCornerAlpha 计算像素在圆角区域的透明度
 // 返回0.0到1.0之间的值，表示透明度
 func getCornerAlpha(x, y int, bounds image.Rectangle, radius float64, fadeWidth float64) float64 {
@@ -0,0 +1,493 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"slices"
+)
+
+// 像素标记
+const (
+	seamMaskNone     int8 = 0
+	seamMaskProtect  int8 = 1  // 保护区域
+	seamMaskInserted int8 = 2  // 插入接缝时复制过的像素
+	seamMaskRemove   int8 = -1 // 删除区域
+)
+
+const (
+	// seamProtectEnergy 保护区域像素增加的能量，远大于梯度能量，接缝只在无路可走时穿过保护区域
+	seamProtectEnergy = 1 << 24
+	// seamRemoveEnergy 删除区域像素减少的能量，接缝优先穿过删除区域
+	seamRemoveEnergy = 1 << 24
+	// seamInsertedEnergy 复制过的像素增加的能量，避免多轮插入时反复复制同一条接缝
+	seamInsertedEnergy = 1 << 10
+)
+
+// DefaultSeamCarveMaxWork SeamCarveProcessor 默认的计算量上限，约为 1000x1000 的图片删除或插入 1000 条接缝
+const DefaultSeamCarveMaxWork int64 = 1_000_000_000
+
+// SeamCarveProcessor 接缝裁剪处理器，按内容感知的方式改变图片宽高比
+// 每次删除或复制一条从上到下（或从左到右）能量最低的像素路径（接缝），能量为亮度梯度，
+// 人物、文字等细节丰富的区域能量高，会被保留，平坦的背景被压缩或拉伸，不会像 ZoomModeExact 一样整体变形。
+// 先删除 Remove 区域的所有像素，再删除或插入接缝到目标尺寸，Protect 区域的像素不删除也不复制。
+// 每条接缝都需要在整张图片上查找，耗时与图片面积和接缝数量的乘积成正比，大图建议先缩小；
+// 计算量 (每条接缝查找时的图片像素数之和) 超过 MaxWork 时返回 *LimitError。
+type SeamCarveProcessor struct {
+	Width   int             // 目标宽度，0 表示保持处理前的宽度
+	Height  int             // 目标高度，0 表示保持处理前的高度
+	Protect []*MosaicRegion // 保护区域
+	Remove  []*MosaicRegion // 删除区域，与保护区域重叠的部分仍然删除
+	MaxWork int64           // 计算量上限，0 表示使用 DefaultSeamCarveMaxWork，小于 0 表示不限制
+}
+
+// NewSeamCarveProcessor 创建接缝裁剪处理器
+func NewSeamCarveProcessor(width, height int) *SeamCarveProcessor {
+	return &SeamCarveProcessor{
+		Width:  width,
+		Height: height,
+	}
+}
+
+// WithProtect 添加保护区域
+func (p *SeamCarveProcessor) WithProtect(regions ...*MosaicRegion) *SeamCarveProcessor {
+	p.Protect = append(p.Protect, regions...)
+	return p
+}
+
+// WithRemove 添加删除区域
+func (p *SeamCarveProcessor) WithRemove(regions ...*MosaicRegion) *SeamCarveProcessor {
+	p.Remove = append(p.Remove, regions...)
+	return p
+}
+
+// Process 实现Processor接口
+func (p *SeamCarveProcessor) Process(img image.Image) (image.Image, error) {
+	return p.ProcessWithContext(context.Background(), img)
+}
+
+// ProcessWithContext 实现 CancelableProcessor 接口，每条接缝之前检查 ctx
+func (p *SeamCarveProcessor) ProcessWithContext(ctx context.Context, img image.Image) (image.Image, error) {
+	bounds := img.Bounds()
+	width, height, err := p.PlanSize(bounds.Dx(), bounds.Dy())
+	if err != nil {
+		return nil, err
+	}
+
+	c := newSeamCarver(rgbaRegion(img, bounds))
+	c.budget = &seamBudget{max: p.maxWork()}
+	c.markRegions(p.Protect, seamMaskProtect)
+	c.markRegions(p.Remove, seamMaskRemove)
+
+	if c, err = c.removeMarked(ctx); err != nil {
+		return nil, err
+	}
+	if c, err = c.resizeWidth(ctx, width); err != nil {
+		return nil, err
+	}
+	c = c.transpose()
+	if c, err = c.resizeWidth(ctx, height); err != nil {
+		return nil, err
+	}
+	return c.transpose().image(), nil
+}
+
+// contentAware 实现 geometryResolver 接口，接缝根据图片内容选择
+func (p *SeamCarveProcessor) contentAware() bool {
+	return true
+}
+
+// resolveGeometry 实现 geometryResolver 接口，每一帧移除的接缝不同，不支持处理动画
+func (p *SeamCarveProcessor) resolveGeometry(image.Image) (Processor, error) {
+	return nil, errors.New("接缝裁剪在每一帧上移除不同的接缝，不支持处理动画")
+}
+
+// PlanSize 实现 Planner 接口，未指定的边保持原尺寸
+// 调整尺寸的计算量超过上限时返回 *LimitError，删除区域的计算量在处理时检查。
+func (p *SeamCarveProcessor) PlanSize(width, height int) (int, int, error) {
+	if p.Width < 0 || p.Height < 0 {
+		return 0, 0, fmt.Errorf("无效的接缝裁剪尺寸: %dx%d", p.Width, p.Height)
+	}
+	targetWidth, targetHeight := width, height
+	if p.Width > 0 {
+		targetWidth = p.Width
+	}
+	if p.Height > 0 {
+		targetHeight = p.Height
+	}
+
+	if limit := p.maxWork(); limit > 0 {
+		if work := seamWork(width, height, targetWidth, targetHeight); work > limit {
+			return 0, 0, &LimitError{Kind: LimitSeamCarve, Step: -1, Value: work, Max: limit}
+		}
+	}
+	return targetWidth, targetHeight, nil
+}
+
+// maxWork 返回计算量上限，小于等于 0 表示不限制
+func (p *SeamCarveProcessor) maxWork() int64 {
+	if p.MaxWork == 0 {
+		return DefaultSeamCarveMaxWork
+	}
+	return p.MaxWork
+}
+
+// seamWork 估算将 width x height 调整为 targetWidth x targetHeight 的计算量上界
+// 先调整宽度，每条竖直接缝查找时的图片不超过 max(width, targetWidth) x height；再调整高度。
+func seamWork(width, height, targetWidth, targetHeight int) int64 {
+	maxWidth, maxHeight := max(width, targetWidth), max(height, targetHeight)
+	horizontal := int64(maxWidth-min(width, targetWidth)) * int64(maxWidth) * int64(height)
+	vertical := int64(maxHeight-min(height, targetHeight)) * int64(maxHeight) * int64(targetWidth)
+	return horizontal + vertical
+}
+
+// seamBudget 一次处理中所有接缝的计算量，转置和复制的工作图像共享
+type seamBudget struct {
+	used int64
+	max  int64 // 小于等于 0 表示不限制
+}
+
+// spend 记录查找一条接缝的计算量，超出上限时返回 *LimitError
+func (b *seamBudget) spend(pixels int) error {
+	b.used += int64(pixels)
+	if b.max > 0 && b.used > b.max {
+		return &LimitError{Kind: LimitSeamCarve, Step: -1, Value: b.used, Max: b.max}
+	}
+	return nil
+}
+
+// seamCarver 接缝裁剪的工作图像，只处理竖直接缝，水平接缝通过转置处理
+type seamCarver struct {
+	w, h   int
+	stride int     // 每行的像素数，删除接缝后行尾的像素不再使用
+	pix    []uint8 // 预乘的 RGBA 像素
+	mask   []int8  // 像素标记
+	index  []int32 // 像素在插入接缝前的列号，只在查找插入位置时使用
+	budget *seamBudget
+
+	// 每个像素的亮度和能量，与 mask 的布局相同；删除接缝时随像素移动，只重新计算接缝附近的能量
+	luma     []int32
+	energies []int64
+	cost     []int64
+}
+
+func newSeamCarver(img *image.RGBA) *seamCarver {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	c := &seamCarver{w: w, h: h, stride: w, pix: make([]uint8, w*h*4), mask: make([]int8, w*h)}
+	for y := 0; y < h; y++ {
+		copy(c.pix[y*w*4:(y+1)*w*4], img.Pix[y*img.Stride:])
+	}
+	return c
+}
+
+// markRegions 标记区域内的像素，超出图片的部分忽略
+func (c *seamCarver) markRegions(regions []*MosaicRegion, mark int8) {
+	c.energies = nil
+	for _, r := range regions {
+		if r == nil {
+			continue
+		}
+		for y := max(r.FromY, 0); y < min(r.ToY, c.h); y++ {
+			for x := max(r.FromX, 0); x < min(r.ToX, c.w); x++ {
+				c.mask[y*c.stride+x] = mark
+			}
+		}
+	}
+}
+
+// removeMarked 删除所有标记为删除的像素，删除区域窄而高时删除竖直接缝，否则删除水平接缝
+func (c *seamCarver) removeMarked(ctx context.Context) (*seamCarver, error) {
+	minX, minY, maxX, maxY, count := c.w, c.h, -1, -1, 0
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			if c.mask[y*c.stride+x] == seamMaskRemove {
+				minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return c, nil
+	}
+
+	horizontal := maxX-minX > maxY-minY
+	if horizontal {
+		c = c.transpose()
+	}
+	for count > 0 && c.w > 1 {
+		seam, err := c.nextSeam(ctx)
+		if err != nil {
+			return nil, err
+		}
+		removed := 0
+		for y, x := range seam {
+			if c.mask[y*c.stride+x] == seamMaskRemove {
+				removed++
+			}
+		}
+		// 删除区域被保护区域包围时接缝无法穿过，不再继续
+		if removed == 0 {
+			break
+		}
+		c.removeSeam(seam)
+		count -= removed
+	}
+	if horizontal {
+		c = c.transpose()
+	}
+	return c, nil
+}
+
+// resizeWidth 删除或插入竖直接缝，将宽度调整为 width
+func (c *seamCarver) resizeWidth(ctx context.Context, width int) (*seamCarver, error) {
+	for c.w > width {
+		seam, err := c.nextSeam(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.removeSeam(seam)
+	}
+	for c.w < width {
+		// 每轮最多插入当前宽度一半的接缝，避免复制同一区域
+		var err error
+		if c, err = c.insertSeams(ctx, min(width-c.w, max(c.w/2, 1))); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// nextSeam 检查 ctx 和计算量上限，然后查找能量最低的竖直接缝
+func (c *seamCarver) nextSeam(ctx context.Context) ([]int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.budget != nil {
+		if err := c.budget.spend(c.w * c.h); err != nil {
+			return nil, err
+		}
+	}
+	return c.findSeam(), nil
+}
+
+// findSeam 使用动态规划查找能量最低的竖直接缝，返回每行接缝所在的列
+func (c *seamCarver) findSeam() []int {
+	if c.energies == nil {
+		c.computeEnergy()
+	}
+	if len(c.cost) < c.w*c.h {
+		c.cost = make([]int64, c.w*c.h)
+	}
+
+	w := c.w
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < w; x++ {
+			e := c.energies[y*c.stride+x]
+			if y > 0 {
+				prev := c.cost[(y-1)*w:]
+				m := prev[x]
+				if x > 0 {
+					m = min(m, prev[x-1])
+				}
+				if x < w-1 {
+					m = min(m, prev[x+1])
+				}
+				e += m
+			}
+			c.cost[y*w+x] = e
+		}
+	}
+
+	seam := make([]int, c.h)
+	last := c.cost[(c.h-1)*w : c.h*w]
+	seam[c.h-1] = slices.Index(last, slices.Min(last))
+	for y := c.h - 2; y >= 0; y-- {
+		x := seam[y+1]
+		best := x
+		for _, nx := range [2]int{x - 1, x + 1} {
+			if nx >= 0 && nx < w && c.cost[y*w+nx] < c.cost[y*w+best] {
+				best = nx
+			}
+		}
+		seam[y] = best
+	}
+	return seam
+}
+
+// computeEnergy 计算每个像素的亮度和能量，亮度放大 1000 倍避免浮点运算
+func (c *seamCarver) computeEnergy() {
+	c.luma = make([]int32, len(c.mask))
+	c.energies = make([]int64, len(c.mask))
+	for y := 0; y < c.h; y++ {
+		row := c.pix[y*c.stride*4:]
+		for x := 0; x < c.w; x++ {
+			p := row[x*4:]
+			c.luma[y*c.stride+x] = 299*int32(p[0]) + 587*int32(p[1]) + 114*int32(p[2])
+		}
+	}
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			c.energies[y*c.stride+x] = c.energy(x, y)
+		}
+	}
+}
+
+// energy 像素能量为水平和竖直方向亮度的中心差分之和，再按像素标记调整
+func (c *seamCarver) energy(x, y int) int64 {
+	row := y * c.stride
+	gx := c.luma[row+min(x+1, c.w-1)] - c.luma[row+max(x-1, 0)]
+	gy := c.luma[min(y+1, c.h-1)*c.stride+x] - c.luma[max(y-1, 0)*c.stride+x]
+	e := int64(max(gx, -gx)+max(gy, -gy)) / 1000
+
+	switch c.mask[y*c.stride+x] {
+	case seamMaskProtect:
+		e += seamProtectEnergy
+	case seamMaskRemove:
+		e -= seamRemoveEnergy
+	case seamMaskInserted:
+		e += seamInsertedEnergy
+	}
+	return e
+}
+
+// removeSeam 删除接缝上的像素，每行接缝右侧的像素左移一列
+// 像素的能量只取决于上下左右相邻的像素，只有接缝两侧的像素需要重新计算能量。
+func (c *seamCarver) removeSeam(seam []int) {
+	for y, x := range seam {
+		row := y * c.stride
+		copy(c.pix[(row+x)*4:(row+c.w)*4], c.pix[(row+x+1)*4:(row+c.w)*4])
+		copy(c.mask[row+x:row+c.w], c.mask[row+x+1:row+c.w])
+		if c.index != nil {
+			copy(c.index[row+x:row+c.w], c.index[row+x+1:row+c.w])
+		}
+		if c.energies != nil {
+			copy(c.luma[row+x:row+c.w], c.luma[row+x+1:row+c.w])
+			copy(c.energies[row+x:row+c.w], c.energies[row+x+1:row+c.w])
+		}
+	}
+	c.w--
+
+	if c.energies == nil {
+		return
+	}
+	// 接缝相邻两行的列号最多相差 1，左移后上下相邻的像素只在这些列之间变化
+	for y, x := range seam {
+		lo, hi := x, x
+		if y > 0 {
+			lo, hi = min(lo, seam[y-1]), max(hi, seam[y-1])
+		}
+		if y < c.h-1 {
+			lo, hi = min(lo, seam[y+1]), max(hi, seam[y+1])
+		}
+		for nx := max(lo-1, 0); nx <= min(hi, c.w-1); nx++ {
+			c.energies[y*c.stride+nx] = c.energy(nx, y)
+		}
+	}
+}
+
+// insertSeams 在副本上依次删除 n 条接缝，记录它们在原图中的位置，再在原图中复制这些接缝
+// 复制的像素为接缝像素与右侧像素的平均值，插入在接缝像素右侧。
+func (c *seamCarver) insertSeams(ctx context.Context, n int) (*seamCarver, error) {
+	finder := c.clone()
+	finder.index = make([]int32, len(finder.mask))
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			finder.index[y*c.stride+x] = int32(x)
+		}
+	}
+
+	// 每行需要复制的原始列号
+	dup := make([][]int, c.h)
+	for i := 0; i < n; i++ {
+		seam, err := finder.nextSeam(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for y, x := range seam {
+			dup[y] = append(dup[y], int(finder.index[y*finder.stride+x]))
+		}
+		finder.removeSeam(seam)
+	}
+
+	w := c.w + n
+	out := &seamCarver{w: w, h: c.h, stride: w, pix: make([]uint8, w*c.h*4), mask: make([]int8, w*c.h), budget: c.budget}
+	for y := 0; y < c.h; y++ {
+		slices.Sort(dup[y])
+		src := c.pix[y*c.stride*4:]
+		dst := out.pix[y*w*4:]
+		mask := out.mask[y*w:]
+		nx := 0
+		for x, d := 0, 0; x < c.w; x++ {
+			copy(dst[nx*4:nx*4+4], src[x*4:x*4+4])
+			mask[nx] = c.mask[y*c.stride+x]
+			nx++
+			for d < len(dup[y]) && dup[y][d] == x {
+				right := min(x+1, c.w-1)
+				for ch := 0; ch < 4; ch++ {
+					dst[nx*4+ch] = uint8((int(src[x*4+ch]) + int(src[right*4+ch]) + 1) / 2)
+				}
+				if mask[nx-1] != seamMaskProtect {
+					mask[nx-1] = seamMaskInserted
+				}
+				mask[nx] = mask[nx-1]
+				nx++
+				d++
+			}
+		}
+	}
+	return out, nil
+}
+
+// clone 复制工作图像
+func (c *seamCarver) clone() *seamCarver {
+	return &seamCarver{
+		w:      c.w,
+		h:      c.h,
+		stride: c.stride,
+		pix:    slices.Clone(c.pix),
+		mask:   slices.Clone(c.mask),
+		budget: c.budget,
+	}
+}
+
+// transpose 转置工作图像，水平接缝变为竖直接缝
+func (c *seamCarver) transpose() *seamCarver {
+	out := &seamCarver{
+		w: c.h, h: c.w, stride: c.h,
+		pix: make([]uint8, c.w*c.h*4), mask: make([]int8, c.w*c.h), budget: c.budget,
+	}
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			copy(out.pix[(x*out.stride+y)*4:(x*out.stride+y)*4+4], c.pix[(y*c.stride+x)*4:])
+			out.mask[x*out.stride+y] = c.mask[y*c.stride+x]
+		}
+	}
+	return out
+}
+
+// image 返回原点为 (0,0) 的 RGBA 图像
+func (c *seamCarver) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, c.w, c.h))
+	for y := 0; y < c.h; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+c.w*4], c.pix[y*c.stride*4:])
+	}
+	return img
+}
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var testGray = color.RGBA{R: 128, G: 128, B: 128, A: 255}
+
+// bannerImage 灰色背景上左右各有一个 40x40 的红色方块
+func bannerImage() *image.RGBA {
+	img := subjectImage(300, 100, image.Rect(20, 30, 60, 70), testRed)
+	draw.Draw(img, image.Rect(240, 30, 280, 70), image.NewUniform(testRed), image.Point{}, draw.Src)
+	return img
+}
+
+func TestSeamCarveProcessor(t *testing.T) {
+	tests := []struct {
+		name          string
+		processor     *SeamCarveProcessor
+		width, height int
+	}{
+		{"缩小宽度", NewSeamCarveProcessor(200, 0), 200, 100},
+		{"放大宽度", NewSeamCarveProcessor(400, 0), 400, 100},
+		{"缩小高度", NewSeamCarveProcessor(0, 80), 300, 80},
+		{"同时调整", NewSeamCarveProcessor(250, 120), 250, 120},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := tc.processor.Process(bannerImage())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b := out.Bounds(); b.Dx() != tc.width || b.Dy() != tc.height {
+				t.Fatalf("尺寸期望 %dx%d，实际 %v", tc.width, tc.height, b)
+			}
+			// 接缝只经过平坦的背景，红色方块保持不变
+			if n := countColor(out, testRed); n != 2*40*40 {
+				t.Fatalf("红色方块被改变，红色像素 %d 个", n)
+			}
+		})
+	}
+
+	if _, _, err := NewSeamCarveProcessor(-1, 0).PlanSize(300, 100); err == nil {
+		t.Fatal("期望无效尺寸错误")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewSeamCarveProcessor(200, 0).ProcessWithContext(ctx, bannerImage()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望取消错误，实际 %v", err)
+	}
+}
+
+func TestSeamCarveRegions(t *testing.T) {
+	// 左侧为能量高的条纹，右侧为平坦的蓝色，保护右侧后只能删除左侧
+	img := subjectImage(300, 50, image.Rect(100, 0, 300, 50), testBlue)
+	for x := 0; x < 100; x += 2 {
+		draw.Draw(img, image.Rect(x, 0, x+1, 50), image.NewUniform(testWhite), image.Point{}, draw.Src)
+	}
+	out, err := NewSeamCarveProcessor(200, 0).WithProtect(&MosaicRegion{FromX: 100, ToX: 300, ToY: 50}).Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countColor(out, testBlue); n != 200*50 {
+		t.Fatalf("保护区域被改变，蓝色像素 %d 个", n)
+	}
+
+	// 删除红色方块，未指定尺寸时插入接缝恢复原尺寸
+	banner := bannerImage()
+	remove := &MosaicRegion{FromX: 15, FromY: 25, ToX: 65, ToY: 75}
+	for _, p := range []*SeamCarveProcessor{
+		NewSeamCarveProcessor(0, 0).WithRemove(remove),
+		NewSeamCarveProcessor(250, 0).WithRemove(remove),
+	} {
+		out, err := p.Process(banner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, _, _ := p.PlanSize(300, 100)
+		if b := out.Bounds(); b.Dx() != w || b.Dy() != 100 {
+			t.Fatalf("尺寸期望 %dx100，实际 %v", w, b)
+		}
+		if n := countColor(out, testRed); n != 40*40 {
+			t.Fatalf("期望只剩右侧的红色方块，红色像素 %d 个", n)
+		}
+	}
+}
+
+func TestSeamCarveEnergyUpdate(t *testing.T) {
+	img := gradientImage(60, 40)
+	for i := 0; i < 200; i++ {
+		img.Set(i*37%60, i*13%40, testWhite)
+	}
+	c := newSeamCarver(img)
+	c.markRegions([]*MosaicRegion{{FromX: 10, FromY: 10, ToX: 20, ToY: 20}}, seamMaskProtect)
+	for i := 0; i < 25; i++ {
+		c.removeSeam(c.findSeam())
+	}
+
+	// 只更新接缝附近的能量，结果与重新计算整张图片的能量一致
+	full := c.clone()
+	full.computeEnergy()
+	for y := 0; y < c.h; y++ {
+		for x := 0; x < c.w; x++ {
+			if i := y*c.stride + x; c.energies[i] != full.energies[i] {
+				t.Fatalf("(%d,%d) 能量期望 %d，实际 %d", x, y, full.energies[i], c.energies[i])
+			}
+		}
+	}
+}
+
+func TestSeamCarveWorkLimit(t *testing.T) {
+	// 7000x7000 的图片删除到 1x1 在规划时即超出默认上限，不需要解码
+	var limitErr *LimitError
+	_, err := Plan(7000, 7000, []Processor{NewZoomRatioProcessor(1), NewSeamCarveProcessor(1, 1)})
+	if !errors.As(err, &limitErr) || limitErr.Kind != LimitSeamCarve || limitErr.Step != 1 {
+		t.Fatalf("期望计算量超出限制，实际 %v", err)
+	}
+
+	// 300x100 缩小为 200x100 的计算量上界为 100*300*100
+	p := NewSeamCarveProcessor(200, 0)
+	p.MaxWork = 100*300*100 - 1
+	if _, err := ProcessWithContext(context.Background(), bannerImage(), []Processor{p}); !errors.As(err, &limitErr) || limitErr.Step != 0 {
+		t.Fatalf("期望计算量超出限制，实际 %v", err)
+	}
+	p.MaxWork = 100 * 300 * 100
+	if _, err := p.Process(bannerImage()); err != nil {
+		t.Fatalf("未超出限制时处理失败: %v", err)
+	}
+	p.MaxWork = -1
+	if _, _, err := p.PlanSize(7000, 7000); err != nil {
+		t.Fatalf("不限制时期望规划成功: %v", err)
+	}
+
+	// 删除区域的接缝数量无法预测，处理时检查
+	remove := NewSeamCarveProcessor(0, 0).WithRemove(&MosaicRegion{FromX: 15, FromY: 25, ToX: 65, ToY: 75})
+	remove.MaxWork = 300 * 100 * 10
+	if _, err := remove.Process(bannerImage()); !errors.As(err, &limitErr) || limitErr.Kind != LimitSeamCarve {
+		t.Fatalf("期望计算量超出限制，实际 %v", err)
+	}
+}
+
+func TestPipelineSpecSeamCarve(t *testing.T) {
+	spec, err := ParsePipelineJSON([]byte(`{"steps":[{"type":"seam_carve","width":200,
+		"protect":[{"from_x":0,"from_y":0,"to_x":10,"to_y":10}],"remove":[{"from_x":20,"from_y":20,"to_x":30,"to_y":30}]}]}`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	processors, err := spec.Processors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := processors[0].(*SeamCarveProcessor); p.Width != 200 || len(p.Protect) != 1 || len(p.Remove) != 1 {
+		t.Fatalf("接缝裁剪处理器错误: %+v", p)
+	}
+
+	for _, bad := range []string{
+		`{"steps":[{"type":"seam_carve"}]}`,
+		`{"steps":[{"type":"seam_carve","width":-1}]}`,
+		`{"steps":[{"type":"seam_carve","width":10,"protect":[{"from_x":10,"to_x":5,"to_y":5}]}]}`,
+	} {
+		if _, err := ParsePipelineJSON([]byte(bad)); err == nil {
+			t.Fatalf("期望配置错误: %s", bad)
+		}
+	}
+}
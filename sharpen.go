@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	// DefaultSharpenSigma 默认的锐化模糊半径
+	DefaultSharpenSigma = 1.0
+	// sharpenBlurPasses 锐化时盒式模糊的次数，三次盒式模糊近似高斯模糊
+	sharpenBlurPasses = 3
+)
+
+// SharpenProcessor 锐化处理器，使用 USM (unsharp mask) 算法增强边缘
+// 将原图与高斯模糊后的图片之差按 Amount 叠加到原图上，透明度不变。
+type SharpenProcessor struct {
+	Amount    float64 // 锐化强度，例如 0.5 表示增强 50% 的边缘差异
+	Sigma     float64 // 高斯模糊的标准差，单位为像素，0 表示 DefaultSharpenSigma
+	Threshold uint8   // 差异不超过该值的通道不锐化，避免放大平坦区域的噪点
+}
+
+// NewSharpenProcessor 创建新的锐化处理器
+func NewSharpenProcessor(amount, sigma float64) *SharpenProcessor {
+	return &SharpenProcessor{
+		Amount: amount,
+		Sigma:  sigma,
+	}
+}
+
+// WithThreshold 设置锐化阈值
+func (p *SharpenProcessor) WithThreshold(threshold uint8) *SharpenProcessor {
+	p.Threshold = threshold
+	return p
+}
+
+// Process 实现Processor接口
+func (p *SharpenProcessor) Process(img image.Image) (image.Image, error) {
+	if _, _, err := p.PlanSize(0, 0); err != nil {
+		return nil, err
+	}
+
+	src := rgbaRegion(img, img.Bounds())
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	if p.Amount == 0 {
+		return dst, nil
+	}
+
+	blurred := image.NewRGBA(src.Rect)
+	copy(blurred.Pix, src.Pix)
+	radius := p.blurRadius()
+	for i := 0; i < sharpenBlurPasses; i++ {
+		boxBlur(blurred, radius)
+	}
+
+	threshold := int(p.Threshold)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		a := float64(src.Pix[i+3])
+		for c := 0; c < 3; c++ {
+			diff := int(src.Pix[i+c]) - int(blurred.Pix[i+c])
+			if diff <= threshold && diff >= -threshold {
+				continue
+			}
+			// 预乘的颜色值不能超过透明度
+			v := float64(src.Pix[i+c]) + p.Amount*float64(diff)
+			dst.Pix[i+c] = uint8(math.Round(min(max(v, 0), a)))
+		}
+	}
+
+	return dst, nil
+}
+
+// blurRadius 三次盒式模糊近似标准差为 Sigma 的高斯模糊时的盒式半径
+func (p *SharpenProcessor) blurRadius() int {
+	sigma := p.Sigma
+	if sigma == 0 {
+		sigma = DefaultSharpenSigma
+	}
+	width := math.Sqrt(12*sigma*sigma/sharpenBlurPasses + 1)
+	return max(int(math.Round((width-1)/2)), 1)
+}
+
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *SharpenProcessor) PlanSize(width, height int) (int, int, error) {
+	if p.Amount < 0 || p.Sigma < 0 {
+		return 0, 0, fmt.Errorf("无效的锐化参数: amount=%v, sigma=%v", p.Amount, p.Sigma)
+	}
+	return width, height, nil
+}
@@ -0,0 +1,164 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// ProgressiveScaler 大比例缩小时先按 2 的幂次做盒式缩小，再使用 Scaler 缩放到目标尺寸
+// 盒式缩小只读取一遍源像素，缩小后的尺寸不小于目标尺寸，最后一次缩放的比例在 1 到 2 之间，
+// 比直接使用插值算法大比例缩小更快，也避免了混叠。Scaler 为空时使用 draw.CatmullRom。
+type ProgressiveScaler struct {
+	Scaler draw.Scaler
+}
+
+// NewProgressiveScaler 创建先盒式缩小再使用 scaler 缩放的缩放算法
+func NewProgressiveScaler(scaler draw.Scaler) ProgressiveScaler {
+	return ProgressiveScaler{Scaler: scaler}
+}
+
+// Scale 实现 draw.Scaler 接口
+func (s ProgressiveScaler) Scale(dst draw.Image, dr image.Rectangle, src image.Image, sr image.Rectangle, op draw.Op,
+	opts *draw.Options,
+) {
+	scaler := s.Scaler
+	if scaler == nil {
+		scaler = draw.CatmullRom
+	}
+
+	factor := shrinkFactor(sr.Dx(), sr.Dy(), dr.Dx(), dr.Dy())
+	if factor > 1 {
+		src = boxShrink(src, sr, factor)
+		sr = src.Bounds()
+	}
+	scaler.Scale(dst, dr, src, sr, op, opts)
+}
+
+// shrinkFactor 返回缩小后仍不小于目标尺寸的最大 2 的幂次
+func shrinkFactor(srcWidth, srcHeight, dstWidth, dstHeight int) int {
+	if dstWidth <= 0 || dstHeight <= 0 {
+		return 1
+	}
+	factor := 1
+	for srcWidth/(factor*2) >= dstWidth && srcHeight/(factor*2) >= dstHeight {
+		factor *= 2
+	}
+	return factor
+}
+
+// boxShrink 将 src 中的 sr 区域按 factor 缩小，每个输出像素为 factor x factor 个源像素的平均值
+// 边缘不足 factor 的部分只对实际存在的像素求平均。YCbCr 和 Gray 图像直接读取像素平面，不转换整张图片。
+func boxShrink(src image.Image, sr image.Rectangle, factor int) *image.RGBA {
+	dw := (sr.Dx() + factor - 1) / factor
+	dh := (sr.Dy() + factor - 1) / factor
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	switch img := src.(type) {
+	case *image.YCbCr:
+		shrinkYCbCr(dst, img, sr, factor)
+	case *image.Gray:
+		shrinkGray(dst, img, sr, factor)
+	default:
+		shrinkRGBA(dst, rgbaRegion(src, sr), factor)
+	}
+	return dst
+}
+
+// shrinkRGBA 缩小原点为 (0,0) 的 RGBA 图像
+func shrinkRGBA(dst, src *image.RGBA, factor int) {
+	size := src.Rect.Size()
+	sums := make([]uint32, dst.Rect.Dx()*4)
+	for dy := 0; dy < dst.Rect.Dy(); dy++ {
+		clear(sums)
+		y0, y1 := dy*factor, min((dy+1)*factor, size.Y)
+		for y := y0; y < y1; y++ {
+			row := src.Pix[y*src.Stride : y*src.Stride+size.X*4]
+			for x := 0; x < size.X; x++ {
+				s := sums[x/factor*4:]
+				p := row[x*4:]
+				s[0] += uint32(p[0])
+				s[1] += uint32(p[1])
+				s[2] += uint32(p[2])
+				s[3] += uint32(p[3])
+			}
+		}
+
+		out := dst.Pix[dy*dst.Stride:]
+		for dx := 0; dx < dst.Rect.Dx(); dx++ {
+			n := uint32((min((dx+1)*factor, size.X) - dx*factor) * (y1 - y0))
+			for c := 0; c < 4; c++ {
+				out[dx*4+c] = uint8((sums[dx*4+c] + n/2) / n)
+			}
+		}
+	}
+}
+
+// shrinkGray 缩小灰度图像
+func shrinkGray(dst *image.RGBA, src *image.Gray, sr image.Rectangle, factor int) {
+	sums := make([]uint32, dst.Rect.Dx())
+	for dy := 0; dy < dst.Rect.Dy(); dy++ {
+		clear(sums)
+		y0, y1 := sr.Min.Y+dy*factor, min(sr.Min.Y+(dy+1)*factor, sr.Max.Y)
+		for y := y0; y < y1; y++ {
+			row := src.Pix[src.PixOffset(sr.Min.X, y):]
+			for x := 0; x < sr.Dx(); x++ {
+				sums[x/factor] += uint32(row[x])
+			}
+		}
+
+		out := dst.Pix[dy*dst.Stride:]
+		for dx := range sums {
+			n := uint32((min((dx+1)*factor, sr.Dx()) - dx*factor) * (y1 - y0))
+			v := uint8((sums[dx] + n/2) / n)
+			out[dx*4], out[dx*4+1], out[dx*4+2], out[dx*4+3] = v, v, v, 0xff
+		}
+	}
+}
+
+// shrinkYCbCr 缩小 YCbCr 图像，先对 Y、Cb、Cr 分别求平均再转换为 RGB
+// YCbCr 到 RGB 的转换在不溢出时是线性的，与先转换再平均的结果几乎相同。
+func shrinkYCbCr(dst *image.RGBA, src *image.YCbCr, sr image.Rectangle, factor int) {
+	w := dst.Rect.Dx()
+	sums := make([]uint32, w*3)
+	for dy := 0; dy < dst.Rect.Dy(); dy++ {
+		clear(sums)
+		y0, y1 := sr.Min.Y+dy*factor, min(sr.Min.Y+(dy+1)*factor, sr.Max.Y)
+		for y := y0; y < y1; y++ {
+			yi := src.YOffset(sr.Min.X, y)
+			for x := 0; x < sr.Dx(); x++ {
+				ci := src.COffset(sr.Min.X+x, y)
+				s := sums[x/factor*3:]
+				s[0] += uint32(src.Y[yi+x])
+				s[1] += uint32(src.Cb[ci])
+				s[2] += uint32(src.Cr[ci])
+			}
+		}
+
+		out := dst.Pix[dy*dst.Stride:]
+		for dx := 0; dx < w; dx++ {
+			n := uint32((min((dx+1)*factor, sr.Dx()) - dx*factor) * (y1 - y0))
+			s := sums[dx*3:]
+			r, g, b := color.YCbCrToRGB(uint8((s[0]+n/2)/n), uint8((s[1]+n/2)/n), uint8((s[2]+n/2)/n))
+			out[dx*4], out[dx*4+1], out[dx*4+2], out[dx*4+3] = r, g, b, 0xff
+		}
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 签名校验错误
+var (
+	ErrSignatureMissing   = errors.New("缺少签名")
+	ErrSignatureInvalid   = errors.New("签名无效")
+	ErrSignatureExpired   = errors.New("签名已过期")
+	ErrSigningKeyNotFound = errors.New("未知的签名密钥")
+)
+
+// urlSignaturePrefix 签名参数名称
+const urlSignaturePrefix = "s:"
+
+// SigningKey 签名密钥
+type SigningKey struct {
+	ID     string // 密钥标识，写入签名中用于选择校验密钥，不能包含 ':' 和 '/'
+	Secret []byte // HMAC密钥
+}
+
+// URLSigner 处理参数签名器
+//
+// 签名作为URL路径的第一个片段，格式为 s:<密钥标识>:<过期时间>:<签名>，例如:
+//
+//	/s:k1:1735689600:3q2-7w.../rs:width:300/photo.jpg
+//
+// 过期时间为Unix时间戳（秒），0 表示永不过期。
+// 签名内容为密钥标识、过期时间以及签名片段之后的完整路径（包括处理参数和源图片路径）。
+// 轮换密钥时，将新密钥放在第一位用于签名，旧密钥保留在后面继续用于校验。
+type URLSigner struct {
+	keys []SigningKey
+}
+
+// NewURLSigner 创建签名器，第一个密钥用于签名，所有密钥都可用于校验
+func NewURLSigner(keys ...SigningKey) (*URLSigner, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("至少需要一个签名密钥")
+	}
+
+	for _, key := range keys {
+		if key.ID == "" || strings.ContainsAny(key.ID, ":/") {
+			return nil, fmt.Errorf("无效的密钥标识: %q", key.ID)
+		}
+		if len(key.Secret) == 0 {
+			return nil, fmt.Errorf("密钥 %s 为空", key.ID)
+		}
+	}
+
+	return &URLSigner{keys: keys}, nil
+}
+
+// Sign 对路径签名，返回带签名片段的路径
+// path: 已转义的URL路径，例如 /rs:width:300/photo.jpg
+// expires: 过期时间，零值表示永不过期
+func (s *URLSigner) Sign(path string, expires time.Time) string {
+	key := s.keys[0]
+	path = "/" + strings.TrimPrefix(path, "/")
+
+	var exp int64
+	if !expires.IsZero() {
+		exp = expires.Unix()
+	}
+
+	sig := signURL(key.Secret, key.ID, exp, path)
+	return "/" + urlSignaturePrefix + key.ID + ":" + strconv.FormatInt(exp, 10) + ":" + sig + path
+}
+
+// Verify 校验带签名的路径，返回去掉签名片段后的路径
+func (s *URLSigner) Verify(signedPath string, now time.Time) (string, error) {
+	seg, path, _ := strings.Cut(strings.TrimPrefix(signedPath, "/"), "/")
+	if !strings.HasPrefix(seg, urlSignaturePrefix) {
+		return "", ErrSignatureMissing
+	}
+	path = "/" + path
+
+	parts := strings.Split(strings.TrimPrefix(seg, urlSignaturePrefix), ":")
+	if len(parts) != 3 {
+		return "", ErrSignatureInvalid
+	}
+	keyID, expStr, sig := parts[0], parts[1], parts[2]
+
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || exp < 0 {
+		return "", ErrSignatureInvalid
+	}
+
+	var secret []byte
+	for _, key := range s.keys {
+		if key.ID == keyID {
+			secret = key.Secret
+			break
+		}
+	}
+	if secret == nil {
+		return "", ErrSigningKeyNotFound
+	}
+
+	expected := signURL(secret, keyID, exp, path)
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return "", ErrSignatureInvalid
+	}
+
+	// 签名正确后再检查过期时间，避免泄露密钥信息
+	if exp > 0 && now.Unix() > exp {
+		return "", ErrSignatureExpired
+	}
+
+	return path, nil
+}
+
+// signURL 计算签名
+func signURL(secret []byte, keyID string, exp int64, path string) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(keyID + ":" + strconv.FormatInt(exp, 10) + ":" + path))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
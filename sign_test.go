@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer, err := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := signer.Sign("/rs:width:300/photo.jpg", time.Time{})
+	if !strings.HasPrefix(signed, "/s:k1:0:") || !strings.HasSuffix(signed, "/rs:width:300/photo.jpg") {
+		t.Fatalf("签名路径格式错误: %s", signed)
+	}
+
+	path, err := signer.Verify(signed, time.Now())
+	if err != nil {
+		t.Fatalf("校验失败: %v", err)
+	}
+	if path != "/rs:width:300/photo.jpg" {
+		t.Fatalf("校验返回路径错误: %s", path)
+	}
+}
+
+func TestURLSignerTampered(t *testing.T) {
+	signer, _ := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret-1")})
+	signed := signer.Sign("/rs:width:300/photo.jpg", time.Time{})
+
+	testCases := []struct {
+		name string
+		path string
+		err  error
+	}{
+		{"修改尺寸", strings.Replace(signed, "300", "9000", 1), ErrSignatureInvalid},
+		{"修改源图片", strings.Replace(signed, "photo.jpg", "other.jpg", 1), ErrSignatureInvalid},
+		{"修改过期时间", strings.Replace(signed, ":0:", ":99999999999:", 1), ErrSignatureInvalid},
+		{"未知密钥", strings.Replace(signed, "s:k1:", "s:k9:", 1), ErrSigningKeyNotFound},
+		{"缺少签名", "/rs:width:300/photo.jpg", ErrSignatureMissing},
+		{"签名格式错误", "/s:k1/rs:width:300/photo.jpg", ErrSignatureInvalid},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := signer.Verify(tc.path, time.Now()); !errors.Is(err, tc.err) {
+				t.Fatalf("期望错误 %v，实际 %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestURLSignerExpiry(t *testing.T) {
+	signer, _ := NewURLSigner(SigningKey{ID: "k1", Secret: []byte("secret-1")})
+	now := time.Unix(1700000000, 0)
+	signed := signer.Sign("rs:max:100/photo.jpg", now.Add(time.Hour))
+
+	if _, err := signer.Verify(signed, now); err != nil {
+		t.Fatalf("未过期的签名校验失败: %v", err)
+	}
+	if _, err := signer.Verify(signed, now.Add(2*time.Hour)); !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("期望签名过期，实际 %v", err)
+	}
+}
+
+func TestURLSignerKeyRotation(t *testing.T) {
+	oldSigner, _ := NewURLSigner(SigningKey{ID: "old", Secret: []byte("old-secret")})
+	signedOld := oldSigner.Sign("/rs:width:300/photo.jpg", time.Time{})
+
+	// 新密钥用于签名，旧密钥继续用于校验
+	signer, _ := NewURLSigner(
+		SigningKey{ID: "new", Secret: []byte("new-secret")},
+		SigningKey{ID: "old", Secret: []byte("old-secret")},
+	)
+
+	if _, err := signer.Verify(signedOld, time.Now()); err != nil {
+		t.Fatalf("旧密钥签名校验失败: %v", err)
+	}
+
+	signedNew := signer.Sign("/rs:width:300/photo.jpg", time.Time{})
+	if !strings.HasPrefix(signedNew, "/s:new:") {
+		t.Fatalf("应使用新密钥签名: %s", signedNew)
+	}
+	if _, err := oldSigner.Verify(signedNew, time.Now()); !errors.Is(err, ErrSigningKeyNotFound) {
+		t.Fatalf("期望未知密钥错误，实际 %v", err)
+	}
+}
+
+func TestNewURLSignerInvalid(t *testing.T) {
+	if _, err := NewURLSigner(); err == nil {
+		t.Fatal("没有密钥应返回错误")
+	}
+	if _, err := NewURLSigner(SigningKey{ID: "a:b", Secret: []byte("x")}); err == nil {
+		t.Fatal("密钥标识包含 ':' 应返回错误")
+	}
+	if _, err := NewURLSigner(SigningKey{ID: "a"}); err == nil {
+		t.Fatal("空密钥应返回错误")
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// smartCropAnalysisSize 分析时先将图片缩小到最大边不超过该值
+	smartCropAnalysisSize = 256
+
+	// 各项特征的权重，每项特征的取值范围为 0-1
+	smartCropEdgeWeight       = 1.0 // 亮度梯度，主体的轮廓和纹理
+	smartCropSaturationWeight = 0.5 // 色度，主体的颜色通常比背景鲜艳
+	smartCropSkinWeight       = 1.5 // 肤色，避免切掉人物
+)
+
+// SmartCrop 根据图片内容选择 width x height 的切割区域，坐标与 img.Bounds() 一致
+// 在缩小后的图片上按亮度梯度、色度和肤色为每个像素打分，选择得分之和最高的区域，
+// 得分相同时选择最靠近中心的区域。返回的区域可以保存下来，之后使用 NewCutProcessorWithRegion 切割。
+func SmartCrop(img image.Image, width, height int) (image.Rectangle, error) {
+	bounds := img.Bounds()
+	if width <= 0 || height <= 0 || width > bounds.Dx() || height > bounds.Dy() {
+		return image.Rectangle{}, fmt.Errorf("无效的切割尺寸(%dx%d)，原始尺寸(%dx%d)",
+			width, height, bounds.Dx(), bounds.Dy())
+	}
+	x, y := smartCropOffset(img, width, height)
+	return image.Rect(bounds.Min.X+x, bounds.Min.Y+y, bounds.Min.X+x+width, bounds.Min.Y+y+height), nil
+}
+
+// smartCropOffset 返回得分最高的区域相对于 img.Bounds().Min 的左上角坐标，尺寸已验证
+func smartCropOffset(img image.Image, width, height int) (int, int) {
+	bounds := img.Bounds()
+	origWidth, origHeight := bounds.Dx(), bounds.Dy()
+	if width == origWidth && height == origHeight {
+		return 0, 0
+	}
+
+	analysis := smartCropAnalysisImage(img)
+	aw, ah := analysis.Rect.Dx(), analysis.Rect.Dy()
+	sums := integralScores(analysis)
+
+	// 分析图片中的区域尺寸
+	cw := min(max(int(math.Round(float64(width)*float64(aw)/float64(origWidth))), 1), aw)
+	ch := min(max(int(math.Round(float64(height)*float64(ah)/float64(origHeight))), 1), ah)
+
+	bestX, bestY := (aw-cw)/2, (ah-ch)/2
+	bestScore := windowScore(sums, aw, bestX, bestY, cw, ch)
+	bestDistance := 0
+	for y := 0; y <= ah-ch; y++ {
+		for x := 0; x <= aw-cw; x++ {
+			score := windowScore(sums, aw, x, y, cw, ch)
+			dx, dy := 2*x-(aw-cw), 2*y-(ah-ch)
+			distance := max(dx, -dx) + max(dy, -dy)
+			if score > bestScore+1e-9 || (score > bestScore-1e-9 && distance < bestDistance) {
+				bestX, bestY, bestScore, bestDistance = x, y, score, distance
+			}
+		}
+	}
+
+	// 映射回原图坐标，只有一个候选位置的方向居中
+	return smartCropMap(bestX, aw, cw, origWidth, width), smartCropMap(bestY, ah, ch, origHeight, height)
+}
+
+// smartCropMap 将分析图片中的坐标映射为原图坐标
+func smartCropMap(pos, analysisSize, windowSize, origSize, size int) int {
+	if analysisSize == windowSize {
+		return (origSize - size) / 2
+	}
+	v := int(math.Round(float64(pos) * float64(origSize) / float64(analysisSize)))
+	return min(max(v, 0), origSize-size)
+}
+
+// smartCropAnalysisImage 返回最大边不超过 smartCropAnalysisSize 的分析图片
+func smartCropAnalysisImage(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if max(w, h) <= smartCropAnalysisSize {
+		return rgbaRegion(img, bounds)
+	}
+
+	scale := float64(smartCropAnalysisSize) / float64(max(w, h))
+	aw := max(int(math.Round(float64(w)*scale)), 1)
+	ah := max(int(math.Round(float64(h)*scale)), 1)
+	analysis := image.NewRGBA(image.Rect(0, 0, aw, ah))
+	NewProgressiveScaler(AreaAverage).Scale(analysis, analysis.Rect, img, bounds, draw.Src, nil)
+	return analysis
+}
+
+// integralScores 计算每个像素的得分并返回积分图，积分图的宽高比图片大 1
+func integralScores(img *image.RGBA) []float64 {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	luma := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			p := img.Pix[y*img.Stride+x*4:]
+			luma[y*w+x] = 0.299*float64(p[0]) + 0.587*float64(p[1]) + 0.114*float64(p[2])
+		}
+	}
+
+	sums := make([]float64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		row := 0.0
+		for x := 0; x < w; x++ {
+			p := img.Pix[y*img.Stride+x*4:]
+			a := float64(p[3]) / 255
+			score := 0.0
+			if a > 0 {
+				score = smartCropEdgeWeight*edgeScore(luma, w, h, x, y) +
+					smartCropSaturationWeight*chromaScore(p, a) +
+					smartCropSkinWeight*skinScore(p, a)
+			}
+			// 透明像素按不透明度降低得分
+			row += score * a
+			sums[(y+1)*(w+1)+x+1] = sums[y*(w+1)+x+1] + row
+		}
+	}
+	return sums
+}
+
+// windowScore 返回区域内的得分之和
+func windowScore(sums []float64, w, x, y, cw, ch int) float64 {
+	stride := w + 1
+	return sums[(y+ch)*stride+x+cw] - sums[y*stride+x+cw] - sums[(y+ch)*stride+x] + sums[y*stride+x]
+}
+
+// edgeScore 亮度的中心差分梯度，边缘处使用单侧差分
+func edgeScore(luma []float64, w, h, x, y int) float64 {
+	gx := luma[y*w+min(x+1, w-1)] - luma[y*w+max(x-1, 0)]
+	gy := luma[min(y+1, h-1)*w+x] - luma[max(y-1, 0)*w+x]
+	return min((math.Abs(gx)+math.Abs(gy))/255, 1)
+}
+
+// chromaScore 去除预乘后的色度，即 RGB 最大值与最小值之差
+func chromaScore(p []uint8, a float64) float64 {
+	hi := max(p[0], p[1], p[2])
+	lo := min(p[0], p[1], p[2])
+	return min(float64(hi-lo)/255/a, 1)
+}
+
+// skinScore 肤色得分，去除预乘后的颜色在 YCbCr 空间的常见肤色范围内时为 1
+func skinScore(p []uint8, a float64) float64 {
+	r := min(float64(p[0])/a, 255)
+	g := min(float64(p[1])/a, 255)
+	b := min(float64(p[2])/a, 255)
+	y := 0.299*r + 0.587*g + 0.114*b
+	cb := 128 - 0.168736*r - 0.331264*g + 0.5*b
+	cr := 128 + 0.5*r - 0.418688*g - 0.081312*b
+	if y > 40 && cb >= 77 && cb <= 127 && cr >= 133 && cr <= 173 {
+		return 1
+	}
+	return 0
+}
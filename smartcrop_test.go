@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+// subjectImage 灰色背景上在 subject 区域绘制指定颜色的主体
+func subjectImage(w, h int, subject image.Rectangle, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.RGBA{R: 128, G: 128, B: 128, A: 255}), image.Point{}, draw.Src)
+	draw.Draw(img, subject, image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+// countColor 统计指定颜色的像素数
+func countColor(img image.Image, c color.RGBA) int {
+	count := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if color.RGBAModel.Convert(img.At(x, y)).(color.RGBA) == c {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func TestSmartCrop(t *testing.T) {
+	skin := color.RGBA{R: 224, G: 172, B: 140, A: 255}
+	tests := []struct {
+		name    string
+		img     image.Image
+		width   int
+		height  int
+		subject image.Rectangle
+	}{
+		{"右侧彩色主体", subjectImage(400, 200, image.Rect(320, 60, 380, 120), testRed), 200, 200, image.Rect(320, 60, 380, 120)},
+		{"左侧肤色主体", subjectImage(400, 200, image.Rect(10, 40, 70, 100), skin), 150, 200, image.Rect(10, 40, 70, 100)},
+		{"大图底部主体", subjectImage(1200, 1600, image.Rect(500, 1300, 700, 1500), testBlue), 1200, 600, image.Rect(500, 1300, 700, 1500)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			region, err := SmartCrop(tc.img, tc.width, tc.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if region.Dx() != tc.width || region.Dy() != tc.height || !tc.subject.In(region) {
+				t.Fatalf("切割区域 %v 未包含主体 %v", region, tc.subject)
+			}
+		})
+	}
+
+	// 没有明显主体时居中
+	plain := subjectImage(400, 200, image.Rectangle{}, nil)
+	if region, _ := SmartCrop(plain, 200, 200); region != image.Rect(100, 0, 300, 200) {
+		t.Fatalf("期望居中，实际 %v", region)
+	}
+
+	// 区域坐标与图片坐标一致
+	sub := subjectImage(400, 200, image.Rect(320, 60, 380, 120), testRed).SubImage(image.Rect(100, 0, 400, 200))
+	if region, _ := SmartCrop(sub, 100, 100); !image.Rect(320, 60, 380, 120).In(region) {
+		t.Fatalf("子图切割区域错误: %v", region)
+	}
+
+	if _, err := SmartCrop(plain, 500, 100); err == nil {
+		t.Fatal("期望切割尺寸错误")
+	}
+}
+
+func TestSmartCutProcessor(t *testing.T) {
+	img := subjectImage(400, 200, image.Rect(320, 60, 380, 120), testRed)
+
+	cut := NewSmartCutProcessor(200, 200)
+	region, err := cut.Region(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cut.Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Bounds() != region || !image.Rect(320, 60, 380, 120).In(region) {
+		t.Fatalf("切割结果 %v 与区域 %v 不一致", out.Bounds(), region)
+	}
+
+	// 保存的区域可以重现切割结果
+	again, _ := NewCutProcessorWithRegion(region.Dx(), region.Dy(), region.Min.X, region.Min.Y).Process(img)
+	if again.Bounds() != region {
+		t.Fatalf("期望相同的切割区域，实际 %v", again.Bounds())
+	}
+
+	// 按宽高比切割最大区域
+	aspect := NewCutAspectProcessor(1.5, CutPositionSmart)
+	if w, h, err := aspect.PlanSize(400, 200); err != nil || w != 300 || h != 200 {
+		t.Fatalf("宽高比切割尺寸期望 300x200，实际 %dx%d, %v", w, h, err)
+	}
+	if w, h, _ := aspect.PlanSize(300, 600); w != 300 || h != 200 {
+		t.Fatalf("宽高比切割尺寸期望 300x200，实际 %dx%d", w, h)
+	}
+	for _, invalid := range []float64{-1, 1e12, 1e-12, math.NaN(), math.Inf(1)} {
+		if _, _, err := NewCutAspectProcessor(invalid, "").PlanSize(400, 200); err == nil {
+			t.Fatalf("期望无效宽高比 %v 错误", invalid)
+		}
+	}
+	if _, err := ParseURLOptions("ca:NaN"); err == nil {
+		t.Fatal("期望URL参数中的无效宽高比错误")
+	}
+
+	// cover 缩放按内容裁剪
+	zoomed, err := NewZoomCoverProcessor(100, 100, CutPositionSmart).Process(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.RGBAModel.Convert(zoomed.At(80, 45)).(color.RGBA); c != testRed {
+		t.Fatalf("期望裁剪后保留红色主体，实际 %v", c)
+	}
+}
+
+func TestPipelineSpecSmartCut(t *testing.T) {
+	spec, err := ParsePipelineJSON([]byte(`{"steps":[{"type":"cut","aspect":1.5,"position":"smart"}]}`))
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	processors, err := spec.Processors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cut := processors[0].(*CutProcessor); cut.Aspect != 1.5 || cut.Position != CutPositionSmart {
+		t.Fatalf("切割处理器错误: %+v", cut)
+	}
+
+	if _, err := ParsePipelineJSON([]byte(`{"steps":[{"type":"cut","aspect":-1}]}`)); err == nil {
+		t.Fatal("期望无效宽高比错误")
+	}
+}
@@ -0,0 +1,933 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/draw"
+	"gopkg.in/yaml.v3"
+)
+
+// 声明式处理器步骤类型
+const (
+	StepZoom          = "zoom"
+	StepCut           = "cut"
+	StepCircle        = "circle"
+	StepRotate        = "rotate"
+	StepRoundedCorner = "rounded_corner"
+	StepMosaic        = "mosaic"
+	StepWatermark     = "watermark"
+	StepOverlay       = "overlay"
+	StepNoise         = "noise"
+	StepText          = "text"
+	StepDrawCircle    = "draw_circle"
+	StepDrawRect      = "draw_rect"
+	StepEmpty         = "empty"
+	StepSharpen       = "sharpen"
+	StepSeamCarve     = "seam_carve"
+)
+
+// PipelineSpec 声明式处理器链配置，可由JSON或YAML描述
+//
+// 示例(YAML):
+//
+//	steps:
+//	  - type: cut
+//	    square: true
+//	    position: center
+//	  - type: zoom
+//	    mode: width
+//	    width: 300
+//	  - type: watermark
+//	    text: ACME
+//	    position: bottom-right
+type PipelineSpec struct {
+	Steps []StepSpec `json:"steps" yaml:"steps"`
+}
+
+// StepSpec 处理器链中的单个步骤
+// Type 决定使用哪个处理器，其余字段按处理器类型取用，未用到的字段会被忽略
+type StepSpec struct {
+	// 处理器类型: zoom, cut, circle, rotate, rounded_corner, mosaic,
+	// watermark, overlay, noise, text, draw_circle, draw_rect, empty, sharpen, seam_carve，
+	// 或通过 RegisterProcessor 注册的处理器名称
+	Type string `json:"type" yaml:"type"`
+
+	// 注册处理器的参数，内置处理器使用下面的字段
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+
+	// 尺寸 (zoom, cut, draw_rect, seam_carve)
+	Width  int `json:"width,omitempty" yaml:"width,omitempty"`
+	Height int `json:"height,omitempty" yaml:"height,omitempty"`
+	// 边长 (zoom 的 max/min 模式, cut 的正方形模式)
+	Size int `json:"size,omitempty" yaml:"size,omitempty"`
+	// 缩放比例 (zoom 的 ratio 模式)
+	Ratio float64 `json:"ratio,omitempty" yaml:"ratio,omitempty"`
+	// 缩放模式 (zoom): exact, ratio, width, height, max, min, cover, contain, pad，默认为 exact
+	Mode string `json:"mode,omitempty" yaml:"mode,omitempty"`
+	// 不放大图片 (zoom)
+	NoUpscale bool `json:"no_upscale,omitempty" yaml:"no_upscale,omitempty"`
+	// 使用模糊后的原图填充 (zoom 的 pad 模式)
+	Blur bool `json:"blur,omitempty" yaml:"blur,omitempty"`
+	// 缩放算法 (zoom): nearest, approx-bilinear, bilinear, catmull-rom, lanczos2, lanczos3, mitchell, box, area，
+	// 加 linear- 前缀表示在线性光空间中缩放，例如 linear-lanczos3，
+	// 加 fast- 前缀表示大比例缩小时先做盒式缩小，例如 fast-lanczos3、fast-linear-lanczos3
+	Scaler string `json:"scaler,omitempty" yaml:"scaler,omitempty"`
+
+	// 锐化强度 (sharpen, zoom 缩小后锐化)，0 表示不锐化
+	Amount float64 `json:"amount,omitempty" yaml:"amount,omitempty"`
+	// 锐化模糊的标准差 (sharpen, zoom)，0 表示默认值
+	Sigma float64 `json:"sigma,omitempty" yaml:"sigma,omitempty"`
+	// 锐化阈值 (sharpen, zoom)，0-255
+	Threshold int `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+
+	// 预设位置 (zoom 的 cover 和 pad 模式, cut, overlay, watermark)，cut 和 zoom 的 cover 模式支持 smart
+	Position string `json:"position,omitempty" yaml:"position,omitempty"`
+	// 坐标 (cut 自定义区域, overlay, text, draw_circle, draw_rect)
+	X int `json:"x,omitempty" yaml:"x,omitempty"`
+	Y int `json:"y,omitempty" yaml:"y,omitempty"`
+	// 是否使用自定义区域 (cut)
+	CustomRegion bool `json:"custom_region,omitempty" yaml:"custom_region,omitempty"`
+	// 是否为正方形模式 (cut)
+	Square bool `json:"square,omitempty" yaml:"square,omitempty"`
+	// 宽高比 (cut)，未指定宽度和高度时切割该比例的最大区域，例如 1.5 表示 3:2
+	Aspect float64 `json:"aspect,omitempty" yaml:"aspect,omitempty"`
+
+	// 旋转角度 (rotate, text)
+	Angle float64 `json:"angle,omitempty" yaml:"angle,omitempty"`
+	// 背景颜色 (rotate, zoom 的 pad 模式)
+	Background string `json:"background,omitempty" yaml:"background,omitempty"`
+	// 是否保持原始尺寸 (rotate)
+	KeepSize bool `json:"keep_size,omitempty" yaml:"keep_size,omitempty"`
+
+	// 半径 (rounded_corner, draw_circle)
+	Radius int `json:"radius,omitempty" yaml:"radius,omitempty"`
+
+	// 马赛克区域 (mosaic)
+	Regions []*MosaicRegion `json:"regions,omitempty" yaml:"regions,omitempty"`
+	// 马赛克区域百分比 (mosaic)，0 表示整个区域
+	Percent float32 `json:"percent,omitempty" yaml:"percent,omitempty"`
+	// 马赛克开始方向 (mosaic): left, right, top, bottom
+	Direction string `json:"direction,omitempty" yaml:"direction,omitempty"`
+
+	// 保护区域和删除区域 (seam_carve)，格式与马赛克区域相同
+	Protect []*MosaicRegion `json:"protect,omitempty" yaml:"protect,omitempty"`
+	Remove  []*MosaicRegion `json:"remove,omitempty" yaml:"remove,omitempty"`
+
+	// 文本 (watermark, text)
+	Text string `json:"text,omitempty" yaml:"text,omitempty"`
+	// 字体大小 (watermark, text)，需要通过 SetDefaultFont 设置默认字体
+	FontSize float64 `json:"font_size,omitempty" yaml:"font_size,omitempty"`
+	// 颜色，格式为 #RRGGBB 或 #RRGGBBAA (watermark, text, draw_circle, draw_rect)
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
+	// 不透明度 (watermark, overlay)，0 表示使用默认值
+	Opacity float64 `json:"opacity,omitempty" yaml:"opacity,omitempty"`
+	// 水印旋转角度 (watermark)
+	Rotation float64 `json:"rotation,omitempty" yaml:"rotation,omitempty"`
+
+	// 最大文本宽度 (text)
+	MaxWidth float64 `json:"max_width,omitempty" yaml:"max_width,omitempty"`
+	// 行距倍数 (text)
+	LineSpacing float64 `json:"line_spacing,omitempty" yaml:"line_spacing,omitempty"`
+	// 对齐方式 (text): left, center, right
+	Align string `json:"align,omitempty" yaml:"align,omitempty"`
+	// 按字符换行 (text)
+	CharWrap bool `json:"char_wrap,omitempty" yaml:"char_wrap,omitempty"`
+
+	// 叠加图像文件路径 (overlay)
+	Image string `json:"image,omitempty" yaml:"image,omitempty"`
+	// 叠加图像缩放比例 (overlay)
+	Scale float64 `json:"scale,omitempty" yaml:"scale,omitempty"`
+
+	// 是否填充 (draw_circle, draw_rect)
+	Fill bool `json:"fill,omitempty" yaml:"fill,omitempty"`
+	// 填充颜色 (draw_rect)，为空时使用 Color
+	FillColor string `json:"fill_color,omitempty" yaml:"fill_color,omitempty"`
+
+	// 干扰线和干扰点数量 (noise)
+	Lines int `json:"lines,omitempty" yaml:"lines,omitempty"`
+	Dots  int `json:"dots,omitempty" yaml:"dots,omitempty"`
+	// 干扰线和干扰点颜色 (noise)
+	LineColor string `json:"line_color,omitempty" yaml:"line_color,omitempty"`
+	DotColor  string `json:"dot_color,omitempty" yaml:"dot_color,omitempty"`
+}
+
+// SpecError 处理器链配置错误，包含出错步骤的序号和字段
+type SpecError struct {
+	Index int    // 步骤序号，从0开始
+	Field string // 出错字段
+	Msg   string // 错误描述
+}
+
+func (e *SpecError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("steps[%d]: %s", e.Index, e.Msg)
+	}
+	return fmt.Sprintf("steps[%d].%s: %s", e.Index, e.Field, e.Msg)
+}
+
+func specErr(index int, field, format string, args ...any) *SpecError {
+	return &SpecError{Index: index, Field: field, Msg: fmt.Sprintf(format, args...)}
+}
+
+// checkFinite 检查数值不是 NaN 或无穷大，YAML 可以表示 .nan 和 .inf
+func checkFinite(index int, field string, v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return specErr(index, field, "无效的数值: %v", v)
+	}
+	return nil
+}
+
+// sizeField 返回宽度和高度中无效的字段名，宽度有效时为 height
+func sizeField(width int) string {
+	if width <= 0 {
+		return "width"
+	}
+	return "height"
+}
+
+// ParsePipelineJSON 解析JSON格式的处理器链配置
+func ParsePipelineJSON(data []byte) (*PipelineSpec, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	spec := &PipelineSpec{}
+	if err := dec.Decode(spec); err != nil {
+		return nil, fmt.Errorf("解析JSON配置失败: %w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ParsePipelineYAML 解析YAML格式的处理器链配置
+func ParsePipelineYAML(data []byte) (*PipelineSpec, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	spec := &PipelineSpec{}
+	if err := dec.Decode(spec); err != nil {
+		return nil, fmt.Errorf("解析YAML配置失败: %w", err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ParsePipeline 解析处理器链配置，以 '{' 开头的内容按JSON解析，否则按YAML解析
+func ParsePipeline(data []byte) (*PipelineSpec, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return ParsePipelineJSON(trimmed)
+	}
+	return ParsePipelineYAML(trimmed)
+}
+
+// LoadPipelineFile 从文件加载处理器链配置，根据扩展名选择JSON或YAML格式
+func LoadPipelineFile(path string) (*PipelineSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParsePipelineJSON(data)
+	case ".yaml", ".yml":
+		return ParsePipelineYAML(data)
+	default:
+		return ParsePipeline(data)
+	}
+}
+
+// Validate 校验处理器链配置，返回第一个错误
+// 只校验参数，不访问文件，叠加图像在 Processors 构建处理器链时才加载一次。
+func (s *PipelineSpec) Validate() error {
+	for i := range s.Steps {
+		if err := s.Steps[i].validate(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Processors 构建处理器链
+func (s *PipelineSpec) Processors() ([]Processor, error) {
+	processors := make([]Processor, 0, len(s.Steps))
+	for i := range s.Steps {
+		p, err := s.Steps[i].build(i)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, p)
+	}
+	return processors, nil
+}
+
+// ContextProcessors 构建上下文处理器链，所有步骤都必须支持 ContextProcessor
+func (s *PipelineSpec) ContextProcessors() ([]ContextProcessor, error) {
+	processors := make([]ContextProcessor, 0, len(s.Steps))
+	for i := range s.Steps {
+		p, err := s.Steps[i].build(i)
+		if err != nil {
+			return nil, err
+		}
+		cp, ok := p.(ContextProcessor)
+		if !ok {
+			return nil, specErr(i, "type", "处理器 %s 不支持上下文处理", s.Steps[i].Type)
+		}
+		processors = append(processors, cp)
+	}
+	return processors, nil
+}
+
+// CompilePipeline 解析配置并直接构建处理器链
+func CompilePipeline(data []byte) ([]Processor, error) {
+	spec, err := ParsePipeline(data)
+	if err != nil {
+		return nil, err
+	}
+	return spec.Processors()
+}
+
+// validate 校验单个步骤，叠加图像只校验参数，不加载文件
+func (s *StepSpec) validate(index int) error {
+	if s.Type == StepOverlay {
+		return s.checkOverlay(index)
+	}
+	_, err := s.build(index)
+	return err
+}
+
+// build 校验并构建单个步骤对应的处理器
+func (s *StepSpec) build(index int) (Processor, error) {
+	if len(s.Params) > 0 && slices.Contains(builtinSteps, s.Type) {
+		return nil, specErr(index, "params", "内置处理器 %s 不使用 params", s.Type)
+	}
+
+	switch s.Type {
+	case StepZoom:
+		return s.buildZoom(index)
+	case StepCut:
+		return s.buildCut(index)
+	case StepCircle:
+		return NewCutCircleProcessor(), nil
+	case StepRotate:
+		return s.buildRotate(index)
+	case StepRoundedCorner:
+		if s.Radius <= 0 {
+			return nil, specErr(index, "radius", "圆角半径必须大于0")
+		}
+		return NewRoundedCornerProcessor(s.Radius), nil
+	case StepMosaic:
+		return s.buildMosaic(index)
+	case StepWatermark:
+		return s.buildWatermark(index)
+	case StepOverlay:
+		return s.buildOverlay(index)
+	case StepNoise:
+		return s.buildNoise(index)
+	case StepText:
+		return s.buildText(index)
+	case StepDrawCircle:
+		return s.buildDrawCircle(index)
+	case StepDrawRect:
+		return s.buildDrawRect(index)
+	case StepEmpty:
+		return &EmptyProcessor{}, nil
+	case StepSharpen:
+		if s.Amount <= 0 {
+			return nil, specErr(index, "amount", "锐化强度必须大于0")
+		}
+		return s.buildSharpen(index)
+	case StepSeamCarve:
+		return s.buildSeamCarve(index)
+	case "":
+		return nil, specErr(index, "type", "未指定处理器类型")
+	default:
+		return s.buildRegistered(index)
+	}
+}
+
+// buildRegistered 使用默认注册表构建处理器
+func (s *StepSpec) buildRegistered(index int) (Processor, error) {
+	factory, ok := DefaultRegistry.Lookup(s.Type)
+	if !ok {
+		return nil, specErr(index, "type", "未知的处理器类型: %s", s.Type)
+	}
+
+	p, err := factory(s.Params)
+	if err != nil {
+		return nil, specErr(index, "params", "%v", err)
+	}
+	return p, nil
+}
+
+func (s *StepSpec) buildZoom(index int) (Processor, error) {
+	mode, ok := zoomModeNames[s.Mode]
+	if !ok {
+		return nil, specErr(index, "mode", "未知的缩放模式: %s", s.Mode)
+	}
+
+	var p *ZoomProcessor
+	switch mode {
+	case ZoomModeExact:
+		if s.Width <= 0 || s.Height <= 0 {
+			return nil, specErr(index, sizeField(s.Width), "精确缩放需要指定宽度和高度: %dx%d", s.Width, s.Height)
+		}
+		p = NewZoomProcessor(s.Width, s.Height)
+	case ZoomModeRatio:
+		if err := checkFinite(index, "ratio", s.Ratio); err != nil {
+			return nil, err
+		}
+		if s.Ratio <= 0 {
+			return nil, specErr(index, "ratio", "缩放比例必须大于0")
+		}
+		p = NewZoomRatioProcessor(s.Ratio)
+	case ZoomModeWidth:
+		if s.Width <= 0 {
+			return nil, specErr(index, "width", "宽度必须大于0")
+		}
+		p = NewZoomWidthProcessor(s.Width)
+	case ZoomModeHeight:
+		if s.Height <= 0 {
+			return nil, specErr(index, "height", "高度必须大于0")
+		}
+		p = NewZoomHeightProcessor(s.Height)
+	case ZoomModeMax, ZoomModeMin:
+		if s.Size <= 0 {
+			return nil, specErr(index, "size", "边长必须大于0")
+		}
+		if mode == ZoomModeMax {
+			p = NewZoomMaxProcessor(s.Size)
+		} else {
+			p = NewZoomMinProcessor(s.Size)
+		}
+	case ZoomModeCover, ZoomModeContain, ZoomModePad:
+		if s.Width <= 0 || s.Height <= 0 {
+			return nil, specErr(index, sizeField(s.Width), "%s 模式需要指定宽度和高度: %dx%d", s.Mode, s.Width, s.Height)
+		}
+		if s.Position != "" && !isCutPosition(s.Position) {
+			return nil, specErr(index, "position", "未知的位置: %s", s.Position)
+		}
+		p = &ZoomProcessor{Width: s.Width, Height: s.Height, Mode: mode, Position: CutPosition(s.Position)}
+		if mode == ZoomModePad && s.Background != "" {
+			c, err := ParseHexColor(s.Background)
+			if err != nil {
+				return nil, specErr(index, "background", "%v", err)
+			}
+			p.WithBackground(c)
+		}
+		p.WithBlurBackground(mode == ZoomModePad && s.Blur)
+	}
+	p.WithNoUpscale(s.NoUpscale)
+
+	if s.Scaler != "" {
+		scaler, ok := parseScaler(s.Scaler)
+		if !ok {
+			return nil, specErr(index, "scaler", "未知的缩放算法: %s", s.Scaler)
+		}
+		p.WithScaler(scaler)
+	}
+
+	if s.Amount != 0 {
+		sharpen, err := s.buildSharpen(index)
+		if err != nil {
+			return nil, err
+		}
+		p.WithSharpen(sharpen)
+	}
+
+	return p, nil
+}
+
+// buildSharpen 构建锐化处理器，zoom 步骤的缩小后锐化使用相同的参数
+func (s *StepSpec) buildSharpen(index int) (*SharpenProcessor, error) {
+	if err := checkFinite(index, "amount", s.Amount); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "sigma", s.Sigma); err != nil {
+		return nil, err
+	}
+	if s.Amount < 0 {
+		return nil, specErr(index, "amount", "锐化强度不能为负数")
+	}
+	if s.Sigma < 0 {
+		return nil, specErr(index, "sigma", "锐化模糊的标准差不能为负数")
+	}
+	if s.Threshold < 0 || s.Threshold > 255 {
+		return nil, specErr(index, "threshold", "锐化阈值必须在0-255之间: %d", s.Threshold)
+	}
+	return NewSharpenProcessor(s.Amount, s.Sigma).WithThreshold(uint8(s.Threshold)), nil
+}
+
+func (s *StepSpec) buildCut(index int) (Processor, error) {
+	if s.Position != "" && !isCutPosition(s.Position) {
+		return nil, specErr(index, "position", "未知的切割位置: %s", s.Position)
+	}
+
+	if s.CustomRegion {
+		if s.X < 0 || s.Y < 0 {
+			return nil, specErr(index, "x", "切割起点不能为负数: (%d,%d)", s.X, s.Y)
+		}
+	}
+
+	if s.Square {
+		size := s.Size
+		if size == 0 {
+			size = max(s.Width, s.Height)
+		}
+		if size < 0 {
+			return nil, specErr(index, "size", "边长不能为负数")
+		}
+		if s.CustomRegion {
+			if size == 0 {
+				return nil, specErr(index, "size", "自定义区域需要指定边长")
+			}
+			return NewCutSquareProcessorWithRegion(size, s.X, s.Y), nil
+		}
+		if size == 0 {
+			return NewCutSquareProcessor(s.Position), nil
+		}
+		return NewCutSquareProcessorWithSize(size, s.Position), nil
+	}
+
+	if s.Aspect != 0 && !validCutAspect(s.Aspect) {
+		return nil, specErr(index, "aspect", "宽高比必须在 %v-%v 之间: %v", 1/maxCutAspect, maxCutAspect, s.Aspect)
+	}
+	if s.Aspect > 0 && s.Width == 0 && s.Height == 0 && !s.CustomRegion {
+		return NewCutAspectProcessor(s.Aspect, CutPosition(s.Position)), nil
+	}
+	if s.Width <= 0 || s.Height <= 0 {
+		return nil, specErr(index, sizeField(s.Width), "切割需要指定宽度和高度: %dx%d", s.Width, s.Height)
+	}
+	if s.CustomRegion {
+		return NewCutProcessorWithRegion(s.Width, s.Height, s.X, s.Y), nil
+	}
+	return NewCutProcessor(s.Width, s.Height, CutPosition(s.Position)), nil
+}
+
+func (s *StepSpec) buildRotate(index int) (Processor, error) {
+	if err := checkFinite(index, "angle", s.Angle); err != nil {
+		return nil, err
+	}
+	p := NewRotateProcessor(s.Angle).WithKeepSize(s.KeepSize)
+	if s.Background != "" {
+		c, err := ParseHexColor(s.Background)
+		if err != nil {
+			return nil, specErr(index, "background", "%v", err)
+		}
+		p.WithBackground(c)
+	}
+	return p, nil
+}
+
+func (s *StepSpec) buildMosaic(index int) (Processor, error) {
+	if len(s.Regions) == 0 {
+		return nil, specErr(index, "regions", "至少需要一个马赛克区域")
+	}
+	if err := checkRegions(index, "regions", s.Regions); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "percent", float64(s.Percent)); err != nil {
+		return nil, err
+	}
+	if s.Percent < 0 || s.Percent > 1 {
+		return nil, specErr(index, "percent", "马赛克百分比必须在0-1之间: %v", s.Percent)
+	}
+
+	direction := Direction(s.Direction)
+	switch direction {
+	case "":
+		direction = DirectionLeft
+	case DirectionLeft, DirectionRight, DirectionTop, DirectionBottom:
+	default:
+		return nil, specErr(index, "direction", "未知的马赛克方向: %s", s.Direction)
+	}
+
+	percent := s.Percent
+	if percent == 0 {
+		percent = 1
+	}
+
+	return NewMosaicProcessor(s.Regions, percent, direction), nil
+}
+
+// checkRegions 检查区域的右下角在左上角的右下方
+func checkRegions(index int, field string, regions []*MosaicRegion) error {
+	for i, r := range regions {
+		if r == nil || r.FromX >= r.ToX || r.FromY >= r.ToY {
+			return specErr(index, fmt.Sprintf("%s[%d]", field, i), "无效的区域")
+		}
+	}
+	return nil
+}
+
+func (s *StepSpec) buildSeamCarve(index int) (Processor, error) {
+	if s.Width < 0 {
+		return nil, specErr(index, "width", "接缝裁剪宽度不能为负数: %d", s.Width)
+	}
+	if s.Height < 0 {
+		return nil, specErr(index, "height", "接缝裁剪高度不能为负数: %d", s.Height)
+	}
+	if s.Width == 0 && s.Height == 0 && len(s.Remove) == 0 {
+		return nil, specErr(index, "width", "接缝裁剪需要指定宽度、高度或删除区域")
+	}
+	if err := checkRegions(index, "protect", s.Protect); err != nil {
+		return nil, err
+	}
+	if err := checkRegions(index, "remove", s.Remove); err != nil {
+		return nil, err
+	}
+	return NewSeamCarveProcessor(s.Width, s.Height).WithProtect(s.Protect...).WithRemove(s.Remove...), nil
+}
+
+func (s *StepSpec) buildWatermark(index int) (Processor, error) {
+	if s.Text == "" {
+		return nil, specErr(index, "text", "水印文本不能为空")
+	}
+	if err := checkFinite(index, "font_size", s.FontSize); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "opacity", s.Opacity); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "rotation", s.Rotation); err != nil {
+		return nil, err
+	}
+	if s.FontSize < 0 {
+		return nil, specErr(index, "font_size", "字体大小不能为负数")
+	}
+	if s.Opacity < 0 || s.Opacity > 1 {
+		return nil, specErr(index, "opacity", "不透明度必须在0-1之间: %v", s.Opacity)
+	}
+	if s.Position != "" && !isWatermarkPosition(s.Position) {
+		return nil, specErr(index, "position", "未知的水印位置: %s", s.Position)
+	}
+
+	c := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	if s.Color != "" {
+		var err error
+		if c, err = ParseHexColor(s.Color); err != nil {
+			return nil, specErr(index, "color", "%v", err)
+		}
+	}
+
+	fontSize := s.FontSize
+	if fontSize == 0 {
+		fontSize = 24
+	}
+	opacity := s.Opacity
+	if opacity == 0 {
+		opacity = 0.5
+	}
+
+	return NewWatermarkProcessor(s.Text, fontSize, c, opacity, s.Position, s.Rotation), nil
+}
+
+func (s *StepSpec) buildOverlay(index int) (Processor, error) {
+	if err := s.checkOverlay(index); err != nil {
+		return nil, err
+	}
+
+	overlayImg, err := loadImageFile(s.Image)
+	if err != nil {
+		return nil, specErr(index, "image", "加载叠加图像失败: %v", err)
+	}
+
+	opacity := s.Opacity
+	if opacity == 0 {
+		opacity = 1
+	}
+
+	if s.Position != "" {
+		return NewOverlayProcessorWithPosition(overlayImg, s.Position, opacity, s.Scale), nil
+	}
+	return NewOverlayProcessor(overlayImg, s.X, s.Y, opacity, s.Scale), nil
+}
+
+func (s *StepSpec) checkOverlay(index int) error {
+	if s.Image == "" {
+		return specErr(index, "image", "未指定叠加图像")
+	}
+	if err := checkFinite(index, "opacity", s.Opacity); err != nil {
+		return err
+	}
+	if s.Opacity < 0 || s.Opacity > 1 {
+		return specErr(index, "opacity", "不透明度必须在0-1之间: %v", s.Opacity)
+	}
+	if err := checkFinite(index, "scale", s.Scale); err != nil {
+		return err
+	}
+	if s.Scale < 0 {
+		return specErr(index, "scale", "缩放比例不能为负数")
+	}
+	if s.Position != "" && !isOverlayPosition(s.Position) {
+		return specErr(index, "position", "未知的叠加位置: %s", s.Position)
+	}
+	return nil
+}
+
+func (s *StepSpec) buildNoise(index int) (Processor, error) {
+	if s.Lines < 0 {
+		return nil, specErr(index, "lines", "干扰线数量不能为负数")
+	}
+	if s.Dots < 0 {
+		return nil, specErr(index, "dots", "干扰点数量不能为负数")
+	}
+
+	lineColor := color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	dotColor := lineColor
+	var err error
+	if s.LineColor != "" {
+		if lineColor, err = ParseHexColor(s.LineColor); err != nil {
+			return nil, specErr(index, "line_color", "%v", err)
+		}
+	}
+	if s.DotColor != "" {
+		if dotColor, err = ParseHexColor(s.DotColor); err != nil {
+			return nil, specErr(index, "dot_color", "%v", err)
+		}
+	}
+
+	return NewNoiseProcessor(s.Lines, s.Dots, lineColor, dotColor), nil
+}
+
+func (s *StepSpec) buildText(index int) (Processor, error) {
+	if s.Text == "" {
+		return nil, specErr(index, "text", "文本不能为空")
+	}
+	if s.FontSize < 0 {
+		return nil, specErr(index, "font_size", "字体大小不能为负数")
+	}
+	if s.MaxWidth < 0 {
+		return nil, specErr(index, "max_width", "最大文本宽度不能为负数")
+	}
+	if err := checkFinite(index, "font_size", s.FontSize); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "angle", s.Angle); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "max_width", s.MaxWidth); err != nil {
+		return nil, err
+	}
+	if err := checkFinite(index, "line_spacing", s.LineSpacing); err != nil {
+		return nil, err
+	}
+
+	opts := TextOptions{
+		Text:        s.Text,
+		Position:    image.Pt(s.X, s.Y),
+		Angle:       s.Angle,
+		MaxWidth:    s.MaxWidth,
+		LineSpacing: s.LineSpacing,
+		CharWrap:    s.CharWrap,
+	}
+
+	switch s.Align {
+	case "", "left":
+		opts.Align = gg.AlignLeft
+	case "center":
+		opts.Align = gg.AlignCenter
+	case "right":
+		opts.Align = gg.AlignRight
+	default:
+		return nil, specErr(index, "align", "未知的对齐方式: %s", s.Align)
+	}
+
+	if s.Color != "" {
+		c, err := ParseHexColor(s.Color)
+		if err != nil {
+			return nil, specErr(index, "color", "%v", err)
+		}
+		opts.Color = c
+	}
+
+	if s.FontSize > 0 && defaultFont != nil {
+		opts.Font = truetype.NewFace(defaultFont, &truetype.Options{Size: s.FontSize})
+	}
+
+	return NewTextProcessor(opts), nil
+}
+
+func (s *StepSpec) buildDrawCircle(index int) (Processor, error) {
+	if s.Radius <= 0 {
+		return nil, specErr(index, "radius", "半径必须大于0")
+	}
+	if s.Color == "" {
+		return nil, specErr(index, "color", "未指定颜色")
+	}
+	c, err := ParseHexColor(s.Color)
+	if err != nil {
+		return nil, specErr(index, "color", "%v", err)
+	}
+	return NewDrawCircleProcessor(s.X, s.Y, s.Radius, c, s.Fill), nil
+}
+
+func (s *StepSpec) buildDrawRect(index int) (Processor, error) {
+	if s.Width <= 0 || s.Height <= 0 {
+		return nil, specErr(index, sizeField(s.Width), "矩形需要指定宽度和高度: %dx%d", s.Width, s.Height)
+	}
+	if s.Color == "" {
+		return nil, specErr(index, "color", "未指定颜色")
+	}
+	c, err := ParseHexColor(s.Color)
+	if err != nil {
+		return nil, specErr(index, "color", "%v", err)
+	}
+
+	rect := image.Rect(s.X, s.Y, s.X+s.Width, s.Y+s.Height)
+	if s.FillColor != "" {
+		fc, err := ParseHexColor(s.FillColor)
+		if err != nil {
+			return nil, specErr(index, "fill_color", "%v", err)
+		}
+		return NewDrawRectProcessorWithFillColor(rect, c, fc), nil
+	}
+	return NewDrawRectProcessor(rect, c, s.Fill), nil
+}
+
+// zoomModeNames 缩放模式名称
+var zoomModeNames = map[string]ZoomMode{
+	"":        ZoomModeExact,
+	"exact":   ZoomModeExact,
+	"ratio":   ZoomModeRatio,
+	"width":   ZoomModeWidth,
+	"height":  ZoomModeHeight,
+	"max":     ZoomModeMax,
+	"min":     ZoomModeMin,
+	"cover":   ZoomModeCover,
+	"contain": ZoomModeContain,
+	"pad":     ZoomModePad,
+}
+
+// scalerNames 缩放算法名称
+var scalerNames = map[string]draw.Scaler{
+	"nearest":         draw.NearestNeighbor,
+	"approx-bilinear": draw.ApproxBiLinear,
+	"bilinear":        draw.BiLinear,
+	"catmull-rom":     draw.CatmullRom,
+	"lanczos2":        Lanczos2,
+	"lanczos3":        Lanczos3,
+	"mitchell":        MitchellNetravali,
+	"box":             Box,
+	"area":            AreaAverage,
+}
+
+// linearScalerPrefix 在线性光空间中缩放的缩放算法名称前缀
+const (
+	linearScalerPrefix      = "linear-"
+	progressiveScalerPrefix = "fast-"
+)
+
+// parseScaler 根据名称查找缩放算法，linear- 前缀表示使用 LinearScaler 包装，
+// fast- 前缀表示使用 ProgressiveScaler 包装，两者同时使用时 fast- 在前
+func parseScaler(name string) (draw.Scaler, bool) {
+	if inner, ok := strings.CutPrefix(name, progressiveScalerPrefix); ok {
+		scaler, ok := parseScaler(inner)
+		if !ok {
+			return nil, false
+		}
+		return NewProgressiveScaler(scaler), true
+	}
+	if inner, ok := strings.CutPrefix(name, linearScalerPrefix); ok {
+		scaler, ok := scalerNames[inner]
+		if !ok {
+			return nil, false
+		}
+		return NewLinearScaler(scaler), true
+	}
+	scaler, ok := scalerNames[name]
+	return scaler, ok
+}
+
+// isCutPosition 判断是否为有效的切割位置
+func isCutPosition(position string) bool {
+	switch CutPosition(position) {
+	case CutPositionCenter, CutPositionTop, CutPositionBottom, CutPositionLeft, CutPositionRight, CutPositionSmart:
+		return true
+	}
+	return false
+}
+
+// isOverlayPosition 判断是否为有效的叠加位置
+func isOverlayPosition(position string) bool {
+	switch position {
+	case "top-left", "top-right", "bottom-left", "bottom-right", "center",
+		"top-center", "bottom-center", "left-center", "right-center":
+		return true
+	}
+	return false
+}
+
+// isWatermarkPosition 判断是否为有效的水印位置
+func isWatermarkPosition(position string) bool {
+	switch position {
+	case "top-left", "top-right", "bottom-left", "bottom-right", "center":
+		return true
+	}
+	return false
+}
+
+// ParseHexColor 解析 #RRGGBB 或 #RRGGBBAA 格式的颜色，'#' 可省略
+func ParseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("无效的颜色: %s", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("无效的颜色: %s", s)
+	}
+
+	if len(hex) == 6 {
+		return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+	}
+	return color.RGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// loadImageFile 从文件加载图像
+func loadImageFile(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
@@ -0,0 +1,251 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParsePipelineJSON(t *testing.T) {
+	data := []byte(`{
+		"steps": [
+			{"type": "cut", "square": true, "position": "center"},
+			{"type": "zoom", "mode": "width", "width": 100, "scaler": "catmull-rom"},
+			{"type": "watermark", "text": "ACME", "position": "bottom-right", "color": "#ff0000"},
+			{"type": "mosaic", "regions": [{"from_x": 0, "from_y": 0, "to_x": 20, "to_y": 20}]}
+		]
+	}`)
+
+	spec, err := ParsePipelineJSON(data)
+	if err != nil {
+		t.Fatalf("解析配置失败: %v", err)
+	}
+
+	processors, err := spec.Processors()
+	if err != nil {
+		t.Fatalf("构建处理器链失败: %v", err)
+	}
+	if len(processors) != 4 {
+		t.Fatalf("期望4个处理器，实际 %d", len(processors))
+	}
+
+	zoom, ok := processors[1].(*ZoomProcessor)
+	if !ok || zoom.Mode != ZoomModeWidth || zoom.Width != 100 {
+		t.Fatalf("缩放处理器配置错误: %#v", processors[1])
+	}
+
+	result, err := Process(image.NewRGBA(image.Rect(0, 0, 400, 300)), processors)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if result.Bounds().Dx() != 100 || result.Bounds().Dy() != 100 {
+		t.Fatalf("期望尺寸 100x100，实际 %dx%d", result.Bounds().Dx(), result.Bounds().Dy())
+	}
+}
+
+func TestParsePipelineYAML(t *testing.T) {
+	data := []byte(`
+steps:
+  - type: zoom
+    mode: max
+    size: 50
+  - type: rotate
+    angle: 90
+    background: "#ffffff"
+  - type: draw_rect
+    x: 5
+    y: 5
+    width: 10
+    height: 10
+    color: "#00ff00"
+    fill: true
+  - type: text
+    text: hello
+    x: 2
+    y: 12
+    align: center
+`)
+
+	processors, err := CompilePipeline(data)
+	if err != nil {
+		t.Fatalf("构建处理器链失败: %v", err)
+	}
+	if len(processors) != 4 {
+		t.Fatalf("期望4个处理器，实际 %d", len(processors))
+	}
+
+	result, err := Process(image.NewRGBA(image.Rect(0, 0, 100, 40)), processors)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	// 旋转后允许1像素的误差
+	if absDiff(result.Bounds().Dx()-20) > 1 || absDiff(result.Bounds().Dy()-50) > 1 {
+		t.Fatalf("期望尺寸约 20x50，实际 %dx%d", result.Bounds().Dx(), result.Bounds().Dy())
+	}
+}
+
+func TestPipelineSpecErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		data  string
+		index int
+		field string
+	}{
+		{"未知类型", `{"steps":[{"type":"blur"}]}`, 0, "type"},
+		{"缺少类型", `{"steps":[{"width":10}]}`, 0, "type"},
+		{"缩放缺少尺寸", `{"steps":[{"type":"empty"},{"type":"zoom","width":10}]}`, 1, "height"},
+		{"未知缩放模式", `{"steps":[{"type":"zoom","mode":"fit"}]}`, 0, "mode"},
+		{"填充缩放缺少高度", `{"steps":[{"type":"zoom","mode":"pad","width":10}]}`, 0, "height"},
+		{"切割缺少宽度", `{"steps":[{"type":"cut","height":10}]}`, 0, "width"},
+		{"填充缩放无效颜色", `{"steps":[{"type":"zoom","mode":"pad","width":10,"height":10,"background":"red"}]}`, 0, "background"},
+		{"未知切割位置", `{"steps":[{"type":"cut","width":1,"height":1,"position":"middle"}]}`, 0, "position"},
+		{"无效颜色", `{"steps":[{"type":"draw_circle","radius":3,"color":"red"}]}`, 0, "color"},
+		{"无效马赛克区域", `{"steps":[{"type":"mosaic","regions":[{"from_x":10,"to_x":5,"to_y":5}]}]}`, 0, "regions[0]"},
+		{"未指定叠加图像", `{"steps":[{"type":"overlay","opacity":0.5}]}`, 0, "image"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParsePipeline([]byte(tc.data))
+			var specErr *SpecError
+			if !errors.As(err, &specErr) {
+				t.Fatalf("期望 SpecError，实际 %v", err)
+			}
+			if specErr.Index != tc.index || specErr.Field != tc.field {
+				t.Fatalf("期望 steps[%d].%s，实际 %v", tc.index, tc.field, err)
+			}
+		})
+	}
+}
+
+func TestPipelineSpecNonFinite(t *testing.T) {
+	// JSON 不能表示 NaN 和无穷大，YAML 可以
+	testCases := []struct {
+		data  string
+		field string
+	}{
+		{"steps:\n  - type: rotate\n    angle: .nan\n", "angle"},
+		{"steps:\n  - type: zoom\n    mode: ratio\n    ratio: .inf\n", "ratio"},
+		{"steps:\n  - type: mosaic\n    regions: [{from_x: 0, from_y: 0, to_x: 1, to_y: 1}]\n    percent: .nan\n", "percent"},
+		{"steps:\n  - type: watermark\n    text: abc\n    opacity: .nan\n", "opacity"},
+		{"steps:\n  - type: watermark\n    text: abc\n    rotation: -.inf\n", "rotation"},
+		{"steps:\n  - type: text\n    text: abc\n    angle: .nan\n", "angle"},
+		{"steps:\n  - type: overlay\n    image: logo.png\n    scale: .inf\n", "scale"},
+	}
+	for _, tc := range testCases {
+		_, err := ParsePipelineYAML([]byte(tc.data))
+		var specErr *SpecError
+		if !errors.As(err, &specErr) || specErr.Field != tc.field {
+			t.Fatalf("期望 %s 字段错误，实际 %v: %q", tc.field, err, tc.data)
+		}
+	}
+}
+
+func TestPipelineSpecOverlayNotExist(t *testing.T) {
+	// 校验时不加载叠加图像，构建处理器链时才返回错误
+	spec, err := ParsePipelineJSON([]byte(`{"steps":[{"type":"overlay","image":"/not/exist.png"}]}`))
+	if err != nil {
+		t.Fatalf("校验配置失败: %v", err)
+	}
+
+	_, err = spec.Processors()
+	var specErr *SpecError
+	if !errors.As(err, &specErr) || specErr.Field != "image" {
+		t.Fatalf("期望 steps[0].image 错误，实际 %v", err)
+	}
+}
+
+func TestParsePipelineUnknownField(t *testing.T) {
+	if _, err := ParsePipelineJSON([]byte(`{"steps":[{"type":"zoom","widht":10}]}`)); err == nil {
+		t.Fatal("未知字段应返回错误")
+	}
+	if _, err := ParsePipelineYAML([]byte("steps:\n  - type: zoom\n    widht: 10\n")); err == nil {
+		t.Fatal("未知字段应返回错误")
+	}
+}
+
+func TestPipelineSpecContextProcessors(t *testing.T) {
+	dir := t.TempDir()
+	overlayPath := filepath.Join(dir, "overlay.png")
+	buf := new(bytes.Buffer)
+	overlay := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := range overlay.Pix {
+		overlay.Pix[i] = 255
+	}
+	if err := png.Encode(buf, overlay); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(overlayPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	specPath := filepath.Join(dir, "pipeline.yaml")
+	content := "steps:\n" +
+		"  - type: overlay\n    image: " + overlayPath + "\n    position: center\n" +
+		"  - type: draw_circle\n    x: 2\n    y: 2\n    radius: 2\n    color: '#ff000080'\n"
+	if err := os.WriteFile(specPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	spec, err := LoadPipelineFile(specPath)
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+
+	processors, err := spec.ContextProcessors()
+	if err != nil {
+		t.Fatalf("构建上下文处理器链失败: %v", err)
+	}
+
+	result, err := ContextProcess(image.NewRGBA(image.Rect(0, 0, 30, 30)), processors)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if c := color.RGBAModel.Convert(result.At(15, 15)).(color.RGBA); c.R != 255 || c.A != 255 {
+		t.Fatalf("叠加图像未绘制: %v", c)
+	}
+
+	// 不支持上下文处理的步骤
+	spec.Steps = append(spec.Steps, StepSpec{Type: StepZoom, Mode: "ratio", Ratio: 0.5})
+	if _, err := spec.ContextProcessors(); err == nil {
+		t.Fatal("缩放处理器不支持上下文处理，应返回错误")
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	c, err := ParseHexColor("#102030")
+	if err != nil || c != (color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0xff}) {
+		t.Fatalf("解析颜色错误: %v %v", c, err)
+	}
+
+	c, err = ParseHexColor("10203040")
+	if err != nil || c != (color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x40}) {
+		t.Fatalf("解析颜色错误: %v %v", c, err)
+	}
+
+	if _, err = ParseHexColor("#12345"); err == nil {
+		t.Fatal("无效颜色应返回错误")
+	}
+}
@@ -58,6 +58,8 @@ var DefaultTextOptions = TextOptions{
 }
 
 // TextProcessor 实现文本处理器
+// 可以被多个 goroutine 并发使用，NewTextProcessor 使用 NewSyncFace 包装字体。
+// 直接设置 Options.Font 或与其他处理器共享字体时，需要自行使用 NewSyncFace 包装。
 type TextProcessor struct {
 	Options TextOptions
 }
@@ -67,6 +69,7 @@ func NewTextProcessor(opts TextOptions) *TextProcessor {
 	if opts.Font == nil {
 		opts.Font = DefaultTextOptions.Font
 	}
+	opts.Font = NewSyncFace(opts.Font)
 	if opts.Color == nil {
 		opts.Color = DefaultTextOptions.Color
 	}
@@ -97,6 +100,11 @@ func (p *TextProcessor) Process(img image.Image) (image.Image, error) {
 	return ctx.dc.Image(), nil
 }
 
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *TextProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
+
 // ContextProcess 实现 ContextProcessor 接口
 func (p *TextProcessor) ContextProcess(ctx *ImageProcessContext) error {
 	dc := ctx.DC()
@@ -0,0 +1,590 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URL参数名称
+const (
+	urlOptResize      = "rs"
+	urlOptCut         = "c"
+	urlOptCutRegion   = "cr"
+	urlOptCutAspect   = "ca"
+	urlOptRotate      = "rt"
+	urlOptRounded     = "rc"
+	urlOptCircle      = "ci"
+	urlOptSharpen     = "sh"
+	urlOptSeamCarve   = "sc"
+	urlOptMosaic      = "mo"
+	urlOptWatermark   = "wm"
+	urlOptQuality     = "q"
+	urlOptFormat      = "f"
+	urlWatermarkText  = "text"
+	urlWatermarkImage = "image"
+	urlNoUpscale      = "noup"
+	urlBlurBackground = "blur"
+)
+
+// URLOptions URL路径中的处理参数
+//
+// 参数之间使用 '/' 分隔，参数名与参数值之间使用 ':' 分隔，例如:
+//
+//	rs:width:300/c:center/wm:text:ACME/q:80
+//
+// 支持的参数:
+//
+//	rs:exact:<w>:<h>[:<scaler>]         精确缩放
+//	rs:ratio:<r>[:<scaler>]             按比例缩放
+//	rs:width:<w>[:<scaler>]             按宽度缩放
+//	rs:height:<h>[:<scaler>]            按高度缩放
+//	rs:max:<size>[:<scaler>]            按最大边缩放
+//	rs:min:<size>[:<scaler>]            按最小边缩放
+//	rs:cover:<w>:<h>[:<position>[:<scaler>]]  按比例缩放到覆盖目标尺寸后裁剪
+//	rs:contain:<w>:<h>[:<scaler>]       按比例缩放到目标尺寸以内
+//	rs:pad:<w>:<h>[:<background>[:<position>[:<scaler>]]]  缩放到目标尺寸以内后填充，background 为 blur 时使用模糊的原图填充
+//	rs:...:noup                         缩放参数末尾加 noup 表示不放大图片
+//	c:<position>[:<size>]               正方形切割，未指定边长时使用较小边
+//	c:<w>:<h>[:<position>]              矩形切割
+//	cr:<x>:<y>:<w>:<h>                  自定义区域切割
+//	cr:<x>:<y>:<size>                   自定义区域正方形切割
+//	ca:<aspect>[:<position>]            按宽高比切割最大区域，例如 ca:1.5:smart
+//	rt:<angle>[:<background>[:<keep>]]  旋转，keep 为 1 时保持原始尺寸
+//	rc:<radius>                         圆角
+//	ci                                  圆形裁剪
+//	sh:<amount>[:<sigma>[:<threshold>]] USM 锐化
+//	mo:<fx>,<fy>,<tx>,<ty>[_...][:<percent>[:<direction>]]  马赛克
+//	sc:<w>[:<h>[:<protect>[:<remove>]]] 接缝裁剪，w 或 h 为空时保持原尺寸，区域格式与马赛克相同
+//	wm:text:<text>[:<position>[:<opacity>[:<color>[:<size>[:<rotation>]]]]]  文本水印
+//	wm:image:<path>[:<position>[:<opacity>[:<scale>[:<x>[:<y>]]]]]          图像叠加
+//	q:<quality>                         JPEG压缩质量
+//	f:<format>                          输出格式: jpeg(jpg), png, gif, bmp, tiff
+//
+// 文本、路径等参数值需要进行URL转义，颜色使用不带 '#' 的十六进制格式。
+type URLOptions struct {
+	Steps   []StepSpec
+	Quality int         // JPEG压缩质量，0 表示使用默认值
+	Format  ImageFormat // 输出格式，为空时与输入格式一致
+}
+
+// ParseURLOptions 解析URL处理参数
+func ParseURLOptions(s string) (*URLOptions, error) {
+	opts := &URLOptions{}
+
+	for _, seg := range strings.Split(strings.Trim(s, "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		if err := opts.parseSegment(seg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := opts.Spec().Validate(); err != nil {
+		return nil, err
+	}
+
+	return opts, nil
+}
+
+// ParseURLPath 解析形如 /rs:width:300/q:80/path/to/photo.jpg 的URL路径
+// 返回处理参数和剩余的源图片路径
+func ParseURLPath(path string) (*URLOptions, string, error) {
+	segs := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	n := 0
+	for n < len(segs) && isURLOptionSegment(segs[n]) {
+		n++
+	}
+
+	opts, err := ParseURLOptions(strings.Join(segs[:n], "/"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return opts, strings.Join(segs[n:], "/"), nil
+}
+
+// Spec 转换为声明式处理器链配置
+func (o *URLOptions) Spec() *PipelineSpec {
+	return &PipelineSpec{Steps: o.Steps}
+}
+
+// Processors 构建处理器链
+func (o *URLOptions) Processors() ([]Processor, error) {
+	return o.Spec().Processors()
+}
+
+// ProcessorOptions 构建处理选项
+func (o *URLOptions) ProcessorOptions() *ProcessorOptions {
+	options := DefaultProcessorOptions
+	if o.Quality > 0 {
+		options.Quality = o.Quality
+	}
+	options.Format = o.Format
+	return &options
+}
+
+// Encode 将处理参数编码为URL路径片段，是 ParseURLOptions 的逆过程
+func (o *URLOptions) Encode() (string, error) {
+	segs := make([]string, 0, len(o.Steps)+1)
+
+	for i := range o.Steps {
+		seg, err := encodeURLStep(&o.Steps[i])
+		if err != nil {
+			return "", &SpecError{Index: i, Field: "type", Msg: err.Error()}
+		}
+		segs = append(segs, seg)
+	}
+
+	if o.Quality > 0 {
+		segs = append(segs, urlOptQuality+":"+strconv.Itoa(o.Quality))
+	}
+	if o.Format != FormatAuto {
+		segs = append(segs, urlOptFormat+":"+string(o.Format))
+	}
+
+	return strings.Join(segs, "/"), nil
+}
+
+// EncodeURLPath 编码处理参数和源图片路径
+func (o *URLOptions) EncodeURLPath(source string) (string, error) {
+	s, err := o.Encode()
+	if err != nil {
+		return "", err
+	}
+	if s == "" {
+		return "/" + strings.TrimPrefix(source, "/"), nil
+	}
+	return "/" + s + "/" + strings.TrimPrefix(source, "/"), nil
+}
+
+// isURLOptionSegment 判断路径片段是否为处理参数
+func isURLOptionSegment(seg string) bool {
+	name, _, _ := strings.Cut(seg, ":")
+	switch name {
+	case urlOptResize, urlOptCut, urlOptCutRegion, urlOptCutAspect, urlOptRotate, urlOptRounded,
+		urlOptMosaic, urlOptWatermark, urlOptQuality, urlOptFormat, urlOptSharpen,
+		urlOptSeamCarve:
+		return strings.Contains(seg, ":")
+	case urlOptCircle:
+		return seg == urlOptCircle
+	}
+	return false
+}
+
+// parseSegment 解析单个参数
+func (o *URLOptions) parseSegment(seg string) error {
+	parts := strings.Split(seg, ":")
+	name := parts[0]
+	args := make([]string, len(parts)-1)
+	for i, p := range parts[1:] {
+		v, err := url.PathUnescape(p)
+		if err != nil {
+			return fmt.Errorf("参数 %s 转义错误: %w", seg, err)
+		}
+		args[i] = v
+	}
+
+	var (
+		step StepSpec
+		err  error
+	)
+
+	switch name {
+	case urlOptResize:
+		step, err = parseURLResize(args)
+	case urlOptCut:
+		step, err = parseURLCut(args)
+	case urlOptCutRegion:
+		step, err = parseURLCutRegion(args)
+	case urlOptCutAspect:
+		step = StepSpec{Type: StepCut}
+		err = parseURLArgs(args, 1, 2, &step.Aspect, &step.Position)
+	case urlOptRotate:
+		step, err = parseURLRotate(args)
+	case urlOptRounded:
+		step = StepSpec{Type: StepRoundedCorner}
+		err = parseURLArgs(args, 1, 1, &step.Radius)
+	case urlOptCircle:
+		step = StepSpec{Type: StepCircle}
+		err = parseURLArgs(args, 0, 0)
+	case urlOptSharpen:
+		step = StepSpec{Type: StepSharpen}
+		err = parseURLArgs(args, 1, 3, &step.Amount, &step.Sigma, &step.Threshold)
+	case urlOptMosaic:
+		step, err = parseURLMosaic(args)
+	case urlOptSeamCarve:
+		step, err = parseURLSeamCarve(args)
+	case urlOptWatermark:
+		step, err = parseURLWatermark(args)
+	case urlOptQuality:
+		if err = parseURLArgs(args, 1, 1, &o.Quality); err == nil && (o.Quality < 1 || o.Quality > 100) {
+			err = fmt.Errorf("压缩质量必须在1-100之间: %d", o.Quality)
+		}
+		if err != nil {
+			return fmt.Errorf("参数 %s: %w", seg, err)
+		}
+		return nil
+	case urlOptFormat:
+		var format string
+		if err = parseURLArgs(args, 1, 1, &format); err == nil {
+			o.Format, err = ParseOutputFormat(format)
+		}
+		if err != nil {
+			return fmt.Errorf("参数 %s: %w", seg, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("未知的参数: %s", seg)
+	}
+
+	if err != nil {
+		return fmt.Errorf("参数 %s: %w", seg, err)
+	}
+
+	o.Steps = append(o.Steps, step)
+	return nil
+}
+
+func parseURLResize(args []string) (StepSpec, error) {
+	if len(args) == 0 {
+		return StepSpec{}, fmt.Errorf("未指定缩放模式")
+	}
+
+	step := StepSpec{Type: StepZoom, Mode: args[0]}
+	args = args[1:]
+	if len(args) > 0 && args[len(args)-1] == urlNoUpscale {
+		step.NoUpscale = true
+		args = args[:len(args)-1]
+	}
+
+	var err error
+	switch step.Mode {
+	case "exact":
+		err = parseURLArgs(args, 2, 3, &step.Width, &step.Height, &step.Scaler)
+	case "ratio":
+		err = parseURLArgs(args, 1, 2, &step.Ratio, &step.Scaler)
+	case "width":
+		err = parseURLArgs(args, 1, 2, &step.Width, &step.Scaler)
+	case "height":
+		err = parseURLArgs(args, 1, 2, &step.Height, &step.Scaler)
+	case "max", "min":
+		err = parseURLArgs(args, 1, 2, &step.Size, &step.Scaler)
+	case "cover":
+		err = parseURLArgs(args, 2, 4, &step.Width, &step.Height, &step.Position, &step.Scaler)
+	case "contain":
+		err = parseURLArgs(args, 2, 3, &step.Width, &step.Height, &step.Scaler)
+	case "pad":
+		err = parseURLArgs(args, 2, 5, &step.Width, &step.Height, &step.Background, &step.Position, &step.Scaler)
+		if step.Background == urlBlurBackground {
+			step.Background, step.Blur = "", true
+		}
+	default:
+		err = fmt.Errorf("未知的缩放模式: %s", step.Mode)
+	}
+
+	return step, err
+}
+
+func parseURLCut(args []string) (StepSpec, error) {
+	if len(args) == 0 {
+		return StepSpec{}, fmt.Errorf("未指定切割参数")
+	}
+
+	// 第一个参数不是数字时为正方形切割
+	if _, err := strconv.Atoi(args[0]); err != nil {
+		step := StepSpec{Type: StepCut, Square: true}
+		err := parseURLArgs(args, 1, 2, &step.Position, &step.Size)
+		return step, err
+	}
+
+	step := StepSpec{Type: StepCut}
+	err := parseURLArgs(args, 2, 3, &step.Width, &step.Height, &step.Position)
+	return step, err
+}
+
+func parseURLCutRegion(args []string) (StepSpec, error) {
+	step := StepSpec{Type: StepCut, CustomRegion: true}
+	if len(args) == 3 {
+		step.Square = true
+		err := parseURLArgs(args, 3, 3, &step.X, &step.Y, &step.Size)
+		return step, err
+	}
+	err := parseURLArgs(args, 4, 4, &step.X, &step.Y, &step.Width, &step.Height)
+	return step, err
+}
+
+func parseURLRotate(args []string) (StepSpec, error) {
+	step := StepSpec{Type: StepRotate}
+	var keep int
+	if err := parseURLArgs(args, 1, 3, &step.Angle, &step.Background, &keep); err != nil {
+		return step, err
+	}
+	step.KeepSize = keep == 1
+	return step, nil
+}
+
+func parseURLMosaic(args []string) (StepSpec, error) {
+	step := StepSpec{Type: StepMosaic}
+	var regions string
+	if err := parseURLArgs(args, 1, 3, &regions, &step.Percent, &step.Direction); err != nil {
+		return step, err
+	}
+
+	var err error
+	step.Regions, err = parseURLRegions(regions)
+	return step, err
+}
+
+func parseURLSeamCarve(args []string) (StepSpec, error) {
+	step := StepSpec{Type: StepSeamCarve}
+	var protect, remove string
+	if err := parseURLArgs(args, 1, 4, &step.Width, &step.Height, &protect, &remove); err != nil {
+		return step, err
+	}
+
+	var err error
+	if step.Protect, err = parseURLRegions(protect); err != nil {
+		return step, err
+	}
+	step.Remove, err = parseURLRegions(remove)
+	return step, err
+}
+
+// parseURLRegions 解析 fx,fy,tx,ty 格式的区域，多个区域使用 '_' 分隔，为空时返回 nil
+func parseURLRegions(s string) ([]*MosaicRegion, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var regions []*MosaicRegion
+	for _, r := range strings.Split(s, "_") {
+		coords := strings.Split(r, ",")
+		region := &MosaicRegion{}
+		if err := parseURLArgs(coords, 4, 4, &region.FromX, &region.FromY, &region.ToX, &region.ToY); err != nil {
+			return nil, fmt.Errorf("无效的区域 %s: %w", r, err)
+		}
+		regions = append(regions, region)
+	}
+	return regions, nil
+}
+
+// formatURLRegions 格式化区域列表
+func formatURLRegions(regions []*MosaicRegion) string {
+	parts := make([]string, 0, len(regions))
+	for _, r := range regions {
+		parts = append(parts, fmt.Sprintf("%d,%d,%d,%d", r.FromX, r.FromY, r.ToX, r.ToY))
+	}
+	return strings.Join(parts, "_")
+}
+
+func parseURLWatermark(args []string) (StepSpec, error) {
+	if len(args) == 0 {
+		return StepSpec{}, fmt.Errorf("未指定水印类型")
+	}
+
+	switch args[0] {
+	case urlWatermarkText:
+		step := StepSpec{Type: StepWatermark}
+		err := parseURLArgs(args[1:], 1, 6,
+			&step.Text, &step.Position, &step.Opacity, &step.Color, &step.FontSize, &step.Rotation)
+		return step, err
+	case urlWatermarkImage:
+		step := StepSpec{Type: StepOverlay}
+		err := parseURLArgs(args[1:], 1, 6,
+			&step.Image, &step.Position, &step.Opacity, &step.Scale, &step.X, &step.Y)
+		return step, err
+	default:
+		return StepSpec{}, fmt.Errorf("未知的水印类型: %s", args[0])
+	}
+}
+
+// parseURLArgs 按顺序解析参数值，空字符串表示使用零值
+func parseURLArgs(args []string, minArgs, maxArgs int, targets ...any) error {
+	if len(args) < minArgs || len(args) > maxArgs {
+		if minArgs == maxArgs {
+			return fmt.Errorf("需要 %d 个参数，实际 %d 个", minArgs, len(args))
+		}
+		return fmt.Errorf("需要 %d-%d 个参数，实际 %d 个", minArgs, maxArgs, len(args))
+	}
+
+	for i, arg := range args {
+		if arg == "" {
+			continue
+		}
+
+		var err error
+		switch t := targets[i].(type) {
+		case *string:
+			*t = arg
+		case *int:
+			*t, err = strconv.Atoi(arg)
+		case *float64:
+			*t, err = parseFiniteFloat(arg, 64)
+		case *float32:
+			var f float64
+			f, err = parseFiniteFloat(arg, 32)
+			*t = float32(f)
+		}
+		if err != nil {
+			return fmt.Errorf("无效的参数值: %s", arg)
+		}
+	}
+
+	return nil
+}
+
+// parseFiniteFloat 解析浮点数，NaN 和无穷大返回错误
+func parseFiniteFloat(s string, bitSize int) (float64, error) {
+	f, err := strconv.ParseFloat(s, bitSize)
+	if err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		err = strconv.ErrSyntax
+	}
+	return f, err
+}
+
+// encodeURLStep 编码单个步骤
+func encodeURLStep(s *StepSpec) (string, error) {
+	var args []string
+
+	switch s.Type {
+	case StepZoom:
+		mode := s.Mode
+		if mode == "" {
+			mode = "exact"
+		}
+		args = append(args, urlOptResize, mode)
+		switch mode {
+		case "exact":
+			args = append(args, strconv.Itoa(s.Width), strconv.Itoa(s.Height))
+		case "ratio":
+			args = append(args, formatURLFloat(s.Ratio))
+		case "width":
+			args = append(args, strconv.Itoa(s.Width))
+		case "height":
+			args = append(args, strconv.Itoa(s.Height))
+		case "cover":
+			args = append(args, strconv.Itoa(s.Width), strconv.Itoa(s.Height), s.Position)
+		case "contain":
+			args = append(args, strconv.Itoa(s.Width), strconv.Itoa(s.Height))
+		case "pad":
+			background := strings.TrimPrefix(s.Background, "#")
+			if s.Blur {
+				background = urlBlurBackground
+			}
+			args = append(args, strconv.Itoa(s.Width), strconv.Itoa(s.Height), background, s.Position)
+		default:
+			args = append(args, strconv.Itoa(s.Size))
+		}
+		args = append(args, s.Scaler)
+		if s.NoUpscale {
+			args = append(args, urlNoUpscale)
+		}
+
+	case StepCut:
+		position := s.Position
+		if position == "" {
+			position = string(CutPositionCenter)
+		}
+		switch {
+		case s.CustomRegion && s.Square:
+			args = append(args, urlOptCutRegion, strconv.Itoa(s.X), strconv.Itoa(s.Y), strconv.Itoa(max(s.Size, s.Width, s.Height)))
+		case s.CustomRegion:
+			args = append(args, urlOptCutRegion, strconv.Itoa(s.X), strconv.Itoa(s.Y), strconv.Itoa(s.Width), strconv.Itoa(s.Height))
+		case s.Square:
+			args = append(args, urlOptCut, position, formatURLInt(max(s.Size, s.Width, s.Height)))
+		case s.Aspect > 0 && s.Width == 0 && s.Height == 0:
+			args = append(args, urlOptCutAspect, formatURLFloat(s.Aspect), s.Position)
+		default:
+			args = append(args, urlOptCut, strconv.Itoa(s.Width), strconv.Itoa(s.Height), s.Position)
+		}
+
+	case StepRotate:
+		keep := ""
+		if s.KeepSize {
+			keep = "1"
+		}
+		args = append(args, urlOptRotate, strconv.FormatFloat(s.Angle, 'f', -1, 64), strings.TrimPrefix(s.Background, "#"), keep)
+
+	case StepRoundedCorner:
+		args = append(args, urlOptRounded, strconv.Itoa(s.Radius))
+
+	case StepCircle:
+		args = append(args, urlOptCircle)
+
+	case StepSharpen:
+		args = append(args, urlOptSharpen, formatURLFloat(s.Amount), formatURLFloat(s.Sigma), formatURLInt(s.Threshold))
+
+	case StepMosaic:
+		percent := ""
+		if s.Percent != 0 {
+			percent = strconv.FormatFloat(float64(s.Percent), 'f', -1, 32)
+		}
+		args = append(args, urlOptMosaic, formatURLRegions(s.Regions), percent, s.Direction)
+
+	case StepSeamCarve:
+		args = append(args, urlOptSeamCarve, formatURLInt(s.Width), formatURLInt(s.Height),
+			formatURLRegions(s.Protect), formatURLRegions(s.Remove))
+
+	case StepWatermark:
+		args = append(args, urlOptWatermark, urlWatermarkText, escapeURLArg(s.Text), s.Position,
+			formatURLFloat(s.Opacity), strings.TrimPrefix(s.Color, "#"),
+			formatURLFloat(s.FontSize), formatURLFloat(s.Rotation))
+
+	case StepOverlay:
+		args = append(args, urlOptWatermark, urlWatermarkImage, escapeURLArg(s.Image), s.Position,
+			formatURLFloat(s.Opacity), formatURLFloat(s.Scale), formatURLInt(s.X), formatURLInt(s.Y))
+
+	default:
+		return "", fmt.Errorf("处理器 %s 不支持URL编码", s.Type)
+	}
+
+	// 去掉末尾的空参数
+	for len(args) > 1 && args[len(args)-1] == "" {
+		args = args[:len(args)-1]
+	}
+
+	return strings.Join(args, ":"), nil
+}
+
+// escapeURLArg 转义参数值，确保不包含 '/' 和 ':'
+func escapeURLArg(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), ":", "%3A")
+}
+
+// formatURLFloat 格式化浮点数，零值返回空字符串
+func formatURLFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatURLInt 格式化整数，零值返回空字符串
+func formatURLInt(i int) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,208 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestParseURLOptions(t *testing.T) {
+	opts, err := ParseURLOptions("rs:width:300/c:center/wm:text:ACME/q:80")
+	if err != nil {
+		t.Fatalf("解析URL参数失败: %v", err)
+	}
+
+	if len(opts.Steps) != 3 {
+		t.Fatalf("期望3个步骤，实际 %d", len(opts.Steps))
+	}
+	if opts.ProcessorOptions().Quality != 80 {
+		t.Fatalf("期望压缩质量 80，实际 %d", opts.ProcessorOptions().Quality)
+	}
+
+	formatOpts, err := ParseURLOptions("ci/f:jpg")
+	if err != nil || formatOpts.ProcessorOptions().Format != FormatJPEG {
+		t.Fatalf("解析输出格式失败: %v", err)
+	}
+
+	processors, err := opts.Processors()
+	if err != nil {
+		t.Fatalf("构建处理器链失败: %v", err)
+	}
+
+	result, err := Process(image.NewRGBA(image.Rect(0, 0, 600, 400)), processors)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+	if result.Bounds().Dx() != 200 || result.Bounds().Dy() != 200 {
+		t.Fatalf("期望尺寸 200x200，实际 %dx%d", result.Bounds().Dx(), result.Bounds().Dy())
+	}
+}
+
+func TestURLOptionsRoundTrip(t *testing.T) {
+	testCases := []string{
+		"rs:exact:300:200",
+		"rs:ratio:0.5:catmull-rom",
+		"rs:max:128",
+		"rs:cover:300:300:top",
+		"rs:contain:300:200:nearest",
+		"rs:pad:300:300:blur",
+		"rs:pad:300:300:ffffff:left:catmull-rom:noup",
+		"rs:width:300::noup",
+		"rs:width:300:linear-lanczos3",
+		"sh:0.8:1.5:4",
+		"sh:0.5",
+		"c:center",
+		"c:top:100",
+		"c:300:200:left",
+		"cr:10:20:30:40",
+		"cr:10:20:30",
+		"ca:1.5:smart",
+		"ca:0.75",
+		"rs:cover:200:200:smart",
+		"rt:90",
+		"rt:45:ffffff:1",
+		"rc:16",
+		"ci",
+		"mo:0,0,20,20_30,30,60,60:0.5:top",
+		"sc:200",
+		"sc::80",
+		"sc:200:100:0,0,10,10_20,20,30,30:40,40,50,50",
+		"sc::::0,0,10,10",
+		"wm:text:Hello%20World%3A%2F:bottom-right:0.7:ff0000:18:-15",
+		"rs:min:100/c:center/ci/q:75",
+		"rs:width:300/q:80/f:jpeg",
+		"ci/f:png",
+	}
+
+	for _, s := range testCases {
+		t.Run(s, func(t *testing.T) {
+			opts, err := ParseURLOptions(s)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+
+			encoded, err := opts.Encode()
+			if err != nil {
+				t.Fatalf("编码失败: %v", err)
+			}
+			if encoded != s {
+				t.Fatalf("编码结果不一致: 期望 %s，实际 %s", s, encoded)
+			}
+
+			again, err := ParseURLOptions(encoded)
+			if err != nil {
+				t.Fatalf("再次解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(opts, again) {
+				t.Fatalf("再次解析结果不一致: %#v != %#v", opts, again)
+			}
+		})
+	}
+}
+
+func TestURLOptionsWatermarkText(t *testing.T) {
+	opts, err := ParseURLOptions("wm:text:Hello%20World%3A%2F")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if opts.Steps[0].Text != "Hello World:/" {
+		t.Fatalf("水印文本解析错误: %q", opts.Steps[0].Text)
+	}
+}
+
+func TestURLOptionsOverlayNotLoaded(t *testing.T) {
+	// 解析参数时只校验语法，不访问叠加图像文件
+	opts, err := ParseURLOptions("wm:image:%2Fnot%2Fexist.png:center")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if opts.Steps[0].Image != "/not/exist.png" {
+		t.Fatalf("图像路径解析错误: %q", opts.Steps[0].Image)
+	}
+	if _, err := opts.Processors(); err == nil {
+		t.Fatal("构建处理器链时期望加载叠加图像失败")
+	}
+}
+
+func TestParseURLPath(t *testing.T) {
+	opts, source, err := ParseURLPath("/rs:width:300/q:80/images/2024/photo.jpg")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if source != "images/2024/photo.jpg" {
+		t.Fatalf("源图片路径错误: %s", source)
+	}
+	if len(opts.Steps) != 1 || opts.Quality != 80 {
+		t.Fatalf("处理参数错误: %#v", opts)
+	}
+
+	path, err := opts.EncodeURLPath(source)
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	if path != "/rs:width:300/q:80/images/2024/photo.jpg" {
+		t.Fatalf("编码路径错误: %s", path)
+	}
+
+	opts, source, err = ParseURLPath("/photo.jpg")
+	if err != nil || source != "photo.jpg" || len(opts.Steps) != 0 {
+		t.Fatalf("无参数路径解析错误: %v %s %#v", err, source, opts)
+	}
+}
+
+func TestParseURLOptionsErrors(t *testing.T) {
+	testCases := []string{
+		"xx:1",
+		"rs:fit:300:200",
+		"rs:width",
+		"rs:width:abc",
+		"c:1",
+		"q:0",
+		"q:101",
+		"rc:0",
+		"mo:1,2,3",
+		"wm:video:abc",
+		"ci:1",
+		"f:webp",
+		"f:heic",
+		"f:",
+		"rt:NaN",
+		"rt:Inf",
+		"sh:NaN",
+		"sh:1:-Inf",
+		"wm:text:abc:center:NaN",
+		"mo:0,0,10,10:NaN",
+	}
+
+	for _, s := range testCases {
+		t.Run(s, func(t *testing.T) {
+			if _, err := ParseURLOptions(s); err == nil {
+				t.Fatalf("期望返回错误: %s", s)
+			}
+		})
+	}
+}
+
+func TestURLOptionsEncodeUnsupported(t *testing.T) {
+	opts := &URLOptions{Steps: []StepSpec{{Type: StepNoise, Lines: 1}}}
+	if _, err := opts.Encode(); err == nil {
+		t.Fatal("不支持的处理器应返回错误")
+	}
+}
@@ -0,0 +1,308 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"reflect"
+	"slices"
+)
+
+// Variant 派生图片，例如响应式图片中的一个宽度
+type Variant struct {
+	Name       string            // 名称，在同一组派生图片中唯一
+	Processors []Processor       // 处理器链
+	Options    *ProcessorOptions // 处理和编码选项，为空时使用共享选项
+}
+
+// VariantOutput 单个派生图片的输出
+type VariantOutput struct {
+	Name   string         // 派生图片名称
+	Data   []byte         // 编码后的数据
+	Result *ProcessResult // 处理结果
+}
+
+// VariantEntry 清单中的单个派生图片
+type VariantEntry struct {
+	Name   string      `json:"name" yaml:"name"`
+	Format ImageFormat `json:"format" yaml:"format"`
+	Width  int         `json:"width" yaml:"width"`
+	Height int         `json:"height" yaml:"height"`
+	Size   int         `json:"size" yaml:"size"` // 编码后的字节数
+	// 复用了该派生图片的缩放结果，为空表示从原图处理
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+}
+
+// VariantManifest 派生图片清单，可以直接序列化后保存
+type VariantManifest struct {
+	InputFormat ImageFormat    `json:"input_format" yaml:"input_format"`
+	Width       int            `json:"width" yaml:"width"`   // 校正方向后的原图宽度
+	Height      int            `json:"height" yaml:"height"` // 校正方向后的原图高度
+	Variants    []VariantEntry `json:"variants" yaml:"variants"`
+}
+
+// VariantsResult 一组派生图片的处理结果
+type VariantsResult struct {
+	Outputs  []VariantOutput // 与输入的派生图片顺序相同
+	Manifest VariantManifest
+}
+
+// ProcessVariants 解码一次输入图片，生成多个派生图片
+// 见 ProcessVariantsWithContext。
+func ProcessVariants(r io.Reader, variants []Variant, options *ProcessorOptions) (*VariantsResult, error) {
+	return ProcessVariantsWithContext(context.Background(), r, variants, options)
+}
+
+// ProcessVariantsWithContext 解码一次输入图片，按各自的处理器链和选项生成多个派生图片，ctx 取消或超时后返回 ctx.Err()
+// 解码相关的选项 (MaxInputBytes、DisableAutoOrient) 以及输入图片的限制使用共享的 options，GIF 动画只处理第一帧。
+// 处理器链以 ZoomProcessor 开始时，按缩放后的尺寸从大到小处理，从已生成的不小于目标尺寸的缩放结果继续缩小，
+// 输出尺寸与从原图处理相同。任一派生图片失败时返回带有派生图片名称的错误。
+func ProcessVariantsWithContext(ctx context.Context, r io.Reader, variants []Variant,
+	options *ProcessorOptions,
+) (*VariantsResult, error) {
+	if options == nil {
+		options = &DefaultProcessorOptions
+	}
+	ctx = WithObserver(ctx, options.Observer)
+
+	// 校验名称和输出格式，无需解码即可返回错误
+	names := make(map[string]bool, len(variants))
+	for _, v := range variants {
+		if v.Name == "" {
+			return nil, errors.New("派生图片名称不能为空")
+		}
+		if names[v.Name] {
+			return nil, fmt.Errorf("派生图片名称重复: %s", v.Name)
+		}
+		names[v.Name] = true
+
+		vopts := variantOptions(v, options)
+		if vopts.Format != FormatAuto && !vopts.Format.CanEncode() {
+			return nil, variantErr(v, &UnsupportedFormatError{Format: string(vopts.Format), Encode: true})
+		}
+	}
+
+	src, err := openSource(r, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := options.checkSource(src, nil); err != nil {
+		return nil, err
+	}
+	for _, v := range variants {
+		if err := variantOptions(v, options).checkSource(src, v.Processors); err != nil {
+			return nil, variantErr(v, err)
+		}
+	}
+
+	img, err := src.decode(options)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := imageSize(img)
+	result := &VariantsResult{
+		Outputs: make([]VariantOutput, len(variants)),
+		Manifest: VariantManifest{
+			InputFormat: src.format,
+			Width:       width,
+			Height:      height,
+			Variants:    make([]VariantEntry, len(variants)),
+		},
+	}
+
+	// 缩放结果较大的先处理，供后续较小的派生图片复用
+	jobs := make([]*variantJob, len(variants))
+	for i, v := range variants {
+		jobs[i] = newVariantJob(i, v, width, height)
+	}
+	slices.SortStableFunc(jobs, func(a, b *variantJob) int {
+		return b.area() - a.area()
+	})
+
+	var scaled []*scaledImage
+	for _, job := range jobs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		output, entry, err := job.process(ctx, img, src, options, &scaled)
+		if err != nil {
+			return nil, variantErr(job.variant, err)
+		}
+		result.Outputs[job.index] = *output
+		result.Manifest.Variants[job.index] = *entry
+	}
+
+	return result, nil
+}
+
+// variantOptions 返回派生图片的选项，解码相关的选项使用共享选项
+func variantOptions(v Variant, options *ProcessorOptions) *ProcessorOptions {
+	if v.Options == nil || v.Options == options {
+		return options
+	}
+	vopts := *v.Options
+	vopts.MaxInputBytes = options.MaxInputBytes
+	vopts.DisableAutoOrient = options.DisableAutoOrient
+	return &vopts
+}
+
+// variantErr 为错误添加派生图片名称
+func variantErr(v Variant, err error) error {
+	return fmt.Errorf("派生图片 %s: %w", v.Name, err)
+}
+
+// scaledImage 已生成的保持比例的缩放结果
+type scaledImage struct {
+	name   string
+	img    image.Image
+	scaler any
+}
+
+// variantJob 单个派生图片的处理任务
+type variantJob struct {
+	index   int
+	variant Variant
+	zoom    *ZoomProcessor // 开头的缩放处理器，为空表示不复用
+	width   int            // 缩放后的宽度
+	height  int            // 缩放后的高度
+}
+
+// newVariantJob 根据原图尺寸规划开头的缩放步骤，只有不裁剪、不填充的缩小结果可以复用
+func newVariantJob(index int, v Variant, width, height int) *variantJob {
+	job := &variantJob{index: index, variant: v, width: width, height: height}
+	if len(v.Processors) == 0 {
+		return job
+	}
+	zoom, ok := v.Processors[0].(*ZoomProcessor)
+	if !ok || !zoom.scalesOnly() {
+		return job
+	}
+	w, h, err := zoom.PlanSize(width, height)
+	if err != nil || w > width || h > height {
+		return job
+	}
+	job.zoom, job.width, job.height = zoom, w, h
+	return job
+}
+
+// area 缩放后的像素数，用于排序
+func (j *variantJob) area() int {
+	return j.width * j.height
+}
+
+// process 处理并编码派生图片，开头的缩放步骤从不小于目标尺寸的最小缩放结果开始
+func (j *variantJob) process(ctx context.Context, img image.Image, src *source, options *ProcessorOptions,
+	scaled *[]*scaledImage,
+) (*VariantOutput, *VariantEntry, error) {
+	vopts := variantOptions(j.variant, options)
+	if vopts != options {
+		ctx = WithObserver(ctx, vopts.Observer)
+	}
+
+	result := &ProcessResult{
+		InputFormat: src.format,
+		Orientation: src.orientation,
+	}
+	var err error
+	if result.OutputFormat, err = vopts.outputFormat(src.format); err != nil {
+		return nil, nil, err
+	}
+
+	processors := j.variant.Processors
+	from := ""
+	current := img
+	if j.zoom != nil {
+		var base *scaledImage
+		for _, s := range *scaled {
+			w, h := imageSize(s.img)
+			if w < j.width || h < j.height || !sameScaler(s.scaler, j.zoom.Scaler) {
+				continue
+			}
+			if base == nil || w*h < imageArea(base.img) {
+				base = s
+			}
+		}
+
+		if base != nil && imageArea(base.img) == j.area() {
+			// 尺寸相同的缩放结果直接复用
+			current = base.img
+		} else {
+			zoom := j.zoom
+			if base != nil {
+				current = base.img
+				zoom = &ZoomProcessor{Width: j.width, Height: j.height, Mode: ZoomModeExact, Scaler: j.zoom.Scaler}
+			}
+			if current, err = processSteps(ctx, current, []Processor{zoom}, 0); err != nil {
+				return nil, nil, err
+			}
+			// 保持比例的缩放结果才作为后续派生图片的起点
+			if j.zoom.Mode != ZoomModeExact {
+				*scaled = append(*scaled, &scaledImage{name: j.variant.Name, img: current, scaler: j.zoom.Scaler})
+			}
+		}
+		if base != nil {
+			from = base.name
+		}
+		processors = processors[1:]
+	}
+
+	current, err = processSteps(ctx, current, processors, len(j.variant.Processors)-len(processors))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := encodeResult(ctx, buf, current, src.meta, vopts, result); err != nil {
+		return nil, nil, err
+	}
+
+	output := &VariantOutput{Name: j.variant.Name, Data: buf.Bytes(), Result: result}
+	entry := &VariantEntry{
+		Name:   j.variant.Name,
+		Format: result.OutputFormat,
+		Width:  result.Width,
+		Height: result.Height,
+		Size:   buf.Len(),
+		From:   from,
+	}
+	return output, entry, nil
+}
+
+// imageArea 图片的像素数
+func imageArea(img image.Image) int {
+	w, h := imageSize(img)
+	return w * h
+}
+
+// sameScaler 两个缩放算法是否相同，无法比较的类型视为不同
+func sameScaler(a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
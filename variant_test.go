@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestProcessVariants(t *testing.T) {
+	data := encodeTestPNG(t, 64, 48)
+
+	variants := []Variant{
+		{Name: "w16", Processors: []Processor{NewZoomWidthProcessor(16)}},
+		{Name: "w48", Processors: []Processor{NewZoomWidthProcessor(48)}},
+		{Name: "w32", Processors: []Processor{NewZoomWidthProcessor(32)}, Options: &ProcessorOptions{Format: FormatJPEG}},
+		{Name: "same", Processors: []Processor{NewZoomHeightProcessor(24)}},
+		{Name: "square", Processors: []Processor{NewZoomProcessor(20, 20), NewCutSquareProcessor("center")}},
+		{Name: "big", Processors: []Processor{NewZoomWidthProcessor(128)}},
+		{Name: "orig"},
+	}
+	result, err := ProcessVariants(bytes.NewReader(data), variants, nil)
+	if err != nil {
+		t.Fatalf("处理失败: %v", err)
+	}
+
+	manifest := result.Manifest
+	if manifest.InputFormat != FormatPNG || manifest.Width != 64 || manifest.Height != 48 || len(manifest.Variants) != len(variants) {
+		t.Fatalf("清单错误: %+v", manifest)
+	}
+
+	tests := []struct {
+		format        ImageFormat
+		width, height int
+		from          string
+	}{
+		{FormatPNG, 16, 12, "w32"},
+		{FormatPNG, 48, 36, ""},
+		{FormatJPEG, 32, 24, "w48"},
+		{FormatPNG, 32, 24, "w32"},
+		{FormatPNG, 20, 20, "w32"},
+		{FormatPNG, 128, 96, ""},
+		{FormatPNG, 64, 48, ""},
+	}
+	for i, tc := range tests {
+		entry, output := manifest.Variants[i], result.Outputs[i]
+		if entry.Name != variants[i].Name || output.Name != entry.Name {
+			t.Fatalf("第 %d 个派生图片顺序错误: %s", i, entry.Name)
+		}
+		if entry.Format != tc.format || entry.Width != tc.width || entry.Height != tc.height || entry.From != tc.from {
+			t.Fatalf("%s 期望 %s %dx%d 来自 %q，实际 %+v", entry.Name, tc.format, tc.width, tc.height, tc.from, entry)
+		}
+		if entry.Size != len(output.Data) || output.Result.Width != tc.width {
+			t.Fatalf("%s 输出错误: %+v", entry.Name, output.Result)
+		}
+
+		// 输出尺寸与单独处理相同
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(output.Data))
+		if err != nil || ImageFormat(format) != tc.format || cfg.Width != tc.width || cfg.Height != tc.height {
+			t.Fatalf("%s 输出图片错误: %s %+v %v", entry.Name, format, cfg, err)
+		}
+		direct, err := ProcessImage(data, variants[i].Processors, variants[i].Options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg, _, _ := image.DecodeConfig(bytes.NewReader(direct)); cfg.Width != tc.width || cfg.Height != tc.height {
+			t.Fatalf("%s 与单独处理的尺寸不同: %dx%d", entry.Name, cfg.Width, cfg.Height)
+		}
+	}
+}
+
+func TestProcessVariantsError(t *testing.T) {
+	data := encodeTestPNG(t, 64, 48)
+
+	if _, err := ProcessVariants(bytes.NewReader(data), []Variant{{Name: "a"}, {Name: "a"}}, nil); err == nil {
+		t.Fatal("期望名称重复错误")
+	}
+
+	// 错误带有派生图片名称
+	var limitErr *LimitError
+	_, err := ProcessVariants(bytes.NewReader(data), []Variant{
+		{Name: "small", Processors: []Processor{NewZoomWidthProcessor(16)}},
+		{Name: "huge", Processors: []Processor{NewZoomWidthProcessor(1000)}},
+	}, &ProcessorOptions{MaxPixels: 10000})
+	if !errors.As(err, &limitErr) || !strings.Contains(err.Error(), "huge") {
+		t.Fatalf("期望 huge 超出像素限制，实际 %v", err)
+	}
+}
@@ -28,6 +28,7 @@ import (
 )
 
 // WatermarkProcessor 水印处理器
+// 可以被多个 goroutine 并发使用，FontFace 不是 basicfont 时需要使用 NewSyncFace 包装
 type WatermarkProcessor struct {
 	Text     string     // 水印文本
 	FontSize float64    // 字体大小
@@ -49,6 +50,11 @@ func (p *WatermarkProcessor) Process(img image.Image) (image.Image, error) {
 	return ctx.dc.Image(), nil
 }
 
+// PlanSize 实现 Planner 接口，处理后尺寸不变
+func (p *WatermarkProcessor) PlanSize(width, height int) (int, int, error) {
+	return width, height, nil
+}
+
 // ContextProcess 实现 ContextProcessor 接口
 func (p *WatermarkProcessor) ContextProcess(ctx *ImageProcessContext) error {
 	dc := ctx.DC()
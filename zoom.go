@@ -20,6 +20,7 @@ package vimage
 import (
 	"fmt"
 	"image"
+	"image/color"
 	"math"
 
 	"golang.org/x/image/draw"
@@ -41,10 +42,25 @@ const (
 	ZoomModeMax
 	// ZoomModeMin 按最小边缩放，保持比例
 	ZoomModeMin
+	// ZoomModeCover 按比例缩放到覆盖目标尺寸，再按 Position 裁剪为目标尺寸
+	ZoomModeCover
+	// ZoomModeContain 按比例缩放到目标尺寸以内
+	ZoomModeContain
+	// ZoomModePad 按比例缩放到目标尺寸以内，再按 Position 放置在目标尺寸的画布上，空白处使用背景填充
+	ZoomModePad
+)
+
+const (
+	// padBlurDownscale 模糊填充时先缩小的倍数
+	padBlurDownscale = 8
+	// padBlurRadius 模糊填充在缩小后的图片上的模糊半径
+	padBlurRadius = 2
+	// padBlurPasses 模糊次数，多次盒式模糊近似高斯模糊
+	padBlurPasses = 3
 )
 
 // ZoomProcessor 图像缩放处理器
-// 对图像进行像素级缩放，不进行裁剪
+// 对图像进行像素级缩放，只有 cover 模式会裁剪，pad 模式会填充
 type ZoomProcessor struct {
 	// 目标宽度和高度
 	Width  int
@@ -53,8 +69,21 @@ type ZoomProcessor struct {
 	Ratio float64
 	// 缩放模式
 	Mode ZoomMode
-	// 缩放算法
+	// 缩放算法，并发使用时不能是 Kernel.NewScaler 返回的带缓存的 Scaler
 	Scaler draw.Scaler
+	// 裁剪位置 (cover 模式) 或放置位置 (pad 模式)，默认居中
+	Position CutPosition
+	// 填充颜色 (pad 模式)，为空时透明
+	Background color.Color
+	// 使用模糊后的原图填充 (pad 模式)，优先于 Background
+	BlurBackground bool
+	// 不放大图片，需要放大时保持原始尺寸，cover 模式裁剪后可能小于目标尺寸
+	NoUpscale bool
+	// 缩小后锐化，放大或尺寸不变时不锐化
+	Sharpen *SharpenProcessor
+
+	// 处理动画时在第一帧上确定的裁剪位置 (cover 模式)，为空时按 Position 计算
+	cropAt *image.Point
 }
 
 // Process 实现Processor接口
@@ -64,30 +93,177 @@ func (p *ZoomProcessor) Process(img image.Image) (image.Image, error) {
 	origWidth := bounds.Dx()
 	origHeight := bounds.Dy()
 
-	// 计算目标尺寸
-	targetWidth, targetHeight := p.calculateTargetSize(origWidth, origHeight)
+	// 计算并验证目标尺寸
+	targetWidth, targetHeight, err := p.PlanSize(origWidth, origHeight)
+	if err != nil {
+		return nil, err
+	}
 
-	// 验证目标尺寸
-	if targetWidth <= 0 || targetHeight <= 0 {
-		return nil, fmt.Errorf("无效的缩放尺寸: %dx%d", targetWidth, targetHeight)
+	scaler := p.scaler()
+	dst, err := p.scale(img, scaler)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.Mode {
+	case ZoomModeCover:
+		// 按位置裁剪超出目标尺寸的部分，复制到原点为 (0,0) 的图像
+		if dst.Rect.Dx() == targetWidth && dst.Rect.Dy() == targetHeight {
+			return dst, nil
+		}
+		var x, y int
+		if p.cropAt != nil {
+			x, y = p.cropAt.X, p.cropAt.Y
+		} else {
+			x, y = cropOffset(dst, p.Position, targetWidth, targetHeight)
+		}
+		cropped := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+		draw.Draw(cropped, cropped.Bounds(), dst, image.Pt(x, y), draw.Src)
+		return cropped, nil
+	case ZoomModePad:
+		return p.pad(img, dst, targetWidth, targetHeight, scaler), nil
 	}
 
-	// 创建目标图像
-	dst := image.NewRGBA(image.Rect(0, 0, targetWidth, targetHeight))
+	return dst, nil
+}
 
-	// 使用指定的缩放算法
-	scaler := p.Scaler
-	if scaler == nil {
-		// 默认使用双线性插值算法
-		scaler = draw.BiLinear
+// scaler 返回指定的缩放算法，默认使用双线性插值算法
+func (p *ZoomProcessor) scaler() draw.Scaler {
+	if p.Scaler == nil {
+		return draw.BiLinear
 	}
+	return p.Scaler
+}
 
-	// 执行缩放
+// scale 将图片缩放为 scaledSize 的尺寸，缩小时按 Sharpen 锐化
+func (p *ZoomProcessor) scale(img image.Image, scaler draw.Scaler) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	origWidth, origHeight := bounds.Dx(), bounds.Dy()
+	scaledWidth, scaledHeight := p.scaledSize(origWidth, origHeight)
+
+	// 创建缩放后的图像并执行缩放
+	dst := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
 	scaler.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
 
+	// 缩小后的图片通常偏软，在裁剪和填充之前锐化
+	if p.Sharpen != nil && scaledWidth*scaledHeight < origWidth*origHeight {
+		sharpened, err := p.Sharpen.Process(dst)
+		if err != nil {
+			return nil, err
+		}
+		dst = sharpened.(*image.RGBA)
+	}
 	return dst, nil
 }
 
+// contentAware 实现 geometryResolver 接口，cover 模式的 smart 位置根据图片内容选择裁剪位置
+func (p *ZoomProcessor) contentAware() bool {
+	return p.Mode == ZoomModeCover && p.Position == CutPositionSmart && p.cropAt == nil
+}
+
+// resolveGeometry 实现 geometryResolver 接口，返回在 img 缩放后的相同位置裁剪的处理器
+func (p *ZoomProcessor) resolveGeometry(img image.Image) (Processor, error) {
+	bounds := img.Bounds()
+	targetWidth, targetHeight, err := p.PlanSize(bounds.Dx(), bounds.Dy())
+	if err != nil {
+		return nil, err
+	}
+	dst, err := p.scale(img, p.scaler())
+	if err != nil {
+		return nil, err
+	}
+
+	x, y := cropOffset(dst, p.Position, targetWidth, targetHeight)
+	resolved := *p
+	resolved.cropAt = &image.Point{X: x, Y: y}
+	return &resolved, nil
+}
+
+// pad 将缩放后的图像放置在目标尺寸的画布上，空白处使用背景颜色或模糊后的原图填充
+func (p *ZoomProcessor) pad(src image.Image, scaled *image.RGBA, width, height int, scaler draw.Scaler) image.Image {
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	if p.BlurBackground {
+		blurFill(canvas, src, scaler)
+	} else if p.Background != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(p.Background), image.Point{}, draw.Src)
+	}
+
+	size := scaled.Bounds().Size()
+	x, y := positionOffset(p.Position, width, height, size.X, size.Y)
+	draw.Draw(canvas, image.Rect(x, y, x+size.X, y+size.Y), scaled, image.Point{}, draw.Over)
+	return canvas
+}
+
+// blurFill 将原图按 cover 方式缩放并模糊后填充到画布
+// 在缩小后的图片上模糊再放大，模糊程度与画布尺寸成比例，耗时与原图尺寸无关
+func blurFill(canvas *image.RGBA, src image.Image, scaler draw.Scaler) {
+	size := canvas.Bounds().Size()
+	small := &ZoomProcessor{
+		Width:  max(size.X/padBlurDownscale, 1),
+		Height: max(size.Y/padBlurDownscale, 1),
+		Mode:   ZoomModeCover,
+		Scaler: scaler,
+	}
+	covered, err := small.Process(src)
+	if err != nil {
+		return
+	}
+
+	blurred := covered.(*image.RGBA)
+	for i := 0; i < padBlurPasses; i++ {
+		boxBlur(blurred, padBlurRadius)
+	}
+	draw.BiLinear.Scale(canvas, canvas.Bounds(), blurred, blurred.Bounds(), draw.Src, nil)
+}
+
+// PlanSize 实现 Planner 接口，计算缩放后的尺寸
+// cover 模式为裁剪后的尺寸，pad 模式为画布尺寸
+func (p *ZoomProcessor) PlanSize(origWidth, origHeight int) (int, int, error) {
+	switch p.Mode {
+	case ZoomModeCover, ZoomModeContain, ZoomModePad:
+		if p.Width <= 0 || p.Height <= 0 {
+			return 0, 0, fmt.Errorf("无效的缩放尺寸: %dx%d", p.Width, p.Height)
+		}
+	}
+
+	width, height := p.scaledSize(origWidth, origHeight)
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("无效的缩放尺寸: %dx%d", width, height)
+	}
+	if p.Sharpen != nil {
+		if _, _, err := p.Sharpen.PlanSize(width, height); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	switch p.Mode {
+	case ZoomModeCover:
+		return min(width, p.Width), min(height, p.Height), nil
+	case ZoomModePad:
+		return p.Width, p.Height, nil
+	}
+	return width, height, nil
+}
+
+// scalesOnly 是否只缩放，不裁剪也不填充
+func (p *ZoomProcessor) scalesOnly() bool {
+	return p.Mode != ZoomModeCover && p.Mode != ZoomModePad
+}
+
+// scaledSize 计算缩放后、裁剪或填充前的尺寸，NoUpscale 时不超过原始尺寸
+func (p *ZoomProcessor) scaledSize(origWidth, origHeight int) (int, int) {
+	width, height := p.calculateTargetSize(origWidth, origHeight)
+	if !p.NoUpscale || (width <= origWidth && height <= origHeight) {
+		return width, height
+	}
+
+	// 精确缩放的两个方向分别限制，其他模式保持比例
+	if p.Mode == ZoomModeExact {
+		return min(width, origWidth), min(height, origHeight)
+	}
+	return origWidth, origHeight
+}
+
 // calculateTargetSize 根据缩放模式计算目标尺寸
 func (p *ZoomProcessor) calculateTargetSize(origWidth, origHeight int) (int, int) {
 	switch p.Mode {
@@ -136,6 +312,18 @@ func (p *ZoomProcessor) calculateTargetSize(origWidth, origHeight int) (int, int
 		return int(math.Round(float64(origWidth) * ratio)),
 			int(math.Round(float64(origHeight) * ratio))
 
+	case ZoomModeCover:
+		// 按较大的比例缩放，两边都不小于目标尺寸
+		ratio := max(float64(p.Width)/float64(origWidth), float64(p.Height)/float64(origHeight))
+		return max(int(math.Round(float64(origWidth)*ratio)), p.Width),
+			max(int(math.Round(float64(origHeight)*ratio)), p.Height)
+
+	case ZoomModeContain, ZoomModePad:
+		// 按较小的比例缩放，两边都不大于目标尺寸
+		ratio := min(float64(p.Width)/float64(origWidth), float64(p.Height)/float64(origHeight))
+		return min(max(int(math.Round(float64(origWidth)*ratio)), 1), p.Width),
+			min(max(int(math.Round(float64(origHeight)*ratio)), 1), p.Height)
+
 	default:
 		// 默认精确缩放
 		return p.Width, p.Height
@@ -193,6 +381,65 @@ func NewZoomMinProcessor(size int) *ZoomProcessor {
 	}
 }
 
+// NewZoomCoverProcessor 创建新的覆盖缩放处理器，按比例缩放到覆盖目标尺寸后按位置裁剪
+func NewZoomCoverProcessor(width, height int, position CutPosition) *ZoomProcessor {
+	return &ZoomProcessor{
+		Width:    width,
+		Height:   height,
+		Position: position,
+		Mode:     ZoomModeCover,
+	}
+}
+
+// NewZoomContainProcessor 创建新的包含缩放处理器，按比例缩放到目标尺寸以内
+func NewZoomContainProcessor(width, height int) *ZoomProcessor {
+	return &ZoomProcessor{
+		Width:  width,
+		Height: height,
+		Mode:   ZoomModeContain,
+	}
+}
+
+// NewZoomPadProcessor 创建新的填充缩放处理器，按比例缩放到目标尺寸以内后居中放置，空白处使用背景颜色填充
+func NewZoomPadProcessor(width, height int, background color.Color) *ZoomProcessor {
+	return &ZoomProcessor{
+		Width:      width,
+		Height:     height,
+		Background: background,
+		Mode:       ZoomModePad,
+	}
+}
+
+// WithPosition 设置裁剪或放置位置
+func (p *ZoomProcessor) WithPosition(position CutPosition) *ZoomProcessor {
+	p.Position = position
+	return p
+}
+
+// WithBackground 设置填充颜色
+func (p *ZoomProcessor) WithBackground(background color.Color) *ZoomProcessor {
+	p.Background = background
+	return p
+}
+
+// WithBlurBackground 设置是否使用模糊后的原图填充
+func (p *ZoomProcessor) WithBlurBackground(blur bool) *ZoomProcessor {
+	p.BlurBackground = blur
+	return p
+}
+
+// WithNoUpscale 设置是否禁止放大
+func (p *ZoomProcessor) WithNoUpscale(noUpscale bool) *ZoomProcessor {
+	p.NoUpscale = noUpscale
+	return p
+}
+
+// WithSharpen 设置缩小后的锐化处理器，为空时不锐化
+func (p *ZoomProcessor) WithSharpen(sharpen *SharpenProcessor) *ZoomProcessor {
+	p.Sharpen = sharpen
+	return p
+}
+
 // WithScaler 设置缩放算法
 func (p *ZoomProcessor) WithScaler(scaler draw.Scaler) *ZoomProcessor {
 	p.Scaler = scaler
@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one or more
+ * contributor license agreements.  See the NOTICE file distributed with
+ * this work for additional information regarding copyright ownership.
+ * The ASF licenses this file to You under the Apache License, Version 2.0
+ * (the "License"); you may not use this file except in compliance with
+ * the License.  You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vimage
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+// halfImage 左半红色、右半蓝色的图片
+func halfImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, image.Rect(0, 0, w/2, h), image.NewUniform(testRed), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(w/2, 0, w, h), image.NewUniform(testBlue), image.Point{}, draw.Src)
+	return img
+}
+
+func TestZoomProcessorModes(t *testing.T) {
+	img := halfImage(400, 300)
+
+	tests := []struct {
+		name          string
+		processor     *ZoomProcessor
+		width, height int
+	}{
+		{"cover", NewZoomCoverProcessor(300, 300, CutPositionCenter), 300, 300},
+		{"cover宽图", NewZoomCoverProcessor(400, 100, CutPositionTop), 400, 100},
+		{"cover不放大", NewZoomCoverProcessor(600, 600, "").WithNoUpscale(true), 400, 300},
+		{"contain", NewZoomContainProcessor(300, 300), 300, 225},
+		{"contain不放大", NewZoomContainProcessor(800, 800).WithNoUpscale(true), 400, 300},
+		{"pad", NewZoomPadProcessor(300, 300, testWhite), 300, 300},
+		{"pad不放大", NewZoomPadProcessor(800, 800, nil).WithNoUpscale(true), 800, 800},
+		{"width不放大", NewZoomWidthProcessor(800).WithNoUpscale(true), 400, 300},
+		{"exact不放大", NewZoomProcessor(800, 100).WithNoUpscale(true), 400, 100},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w, h, err := tc.processor.PlanSize(400, 300)
+			if err != nil || w != tc.width || h != tc.height {
+				t.Fatalf("规划尺寸期望 %dx%d，实际 %dx%d, %v", tc.width, tc.height, w, h, err)
+			}
+			out, err := tc.processor.Process(img)
+			if err != nil {
+				t.Fatalf("缩放失败: %v", err)
+			}
+			if b := out.Bounds(); b != image.Rect(0, 0, tc.width, tc.height) {
+				t.Fatalf("输出尺寸期望 %dx%d，实际 %v", tc.width, tc.height, b)
+			}
+		})
+	}
+
+	if _, err := NewZoomCoverProcessor(300, 0, "").Process(img); err == nil {
+		t.Fatal("期望缺少高度时报错")
+	}
+}
+
+func TestZoomProcessorPosition(t *testing.T) {
+	img := halfImage(400, 300)
+	at := func(img image.Image, x, y int) color.RGBA {
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+
+	// cover 按位置裁剪
+	left, _ := NewZoomCoverProcessor(100, 100, CutPositionLeft).Process(img)
+	right, _ := NewZoomCoverProcessor(100, 100, CutPositionRight).Process(img)
+	if at(left, 50, 50) != testRed || at(right, 50, 50) != testBlue {
+		t.Fatalf("cover 裁剪位置错误: %v %v", at(left, 50, 50), at(right, 50, 50))
+	}
+
+	// pad 居中放置，上下填充背景
+	padded, _ := NewZoomPadProcessor(300, 300, testWhite).Process(img)
+	if at(padded, 150, 10) != testWhite || at(padded, 10, 150) != testRed || at(padded, 290, 150) != testBlue {
+		t.Fatal("pad 居中放置错误")
+	}
+	top, _ := NewZoomPadProcessor(300, 300, testWhite).WithPosition(CutPositionTop).Process(img)
+	if at(top, 10, 10) != testRed || at(top, 10, 290) != testWhite {
+		t.Fatal("pad 顶部放置错误")
+	}
+
+	// 未指定背景时透明，模糊填充时不透明
+	transparent, _ := NewZoomPadProcessor(300, 300, nil).Process(img)
+	if at(transparent, 150, 10).A != 0 {
+		t.Fatal("期望透明背景")
+	}
+	blurred, _ := NewZoomPadProcessor(300, 300, nil).WithBlurBackground(true).Process(img)
+	if c := at(blurred, 10, 10); c.A != 0xff || c.R == 0 {
+		t.Fatalf("期望模糊的原图填充，实际 %v", c)
+	}
+}